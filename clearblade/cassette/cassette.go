@@ -0,0 +1,360 @@
+// Package cassette records the HTTP interactions of the provider with the
+// ClearBlade IoT Core API to a file, a cassette, and replays them in tests.
+//
+// Cassettes are sanitised before they are written: tokens and system keys
+// are replaced with Redacted, and request headers are not recorded. Device
+// public keys and registry certificates are public and kept, so that the
+// state of replayed resources matches their configuration. Requests are
+// sanitised the same way before they are matched during replay, so a
+// cassette recorded against one ClearBlade environment replays with the
+// credentials of any other.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces sensitive values in cassettes.
+const Redacted = "REDACTED"
+
+// redactedFields are the JSON fields whose values are redacted wherever they
+// appear.
+var redactedFields = map[string]bool{
+	"token":               true,
+	"serviceAccountToken": true,
+	"systemKey":           true,
+}
+
+// Cassette is the file format of recorded interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it received.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a sanitised request.
+type Request struct {
+	Method string `json:"method"`
+	// URL is the path and query of the request. The host is not recorded.
+	URL  string `json:"url"`
+	Body string `json:"body,omitempty"`
+}
+
+// Response is a sanitised response.
+type Response struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// Load reads the cassette at path.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Recorder is an http.RoundTripper that sends requests with another round
+// tripper and keeps the sanitised interactions in memory until it is closed,
+// when it appends them to a cassette. Delete the cassette to record it again
+// from scratch.
+type Recorder struct {
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   []*Recorder
+)
+
+// NewRecorder returns a Recorder that appends to the cassette at path and
+// sends requests with next. CloseRecorders closes it with the other
+// Recorders of the process.
+func NewRecorder(path string, next http.RoundTripper) *Recorder {
+	r := &Recorder{path: path, next: next}
+
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	recorders = append(recorders, r)
+	return r
+}
+
+// CloseRecorders closes the Recorders of the process in the order they were
+// created, so that the interactions of the provider instances Terraform
+// starts for each command of a test are written in sequence.
+func CloseRecorders() error {
+	recordersMu.Lock()
+	closing := recorders
+	recorders = nil
+	recordersMu.Unlock()
+
+	var errs []error
+	for _, r := range closing {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// recordMu serialises writes to cassettes, which several Recorders in a
+// process may append to.
+var recordMu sync.Mutex
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &Interaction{
+		Request: sanitizeRequest(req, reqBody),
+		Response: Response{
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        sanitizeBody(respBody),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction)
+	return resp, nil
+}
+
+// Close appends the interactions recorded since the last Close to the
+// cassette.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.interactions) == 0 {
+		return nil
+	}
+
+	recordMu.Lock()
+	defer recordMu.Unlock()
+	c, err := Load(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		c, err = &Cassette{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	c.Interactions = append(c.Interactions, r.interactions...)
+	if err := c.Save(r.path); err != nil {
+		return fmt.Errorf("cassette %s: %w", r.path, err)
+	}
+	r.interactions = nil
+	return nil
+}
+
+// Replayer is an http.RoundTripper that serves the responses of a cassette.
+// Each request is served the first unused interaction with the same method,
+// URL and body, and a request that matches none fails.
+type Replayer struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+	unmatched    []string
+}
+
+var (
+	replayersMu sync.Mutex
+	replayers   = map[string]*Replayer{}
+)
+
+// NewReplayer returns a Replayer of the cassette at path. Replayers of the
+// same cassette in a process share its interactions, so that the provider
+// instances Terraform starts for each command of a test replay the cassette
+// in sequence rather than each from the start.
+func NewReplayer(path string) (*Replayer, error) {
+	replayersMu.Lock()
+	defer replayersMu.Unlock()
+
+	if r, ok := replayers[path]; ok {
+		return r, nil
+	}
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replayer{path: path, interactions: c.Interactions, used: make([]bool, len(c.Interactions))}
+	replayers[path] = r
+	return r, nil
+}
+
+// Forget drops the shared Replayer of the cassette at path, so that the next
+// NewReplayer replays it from the start.
+func Forget(path string) {
+	replayersMu.Lock()
+	defer replayersMu.Unlock()
+	delete(replayers, path)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	want := sanitizeRequest(req, body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request != want {
+			continue
+		}
+		r.used[i] = true
+
+		header := http.Header{}
+		if interaction.Response.ContentType != "" {
+			header.Set("Content-Type", interaction.Response.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	request := want.Method + " " + want.URL
+	if want.Body != "" {
+		request += " " + want.Body
+	}
+	r.unmatched = append(r.unmatched, request)
+	return nil, fmt.Errorf("cassette %s: no unused recorded interaction matches the request %s", r.path, request)
+}
+
+// Unmatched returns the requests no recorded interaction matched.
+func (r *Replayer) Unmatched() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.unmatched...)
+}
+
+// Unused returns the number of recorded interactions not replayed yet.
+func (r *Replayer) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// readBody reads and replaces *body, so that it can be read again.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+// sanitizeRequest returns the recorded form of a request.
+func sanitizeRequest(req *http.Request, body string) Request {
+	u := url.URL{Path: sanitizePath(req.URL.Path), RawQuery: req.URL.Query().Encode()}
+	return Request{Method: req.Method, URL: u.String(), Body: sanitizeBody(body)}
+}
+
+// sanitizePath redacts the system key of the ClearBlade API paths
+// /api/v/4/webhook/execute/{systemKey}/... and /api/v/1/code/{systemKey}/....
+func sanitizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "execute" || segments[i] == "code" {
+			segments[i+1] = Redacted
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sanitizeBody redacts the sensitive fields of a JSON body, re-encoding it
+// with sorted keys. Bodies that are not JSON are returned unchanged.
+func sanitizeBody(body string) string {
+	var v any
+	if body == "" || json.Unmarshal([]byte(body), &v) != nil {
+		return body
+	}
+	data, err := json.Marshal(redact(v))
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+// redact returns v with the values of sensitive fields replaced.
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if redactedFields[k] && field != nil {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = redact(field)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,188 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const credentialsResponse = `{"systemKey":"secret-system-key","token":"secret-token","url":"https://iot.example.com"}`
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	n := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/getRegistryCredentials"):
+			io.WriteString(w, credentialsResponse)
+		default:
+			n++
+			io.WriteString(w, `{"id":"device-1","numId":"`+strings.Repeat("1", n)+`","credentials":[{"publicKey":{"format":"RSA_PEM","key":"public-key"}}]}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func do(t *testing.T, client *http.Client, method, url, body string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	t.Cleanup(func() { Forget(path) })
+
+	recorder := NewRecorder(path, http.DefaultTransport)
+	recording := &http.Client{Transport: recorder}
+	do(t, recording, http.MethodPost, server.URL+"/api/v/1/code/secret-system-key/getRegistryCredentials", `{"registry":"r1"}`)
+	_, first := do(t, recording, http.MethodGet, server.URL+"/api/v/4/webhook/execute/secret-system-key/cloudiot_devices?name=d1", "")
+	_, second := do(t, recording, http.MethodGet, server.URL+"/api/v/4/webhook/execute/secret-system-key/cloudiot_devices?name=d1", "")
+
+	// The cassette is written when the recorder is closed.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the cassette was written before the recorder was closed: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-system-key", "secret-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "public-key") {
+		t.Errorf("cassette does not contain the public key:\n%s", data)
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaying := &http.Client{Transport: replayer}
+	// The host and system key of replayed requests do not matter.
+	resp, body := do(t, replaying, http.MethodPost, "https://other.example.com/api/v/1/code/other-key/getRegistryCredentials", `{"registry":"r1"}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"token":"REDACTED"`) {
+		t.Errorf("replayed credentials %d %s", resp.StatusCode, body)
+	}
+	// Repeated requests are served their responses in the recorded order.
+	if _, body := do(t, replaying, http.MethodGet, "https://other.example.com/api/v/4/webhook/execute/other-key/cloudiot_devices?name=d1", ""); body != sanitizeBody(first) {
+		t.Errorf("first replayed device %s, want %s", body, sanitizeBody(first))
+	}
+	if _, body := do(t, replaying, http.MethodGet, "https://other.example.com/api/v/4/webhook/execute/other-key/cloudiot_devices?name=d1", ""); body != sanitizeBody(second) {
+		t.Errorf("second replayed device %s, want %s", body, sanitizeBody(second))
+	}
+	if n := replayer.Unused(); n != 0 {
+		t.Errorf("%d interactions were not replayed", n)
+	}
+}
+
+func TestReplayUnmatched(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	t.Cleanup(func() { Forget(path) })
+
+	recorder := NewRecorder(path, http.DefaultTransport)
+	do(t, &http.Client{Transport: recorder}, http.MethodGet, server.URL+"/api/v/4/webhook/execute/key/cloudiot_devices?name=d1", "")
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaying := &http.Client{Transport: replayer}
+	// d2 was never recorded, and the one recorded request for d1 is used up
+	// by the first replay.
+	for _, tc := range []struct {
+		name    string
+		matched bool
+	}{
+		{"d2", false},
+		{"d1", true},
+		{"d1", false},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v/4/webhook/execute/key/cloudiot_devices?name="+tc.name, nil)
+		resp, err := replaying.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if matched := err == nil; matched != tc.matched {
+			t.Errorf("request for %s: matched %t, want %t (error %v)", tc.name, matched, tc.matched, err)
+		}
+	}
+	if got := replayer.Unmatched(); len(got) != 2 || !strings.Contains(got[0], "name=d2") {
+		t.Errorf("unmatched requests %q", got)
+	}
+}
+
+func TestCloseRecordersAppends(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// Each recorder appends to the cassette, in the order the recorders were
+	// created.
+	first, second := NewRecorder(path, http.DefaultTransport), NewRecorder(path, http.DefaultTransport)
+	do(t, &http.Client{Transport: second}, http.MethodGet, server.URL+"/api/v/4/webhook/execute/key/cloudiot_devices?name=d2", "")
+	do(t, &http.Client{Transport: first}, http.MethodGet, server.URL+"/api/v/4/webhook/execute/key/cloudiot_devices?name=d1", "")
+	if err := CloseRecorders(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing again writes nothing more.
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, interaction := range c.Interactions {
+		got = append(got, interaction.Request.URL)
+	}
+	if len(got) != 2 || !strings.HasSuffix(got[0], "name=d1") || !strings.HasSuffix(got[1], "name=d2") {
+		t.Errorf("recorded requests %q, want d1 then d2", got)
+	}
+}
+
+func TestSanitizeBody(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{`{"serviceAccountToken":"t","url":"u"}`, `{"serviceAccountToken":"REDACTED","url":"u"}`},
+		{`{"systemKey":"k","devices":[{"token":"t"}]}`, `{"devices":[{"token":"REDACTED"}],"systemKey":"REDACTED"}`},
+		// Public keys and certificates are public, and replayed into state.
+		{`{"credentials":[{"publicKeyCertificate":{"certificate":"c","format":"X509_CERTIFICATE_PEM"}}]}`, `{"credentials":[{"publicKeyCertificate":{"certificate":"c","format":"X509_CERTIFICATE_PEM"}}]}`},
+		{`{"metadata":{"key":"kept"},"publicKey":{"key":"k"}}`, `{"metadata":{"key":"kept"},"publicKey":{"key":"k"}}`},
+		{`not json`, `not json`},
+	} {
+		if got := sanitizeBody(tc.in); got != tc.want {
+			t.Errorf("sanitizeBody(%s) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package clearbladetest
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// A Fault is a failure the fake injects into a request.
+type Fault int
+
+const (
+	// TooManyRequests fails the request with 429 RESOURCE_EXHAUSTED, as the
+	// API does when a quota is exceeded.
+	TooManyRequests Fault = iota + 1
+	// Unavailable fails the request with 503 UNAVAILABLE.
+	Unavailable
+	// NotFound fails the request with 404 NOT_FOUND. Matching the get
+	// methods reproduces the API's eventual consistency, where a registry or
+	// device is not found for a short time after it was created.
+	NotFound
+	// Slow serves the request after waiting for the rule's Delay.
+	Slow
+	// ConnectionReset resets the connection without serving the request.
+	ConnectionReset
+	// NotConnected fails the request with 400 FAILED_PRECONDITION, as the
+	// API does when a command is sent to a device that is not connected.
+	NotConnected
+)
+
+// A FaultRule injects a fault into the requests it matches. Rules are
+// evaluated in the order they were added, and the first one that fires
+// decides the fault of a request.
+type FaultRule struct {
+	// Method is the API method to match, named as by RequestCount, for
+	// example "registries.devices.get". Empty matches every method.
+	Method string
+	// Path is a regular expression matched against the name of the resource
+	// the request addresses, for example
+	// "/registries/r1/devices/d1$". Requests listing or creating
+	// resources address their parent. Empty matches every resource.
+	Path string
+	// Probability is the chance, between 0 and 1, that the rule fires for a
+	// matching request. Zero fires for every matching request.
+	Probability float64
+	// Count is the number of times the rule fires before it is spent. Zero
+	// never spends the rule.
+	Count int
+
+	// Fault is the fault to inject.
+	Fault Fault
+	// Delay is how long a Slow fault waits.
+	Delay time.Duration
+
+	path  *regexp.Regexp
+	fired int
+}
+
+// AddFault adds a fault rule. It panics if the rule's Path is not a valid
+// regular expression.
+func (s *Server) AddFault(rule FaultRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rule.Path != "" {
+		rule.path = regexp.MustCompile(rule.Path)
+	}
+	s.faults = append(s.faults, &rule)
+}
+
+// ClearFaults removes every fault rule.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// FaultCount returns the number of faults injected since the rules were
+// last cleared.
+func (s *Server) FaultCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, rule := range s.faults {
+		total += rule.fired
+	}
+	return total
+}
+
+// SeedFaults seeds the random source of rules with a Probability, so that a
+// test sees the same sequence of faults on every run.
+func (s *Server) SeedFaults(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.random = rand.New(rand.NewSource(seed))
+}
+
+// matchFault returns the rule that fires for a request of method addressing
+// path, if any.
+func (s *Server) matchFault(method, path string) *FaultRule {
+	for _, rule := range s.faults {
+		switch {
+		case rule.Count > 0 && rule.fired >= rule.Count:
+			continue
+		case rule.Method != "" && rule.Method != method:
+			continue
+		case rule.path != nil && !rule.path.MatchString(path):
+			continue
+		case rule.Probability > 0 && s.random.Float64() >= rule.Probability:
+			continue
+		}
+		rule.fired++
+		return rule
+	}
+	return nil
+}
+
+// injectFault applies the fault of rule to a request and reports whether the
+// request should still be served. It is called with s.mu held.
+func (s *Server) injectFault(w http.ResponseWriter, rule *FaultRule) bool {
+	switch rule.Fault {
+	case TooManyRequests:
+		writeError(w, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED", "Quota exceeded (injected fault).")
+	case Unavailable:
+		writeError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "The service is currently unavailable (injected fault).")
+	case NotFound:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The resource does not exist (injected fault).")
+	case Slow:
+		// Other requests are served while this one waits.
+		s.mu.Unlock()
+		time.Sleep(rule.Delay)
+		s.mu.Lock()
+		return true
+	case ConnectionReset:
+		resetConnection(w)
+	case NotConnected:
+		writeError(w, http.StatusBadRequest, "FAILED_PRECONDITION", "Device is not connected (injected fault).")
+	}
+	return false
+}
+
+// resetConnection closes the connection of w without writing a response,
+// discarding unsent data so that the client sees a reset.
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("clearbladetest: connection cannot be hijacked")
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic("clearbladetest: hijacking connection: " + err.Error())
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,122 @@
+package clearbladetest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clearblade/go-iot"
+)
+
+func TestFaultStatus(t *testing.T) {
+	server, client := newTestService(t)
+	registries := client.Projects.Locations.Registries
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+
+	for _, tc := range []struct {
+		fault Fault
+		code  int
+	}{
+		{TooManyRequests, http.StatusTooManyRequests},
+		{Unavailable, http.StatusServiceUnavailable},
+		{NotFound, http.StatusNotFound},
+		{NotConnected, http.StatusBadRequest},
+	} {
+		server.ClearFaults()
+		server.AddFault(FaultRule{Method: "registries.get", Count: 1, Fault: tc.fault})
+
+		_, err := registries.Get(registryName).Do()
+		wantStatus(t, err, tc.code)
+		if _, err := registries.Get(registryName).Do(); err != nil {
+			t.Fatalf("get after the fault was spent: %s", err)
+		}
+		if got := server.FaultCount(); got != 1 {
+			t.Errorf("fault %d was injected %d times, want 1", tc.fault, got)
+		}
+	}
+}
+
+func TestFaultMatching(t *testing.T) {
+	server, client := newTestService(t)
+	devices := client.Projects.Locations.Registries.Devices
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	for _, id := range []string{"device-1", "device-2"} {
+		if err := server.PutDevice(registryName, &iot.Device{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server.AddFault(FaultRule{Method: "registries.devices.get", Path: "/devices/device-1$", Fault: Unavailable})
+
+	_, err := devices.Get(registryName + "/devices/device-1").Do()
+	wantStatus(t, err, http.StatusServiceUnavailable)
+	if _, err := devices.Get(registryName + "/devices/device-2").Do(); err != nil {
+		t.Errorf("get of an unmatched path: %s", err)
+	}
+	if _, err := devices.List(registryName).Do(); err != nil {
+		t.Errorf("list, an unmatched method: %s", err)
+	}
+	_, err = devices.Get(registryName + "/devices/device-1").Do()
+	wantStatus(t, err, http.StatusServiceUnavailable)
+}
+
+func TestFaultProbabilityIsDeterministic(t *testing.T) {
+	run := func() []bool {
+		server, client := newTestService(t)
+		server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+		server.SeedFaults(42)
+		server.AddFault(FaultRule{Method: "registries.get", Probability: 0.5, Fault: TooManyRequests})
+
+		failed := []bool{}
+		for i := 0; i < 20; i++ {
+			_, err := client.Projects.Locations.Registries.Get(registryName).Do()
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first, second := run(), run()
+	failures := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs with the same seed differ at request %d", i)
+		}
+		if first[i] {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(first) {
+		t.Errorf("%d of %d requests failed with probability 0.5", failures, len(first))
+	}
+}
+
+func TestSlowFault(t *testing.T) {
+	server, client := newTestService(t)
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	server.AddFault(FaultRule{Method: "registries.get", Fault: Slow, Delay: 100 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := client.Projects.Locations.Registries.Get(registryName).Do(); err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("slow request took %s, want at least 100ms", elapsed)
+	}
+}
+
+func TestConnectionResetFault(t *testing.T) {
+	server, client := newTestService(t)
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	server.AddFault(FaultRule{Method: "registries.devices.create", Count: 1, Fault: ConnectionReset})
+
+	devices := client.Projects.Locations.Registries.Devices
+	if _, err := devices.Create(registryName, &iot.Device{Id: "device-1"}).Do(); err == nil {
+		t.Fatal("create succeeded over a reset connection")
+	}
+	if _, ok := server.Device(registryName + "/devices/device-1"); ok {
+		t.Error("device was created by a reset request")
+	}
+	if _, err := devices.Create(registryName, &iot.Device{Id: "device-1"}).Do(); err != nil {
+		t.Fatalf("create after the fault was spent: %s", err)
+	}
+}
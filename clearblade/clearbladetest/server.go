@@ -0,0 +1,1170 @@
+// Package clearbladetest provides an in-memory fake of the ClearBlade IoT
+// Core API for tests.
+//
+// The fake serves the registry, device, config version, state, gateway
+// binding and command endpoints used by the provider over HTTP, so a real
+// go-iot client can be pointed at it:
+//
+//	server := clearbladetest.NewServer()
+//	defer server.Close()
+//
+//	client, err := server.NewService(ctx)
+//
+// Fault rules added with AddFault make matching requests fail, for example
+// to test how callers handle throttling or eventual consistency:
+//
+//	server.AddFault(clearbladetest.FaultRule{
+//		Method: "registries.devices.get",
+//		Count:  1,
+//		Fault:  clearbladetest.NotFound,
+//	})
+package clearbladetest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clearblade/go-iot"
+)
+
+const (
+	// Project is the project of the fake's service account.
+	Project = "test-project"
+
+	// DefaultPageSize is the number of items listed per page when a list
+	// request does not set a page size.
+	DefaultPageSize = 100
+
+	projectSystemKey = "project-system-key"
+	projectToken     = "project-token"
+
+	// maxConfigVersions is the number of config versions kept per device.
+	maxConfigVersions = 10
+	// maxStates is the number of states kept per device.
+	maxStates = 10
+)
+
+var (
+	resourceIDPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._%+~-]{2,254}$`)
+	devicePattern     = regexp.MustCompile(`^(projects/[^/]+/locations/[^/]+/registries/[^/]+)/devices/([^/]+)$`)
+	locationPattern   = regexp.MustCompile(`^projects/([^/]+)/locations/([^/]+)$`)
+
+	// mutableRegistryFields are the top-level registry fields an update mask
+	// may name.
+	mutableRegistryFields = []string{"credentials", "eventNotificationConfigs", "httpConfig", "logLevel", "mqttConfig", "stateNotificationConfig"}
+	// mutableDeviceFields are the device fields an update mask may name.
+	mutableDeviceFields = []string{"blocked", "credentials", "gatewayConfig.gatewayAuthMethod", "logLevel", "metadata"}
+)
+
+// Server is a fake ClearBlade IoT Core API. Its exported methods inspect and
+// change the stored data directly, for example to seed devices or to delete a
+// device out of band. They take full resource names such as
+// projects/test-project/locations/us-central1/registries/r1/devices/d1.
+type Server struct {
+	*httptest.Server
+
+	// Now returns the time used for server-set timestamps.
+	Now func() time.Time
+
+	mu         sync.Mutex
+	registries map[string]*registry
+	tokens     map[string]string
+	nextNumID  uint64
+	requests   map[string]int
+	faults     []*FaultRule
+	random     *rand.Rand
+}
+
+type registry struct {
+	registry *iot.DeviceRegistry
+	devices  map[string]*device
+}
+
+type device struct {
+	device   *iot.Device
+	configs  []*iot.DeviceConfig
+	states   []*iot.DeviceState
+	commands []*iot.SendCommandToDeviceRequest
+	// bound holds the IDs of the devices bound to a gateway.
+	bound map[string]bool
+}
+
+// NewServer starts a fake with no registries. The caller must call Close.
+func NewServer() *Server {
+	s := &Server{
+		Now:        time.Now,
+		registries: map[string]*registry{},
+		tokens:     map[string]string{},
+		nextNumID:  2820000000000000,
+		requests:   map[string]int{},
+		random:     rand.New(rand.NewSource(1)),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Credentials returns service account credentials for the fake, in the JSON
+// format accepted by the provider's credentials argument.
+func (s *Server) Credentials() string {
+	credentials, _ := json.Marshal(iot.ServiceAccountCredentials{
+		SystemKey: projectSystemKey,
+		Token:     projectToken,
+		Url:       s.URL,
+		Project:   Project,
+	})
+	return string(credentials)
+}
+
+// NewService returns a go-iot client that talks to the fake.
+func (s *Server) NewService(ctx context.Context) (*iot.Service, error) {
+	return iot.NewService(ctx, iot.WithHTTPClient(s.Client()), iot.WithServiceAccountCredentials(s.Credentials()))
+}
+
+// RequestCount returns the number of requests made for an API method, named
+// like the method id without the cloudiot.projects.locations prefix, for
+// example "registries.get", "registries.devices.list" or
+// "registries.devices.states.list". The registry credential exchange is
+// counted as "getRegistryCredentials".
+func (s *Server) RequestCount(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method]
+}
+
+// RequestCounts returns the number of requests made for each method, named as
+// by RequestCount.
+func (s *Server) RequestCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int, len(s.requests))
+	for method, n := range s.requests {
+		counts[method] = n
+	}
+	return counts
+}
+
+// TotalRequestCount returns the number of requests made for every method.
+func (s *Server) TotalRequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, n := range s.requests {
+		total += n
+	}
+	return total
+}
+
+// ResetRequestCounts sets every request count back to zero.
+func (s *Server) ResetRequestCounts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = map[string]int{}
+}
+
+// PutRegistry stores a copy of r in the location parent, replacing any
+// registry with the same ID.
+func (s *Server) PutRegistry(parent string, r *iot.DeviceRegistry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := copyRegistry(r)
+	stored.Name = parent + "/registries/" + r.Id
+	defaultRegistry(stored)
+	existing := s.registries[stored.Name]
+	if existing == nil {
+		existing = &registry{devices: map[string]*device{}}
+		s.registries[stored.Name] = existing
+	}
+	existing.registry = stored
+}
+
+// Registry returns a copy of the registry name.
+func (s *Server) Registry(name string) (*iot.DeviceRegistry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.registries[name]
+	if !ok {
+		return nil, false
+	}
+	return copyRegistry(r.registry), true
+}
+
+// DeleteRegistry removes the registry name and its devices.
+func (s *Server) DeleteRegistry(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.registries, name)
+}
+
+// PutDevice stores a copy of d in the registry parent, replacing any device
+// with the same ID. The registry must exist.
+func (s *Server) PutDevice(parent string, d *iot.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.registries[parent]
+	if !ok {
+		return fmt.Errorf("registry %s not found", parent)
+	}
+	if existing, ok := r.devices[d.Id]; ok {
+		existing.device = s.newDevice(parent, d).device
+		return nil
+	}
+	r.devices[d.Id] = s.newDevice(parent, d)
+	return nil
+}
+
+// Device returns a copy of the device name.
+func (s *Server) Device(name string) (*iot.Device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, d := s.lookupDevice(name)
+	if d == nil {
+		return nil, false
+	}
+	return copyDevice(d.device), true
+}
+
+// DeleteDevice removes the device name and its gateway associations.
+func (s *Server) DeleteDevice(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, d := s.lookupDevice(name)
+	if d != nil {
+		r.removeDevice(d.device.Id)
+	}
+}
+
+// AddDeviceState records a state reported by the device name.
+func (s *Server) AddDeviceState(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, d := s.lookupDevice(name)
+	if d == nil {
+		return fmt.Errorf("device %s not found", name)
+	}
+	now := s.timestamp()
+	state := &iot.DeviceState{UpdateTime: now, BinaryData: base64.StdEncoding.EncodeToString(data)}
+	d.states = append([]*iot.DeviceState{state}, d.states...)
+	if len(d.states) > maxStates {
+		d.states = d.states[:maxStates]
+	}
+	d.device.State = state
+	d.device.LastStateTime = now
+	return nil
+}
+
+// Commands returns the commands sent to the device name, oldest first.
+func (s *Server) Commands(name string) []*iot.SendCommandToDeviceRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, d := s.lookupDevice(name)
+	if d == nil {
+		return nil
+	}
+	commands := make([]*iot.SendCommandToDeviceRequest, len(d.commands))
+	copy(commands, d.commands)
+	return commands
+}
+
+// BoundDevices returns the IDs of the devices bound to the gateway name,
+// sorted.
+func (s *Server) BoundDevices(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, d := s.lookupDevice(name)
+	if d == nil {
+		return nil
+	}
+	return sortedSet(d.bound)
+}
+
+func (s *Server) handle(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	method, ok := operation(req)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown path %s", req.URL.Path)
+		return
+	}
+	s.requests[method]++
+
+	if rule := s.matchFault(method, s.resourcePath(req)); rule != nil {
+		if !s.injectFault(w, rule) {
+			return
+		}
+	}
+
+	query := req.URL.Query()
+	token := req.Header.Get("ClearBlade-UserToken")
+	switch method {
+	case "getRegistryCredentials":
+		s.registryCredentials(w, req)
+	case "registries.bindDeviceToGateway":
+		s.bindDevice(w, req, token, true)
+	case "registries.unbindDeviceFromGateway":
+		s.bindDevice(w, req, token, false)
+	case "registries.create":
+		if checkProjectToken(w, token) {
+			s.createRegistry(w, req, first(query, "parent"))
+		}
+	case "registries.list":
+		if checkProjectToken(w, token) {
+			s.listRegistries(w, query)
+		}
+	case "registries.get":
+		if _, r, ok := s.tokenRegistry(w, token); ok {
+			writeJSON(w, r.registry)
+		}
+	case "registries.patch":
+		s.patchRegistry(w, req, query, token)
+	case "registries.delete":
+		if checkProjectToken(w, token) {
+			s.deleteRegistry(w, first(query, "name"))
+		}
+	case "registries.devices.modifyCloudToDeviceConfig":
+		s.modifyConfig(w, req, query, token)
+	case "registries.devices.sendCommandToDevice":
+		s.sendCommand(w, req, query, token)
+	case "registries.devices.create":
+		s.createDevice(w, req, token)
+	case "registries.devices.get":
+		if d, ok := s.requestDevice(w, query, token); ok {
+			writeJSON(w, d.device)
+		}
+	case "registries.devices.list":
+		s.listDevices(w, query, token)
+	case "registries.devices.patch":
+		s.patchDevice(w, req, query, token)
+	case "registries.devices.delete":
+		s.deleteDevice(w, query, token)
+	case "registries.devices.configVersions.list":
+		s.listConfigVersions(w, query, token)
+	case "registries.devices.states.list":
+		s.listStates(w, query, token)
+	}
+}
+
+// operation returns the API method a request is made for, named as by
+// RequestCount.
+func operation(req *http.Request) (string, bool) {
+	if strings.HasSuffix(req.URL.Path, "/getRegistryCredentials") {
+		return "getRegistryCredentials", req.Method == http.MethodPost
+	}
+
+	segments := strings.Split(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if len(segments) != 7 || segments[0] != "api" || segments[4] != "execute" {
+		return "", false
+	}
+
+	query := req.URL.Query()
+	method := first(query, "method")
+	switch segments[6] {
+	case "cloudiot":
+		switch {
+		case req.Method == http.MethodPost && method == "bindDeviceToGateway":
+			return "registries.bindDeviceToGateway", true
+		case req.Method == http.MethodPost && method == "unbindDeviceFromGateway":
+			return "registries.unbindDeviceFromGateway", true
+		case req.Method == http.MethodPost:
+			return "registries.create", true
+		case req.Method == http.MethodGet && first(query, "parent") != "":
+			return "registries.list", true
+		case req.Method == http.MethodGet:
+			return "registries.get", true
+		case req.Method == http.MethodPatch:
+			return "registries.patch", true
+		case req.Method == http.MethodDelete:
+			return "registries.delete", true
+		}
+	case "cloudiot_devices":
+		switch {
+		case req.Method == http.MethodPost && method == "modifyCloudToDeviceConfig":
+			return "registries.devices.modifyCloudToDeviceConfig", true
+		case req.Method == http.MethodPost && method == "sendCommandToDevice":
+			return "registries.devices.sendCommandToDevice", true
+		case req.Method == http.MethodPost:
+			return "registries.devices.create", true
+		case req.Method == http.MethodGet && first(query, "name") != "":
+			return "registries.devices.get", true
+		case req.Method == http.MethodGet:
+			return "registries.devices.list", true
+		case req.Method == http.MethodPatch:
+			return "registries.devices.patch", true
+		case req.Method == http.MethodDelete:
+			return "registries.devices.delete", true
+		}
+	case "cloudiot_devices_configVersions":
+		return "registries.devices.configVersions.list", req.Method == http.MethodGet
+	case "cloudiot_devices_states":
+		return "registries.devices.states.list", req.Method == http.MethodGet
+	}
+	return "", false
+}
+
+// resourcePath returns the name of the resource a request addresses: the
+// device or registry it names, the parent it lists or creates in, or else the
+// registry of its token.
+func (s *Server) resourcePath(req *http.Request) string {
+	query := req.URL.Query()
+	if name := first(query, "name"); name != "" {
+		return name
+	}
+	if parent := first(query, "parent"); parent != "" {
+		return parent
+	}
+	return s.tokens[req.Header.Get("ClearBlade-UserToken")]
+}
+
+// registryCredentials issues a token per registry. Registry-scoped requests
+// are authenticated with it, which is how the fake knows their registry.
+func (s *Server) registryCredentials(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("ClearBlade-UserToken") != projectToken {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "invalid service account token")
+		return
+	}
+	var body struct {
+		Project  string `json:"project"`
+		Region   string `json:"region"`
+		Registry string `json:"registry"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+
+	name := fmt.Sprintf("projects/%s/locations/%s/registries/%s", body.Project, body.Region, body.Registry)
+	token := "registry-token-" + body.Region + "-" + body.Registry
+	s.tokens[token] = name
+	writeJSON(w, iot.RegistryUserCredentials{SystemKey: "registry-system-key", Token: token, Url: s.URL})
+}
+
+// tokenRegistry returns the registry a registry token was issued for.
+func (s *Server) tokenRegistry(w http.ResponseWriter, token string) (string, *registry, bool) {
+	name, ok := s.tokens[token]
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "invalid registry token")
+		return "", nil, false
+	}
+	r, ok := s.registries[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The device registry %s does not exist.", name)
+		return "", nil, false
+	}
+	return name, r, true
+}
+
+func (s *Server) createRegistry(w http.ResponseWriter, req *http.Request, parent string) {
+	matches := locationPattern.FindStringSubmatch(parent)
+	if matches == nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid parent %q", parent)
+		return
+	}
+	if matches[1] != Project {
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "permission denied on project %s", matches[1])
+		return
+	}
+
+	var body iot.DeviceRegistry
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	if !resourceIDPattern.MatchString(body.Id) {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid registry ID %q.", body.Id)
+		return
+	}
+	name := parent + "/registries/" + body.Id
+	if _, ok := s.registries[name]; ok {
+		writeError(w, http.StatusConflict, "ALREADY_EXISTS", "The device registry %s already exists.", name)
+		return
+	}
+
+	stored := copyRegistry(&body)
+	stored.Name = name
+	defaultRegistry(stored)
+	s.registries[name] = &registry{registry: stored, devices: map[string]*device{}}
+	writeJSON(w, stored)
+}
+
+func (s *Server) listRegistries(w http.ResponseWriter, query map[string][]string) {
+	parent := first(query, "parent")
+	names := []string{}
+	for name := range s.registries {
+		if strings.HasPrefix(name, parent+"/registries/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, end, next, ok := page(w, query, len(names))
+	if !ok {
+		return
+	}
+	registries := []*iot.DeviceRegistry{}
+	for _, name := range names[start:end] {
+		registries = append(registries, s.registries[name].registry)
+	}
+	// The client requires nextPageToken to be present, even on the last page.
+	writeJSON(w, map[string]any{"deviceRegistries": registries, "nextPageToken": next})
+}
+
+func (s *Server) patchRegistry(w http.ResponseWriter, req *http.Request, query map[string][]string, token string) {
+	_, r, ok := s.tokenRegistry(w, token)
+	if !ok {
+		return
+	}
+	paths, ok := updateMask(w, first(query, "updateMask"), mutableRegistryFields, true)
+	if !ok {
+		return
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	var updated iot.DeviceRegistry
+	if err := applyMask(r.registry, body, paths, &updated); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	defaultRegistry(&updated)
+	r.registry = &updated
+	writeJSON(w, r.registry)
+}
+
+func (s *Server) deleteRegistry(w http.ResponseWriter, name string) {
+	r, ok := s.registries[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The device registry %s does not exist.", name)
+		return
+	}
+	if len(r.devices) > 0 {
+		writeError(w, http.StatusBadRequest, "FAILED_PRECONDITION", "The device registry %s is not empty.", name)
+		return
+	}
+	delete(s.registries, name)
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) bindDevice(w http.ResponseWriter, req *http.Request, token string, bind bool) {
+	_, r, ok := s.tokenRegistry(w, token)
+	if !ok {
+		return
+	}
+	var body iot.BindDeviceToGatewayRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+
+	gateway := r.device(body.GatewayId)
+	if gateway == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The gateway %s does not exist.", body.GatewayId)
+		return
+	}
+	d := r.device(body.DeviceId)
+	if d == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The device %s does not exist.", body.DeviceId)
+		return
+	}
+	if !isGateway(gateway.device) {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "The device %s is not a gateway.", gateway.device.Id)
+		return
+	}
+	if isGateway(d.device) {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "The gateway %s cannot be bound to another gateway.", d.device.Id)
+		return
+	}
+
+	if bind {
+		gateway.bound[d.device.Id] = true
+	} else {
+		if !gateway.bound[d.device.Id] {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "The device %s is not bound to the gateway %s.", d.device.Id, gateway.device.Id)
+			return
+		}
+		delete(gateway.bound, d.device.Id)
+	}
+	writeJSON(w, struct{}{})
+}
+
+// requestDevice returns the device named by the name query parameter, which
+// must belong to the registry of the token.
+func (s *Server) requestDevice(w http.ResponseWriter, query map[string][]string, token string) (*device, bool) {
+	registryName, r, ok := s.tokenRegistry(w, token)
+	if !ok {
+		return nil, false
+	}
+	name := first(query, "name")
+	matches := devicePattern.FindStringSubmatch(name)
+	if matches == nil || matches[1] != registryName {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid device name %q", name)
+		return nil, false
+	}
+	d := r.device(matches[2])
+	if d == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The device %s does not exist.", name)
+		return nil, false
+	}
+	return d, true
+}
+
+func (s *Server) createDevice(w http.ResponseWriter, req *http.Request, token string) {
+	registryName, r, ok := s.tokenRegistry(w, token)
+	if !ok {
+		return
+	}
+	var body iot.Device
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	if !resourceIDPattern.MatchString(body.Id) {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid device ID %q.", body.Id)
+		return
+	}
+	if _, ok := r.devices[body.Id]; ok {
+		writeError(w, http.StatusConflict, "ALREADY_EXISTS", "The device %s/devices/%s already exists.", registryName, body.Id)
+		return
+	}
+
+	d := s.newDevice(registryName, &body)
+	r.devices[body.Id] = d
+	writeJSON(w, d.device)
+}
+
+func (s *Server) listDevices(w http.ResponseWriter, query map[string][]string, token string) {
+	registryName, r, ok := s.tokenRegistry(w, token)
+	if !ok {
+		return
+	}
+	if parent := first(query, "parent"); parent != "" && parent != registryName {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid parent %q", parent)
+		return
+	}
+
+	ids := setOf(query["deviceIds"])
+	numIDs := setOf(query["deviceNumIds"])
+	gatewayType := first(query, "gatewayListOptions.gatewayType")
+	associatedGateway := first(query, "gatewayListOptions.associationsGatewayId")
+	associatedDevice := first(query, "gatewayListOptions.associationsDeviceId")
+
+	var gateway *device
+	if associatedGateway != "" {
+		if gateway = r.device(associatedGateway); gateway == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "The gateway %s does not exist.", associatedGateway)
+			return
+		}
+	}
+	var associated *device
+	if associatedDevice != "" {
+		if associated = r.device(associatedDevice); associated == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "The device %s does not exist.", associatedDevice)
+			return
+		}
+	}
+
+	matching := []*device{}
+	for _, id := range sortedKeys(r.devices) {
+		d := r.devices[id]
+		switch {
+		case len(ids) > 0 && !ids[d.device.Id]:
+			continue
+		case len(numIDs) > 0 && !numIDs[strconv.FormatUint(d.device.NumId, 10)]:
+			continue
+		case gatewayType == "GATEWAY" && !isGateway(d.device):
+			continue
+		case gatewayType == "NON_GATEWAY" && isGateway(d.device):
+			continue
+		case gateway != nil && !gateway.bound[d.device.Id]:
+			continue
+		case associated != nil && !d.bound[associated.device.Id]:
+			continue
+		}
+		matching = append(matching, d)
+	}
+
+	start, end, next, ok := page(w, query, len(matching))
+	if !ok {
+		return
+	}
+	mask := fieldMask(first(query, "fieldMask"))
+	devices := []map[string]any{}
+	for _, d := range matching[start:end] {
+		devices = append(devices, maskDevice(d.device, mask))
+	}
+	writeJSON(w, map[string]any{"devices": devices, "nextPageToken": next})
+}
+
+func (s *Server) patchDevice(w http.ResponseWriter, req *http.Request, query map[string][]string, token string) {
+	d, ok := s.requestDevice(w, query, token)
+	if !ok {
+		return
+	}
+	paths, ok := updateMask(w, first(query, "updateMask"), mutableDeviceFields, false)
+	if !ok {
+		return
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	var updated iot.Device
+	if err := applyMask(d.device, body, paths, &updated); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	defaultCredentials(updated.Credentials)
+	d.device = &updated
+	writeJSON(w, d.device)
+}
+
+func (s *Server) deleteDevice(w http.ResponseWriter, query map[string][]string, token string) {
+	d, ok := s.requestDevice(w, query, token)
+	if !ok {
+		return
+	}
+	if len(d.bound) > 0 {
+		writeError(w, http.StatusBadRequest, "FAILED_PRECONDITION", "The gateway %s has devices bound to it.", d.device.Id)
+		return
+	}
+	_, r, _ := s.tokenRegistry(w, token)
+	r.removeDevice(d.device.Id)
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) modifyConfig(w http.ResponseWriter, req *http.Request, query map[string][]string, token string) {
+	d, ok := s.requestDevice(w, query, token)
+	if !ok {
+		return
+	}
+	var body iot.ModifyCloudToDeviceConfigRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	current := d.configs[0].Version
+	if body.VersionToUpdate != 0 && body.VersionToUpdate != current {
+		writeError(w, http.StatusConflict, "ABORTED", "The config version %d does not match the current version %d.", body.VersionToUpdate, current)
+		return
+	}
+
+	config := &iot.DeviceConfig{Version: current + 1, CloudUpdateTime: s.timestamp(), BinaryData: body.BinaryData}
+	d.configs = append([]*iot.DeviceConfig{config}, d.configs...)
+	if len(d.configs) > maxConfigVersions {
+		d.configs = d.configs[:maxConfigVersions]
+	}
+	d.device.Config = config
+	d.device.LastConfigSendTime = config.CloudUpdateTime
+	writeJSON(w, config)
+}
+
+func (s *Server) sendCommand(w http.ResponseWriter, req *http.Request, query map[string][]string, token string) {
+	d, ok := s.requestDevice(w, query, token)
+	if !ok {
+		return
+	}
+	var body iot.SendCommandToDeviceRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: %s", err)
+		return
+	}
+	if body.BinaryData == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "binaryData is required.")
+		return
+	}
+	d.commands = append(d.commands, &body)
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) listConfigVersions(w http.ResponseWriter, query map[string][]string, token string) {
+	d, ok := s.requestDevice(w, query, token)
+	if !ok {
+		return
+	}
+	configs := d.configs
+	if n, err := strconv.Atoi(first(query, "numVersions")); err == nil && n > 0 && n < len(configs) {
+		configs = configs[:n]
+	}
+	writeJSON(w, iot.ListDeviceConfigVersionsResponse{DeviceConfigs: configs})
+}
+
+func (s *Server) listStates(w http.ResponseWriter, query map[string][]string, token string) {
+	d, ok := s.requestDevice(w, query, token)
+	if !ok {
+		return
+	}
+	states := d.states
+	if n, err := strconv.Atoi(first(query, "numStates")); err == nil && n > 0 && n < len(states) {
+		states = states[:n]
+	}
+	writeJSON(w, iot.ListDeviceStatesResponse{DeviceStates: states})
+}
+
+// newDevice stores a copy of d with its server-set fields populated.
+func (s *Server) newDevice(registryName string, d *iot.Device) *device {
+	stored := copyDevice(d)
+	s.nextNumID++
+	stored.NumId = s.nextNumID
+	stored.Name = registryName + "/devices/" + d.Id
+	if stored.GatewayConfig == nil {
+		stored.GatewayConfig = &iot.GatewayConfig{}
+	}
+	if stored.GatewayConfig.GatewayType == "" {
+		stored.GatewayConfig.GatewayType = "NON_GATEWAY"
+	}
+	if stored.State == nil {
+		stored.State = &iot.DeviceState{}
+	}
+	if stored.LastErrorStatus == nil {
+		stored.LastErrorStatus = &iot.Status{}
+	}
+	defaultCredentials(stored.Credentials)
+
+	config := &iot.DeviceConfig{Version: 1, CloudUpdateTime: s.timestamp()}
+	if stored.Config != nil {
+		config.BinaryData = stored.Config.BinaryData
+	}
+	stored.Config = config
+
+	return &device{device: stored, configs: []*iot.DeviceConfig{config}, bound: map[string]bool{}}
+}
+
+// lookupDevice returns the device name and its registry.
+func (s *Server) lookupDevice(name string) (*registry, *device) {
+	matches := devicePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return nil, nil
+	}
+	r, ok := s.registries[matches[1]]
+	if !ok {
+		return nil, nil
+	}
+	return r, r.device(matches[2])
+}
+
+func (s *Server) timestamp() string {
+	return s.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// device returns the device with the user-defined or numeric ID id.
+func (r *registry) device(id string) *device {
+	if d, ok := r.devices[id]; ok {
+		return d
+	}
+	for _, d := range r.devices {
+		if strconv.FormatUint(d.device.NumId, 10) == id {
+			return d
+		}
+	}
+	return nil
+}
+
+// removeDevice deletes the device id and its gateway associations.
+func (r *registry) removeDevice(id string) {
+	delete(r.devices, id)
+	for _, d := range r.devices {
+		delete(d.bound, id)
+	}
+}
+
+func isGateway(d *iot.Device) bool {
+	return d.GatewayConfig != nil && d.GatewayConfig.GatewayType == "GATEWAY"
+}
+
+// defaultRegistry fills in the fields the API defaults.
+func defaultRegistry(r *iot.DeviceRegistry) {
+	if r.MqttConfig == nil {
+		r.MqttConfig = &iot.MqttConfig{}
+	}
+	if r.MqttConfig.MqttEnabledState == "" {
+		r.MqttConfig.MqttEnabledState = "MQTT_ENABLED"
+	}
+	if r.HttpConfig == nil {
+		r.HttpConfig = &iot.HttpConfig{}
+	}
+	if r.HttpConfig.HttpEnabledState == "" {
+		r.HttpConfig.HttpEnabledState = "HTTP_ENABLED"
+	}
+	if r.StateNotificationConfig == nil {
+		r.StateNotificationConfig = &iot.StateNotificationConfig{}
+	}
+	if r.EventNotificationConfigs == nil {
+		r.EventNotificationConfigs = []*iot.EventNotificationConfig{}
+	}
+}
+
+// defaultCredentials sets the expiration of credentials that never expire to
+// the Unix epoch, as the API does.
+func defaultCredentials(credentials []*iot.DeviceCredential) {
+	for _, credential := range credentials {
+		if credential.ExpirationTime == "" {
+			credential.ExpirationTime = time.Unix(0, 0).UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// updateMask validates an update mask against the mutable fields and returns
+// its paths in camel case. With topLevel, a path is allowed if its first
+// segment is a mutable field.
+func updateMask(w http.ResponseWriter, mask string, mutable []string, topLevel bool) ([]string, bool) {
+	fail := func() ([]string, bool) {
+		allowed, _ := json.Marshal(mutable)
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+			"The field mask 'updateMask' must contain mutable fields. The following fields are mutable: %s", allowed)
+		return nil, false
+	}
+
+	paths := fieldMask(mask)
+	if len(paths) == 0 {
+		return fail()
+	}
+	for i, path := range paths {
+		if topLevel {
+			path, _, _ = strings.Cut(path, ".")
+			paths[i] = path
+		}
+		if !contains(mutable, path) {
+			return fail()
+		}
+	}
+	return paths, true
+}
+
+// fieldMask splits a comma separated field mask into camel case paths.
+func fieldMask(mask string) []string {
+	paths := []string{}
+	for _, path := range strings.Split(mask, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		segments := strings.Split(path, ".")
+		for i, segment := range segments {
+			segments[i] = camelCase(segment)
+		}
+		paths = append(paths, strings.Join(segments, "."))
+	}
+	return paths
+}
+
+// applyMask copies the fields at paths from patch onto current and decodes the
+// result into out. Fields at paths missing from patch are cleared.
+func applyMask(current any, patch map[string]any, paths []string, out any) error {
+	fields, err := toMap(current)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		target, source := fields, patch
+		segments := strings.Split(path, ".")
+		for _, segment := range segments[:len(segments)-1] {
+			next, _ := target[segment].(map[string]any)
+			if next == nil {
+				next = map[string]any{}
+				target[segment] = next
+			}
+			target = next
+			source, _ = source[segment].(map[string]any)
+		}
+
+		last := segments[len(segments)-1]
+		if value, ok := source[last]; ok {
+			target[last] = value
+		} else {
+			delete(target, last)
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// maskDevice returns the JSON fields of d that a list returns for the field
+// mask. The ID, name and numeric ID are always returned.
+func maskDevice(d *iot.Device, mask []string) map[string]any {
+	fields, _ := toMap(d)
+	masked := map[string]any{}
+	for _, key := range []string{"id", "name", "numId"} {
+		if value, ok := fields[key]; ok {
+			masked[key] = value
+		}
+	}
+	for _, path := range mask {
+		key, _, _ := strings.Cut(path, ".")
+		if value, ok := fields[key]; ok {
+			masked[key] = value
+		}
+	}
+	return masked
+}
+
+// page returns the bounds of the page requested by the pageSize and
+// pageToken parameters and the token of the next page, which is empty on the
+// last page.
+func page(w http.ResponseWriter, query map[string][]string, total int) (int, int, string, bool) {
+	size := DefaultPageSize
+	if raw := first(query, "pageSize"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid page size %q", raw)
+			return 0, 0, "", false
+		}
+		if n > 0 {
+			size = n
+		}
+	}
+
+	start := 0
+	if raw := first(query, "pageToken"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > total {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid page token %q", raw)
+			return 0, 0, "", false
+		}
+		start = n
+	}
+
+	end := start + size
+	if end >= total {
+		return start, total, "", true
+	}
+	return start, end, strconv.Itoa(end), true
+}
+
+func checkProjectToken(w http.ResponseWriter, token string) bool {
+	if token != projectToken {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "invalid service account token")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format of Google API errors, which the
+// client decodes into a *googleapi.Error.
+func writeError(w http.ResponseWriter, code int, status, format string, args ...any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": fmt.Sprintf(format, args...),
+			"status":  status,
+		},
+	})
+}
+
+func copyRegistry(r *iot.DeviceRegistry) *iot.DeviceRegistry {
+	var c iot.DeviceRegistry
+	roundTrip(r, &c)
+	return &c
+}
+
+func copyDevice(d *iot.Device) *iot.Device {
+	var c iot.Device
+	roundTrip(d, &c)
+	return &c
+}
+
+func roundTrip(in, out any) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		panic(err)
+	}
+}
+
+func toMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+func camelCase(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func first(query map[string][]string, key string) string {
+	if values := query[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func setOf(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(devices map[string]*device) []string {
+	keys := make([]string, 0, len(devices))
+	for key := range devices {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
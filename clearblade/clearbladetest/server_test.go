@@ -0,0 +1,297 @@
+package clearbladetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	location     = "projects/test-project/locations/us-central1"
+	registryName = location + "/registries/test-registry"
+)
+
+func newTestService(t *testing.T) (*Server, *iot.Service) {
+	t.Helper()
+
+	server := NewServer()
+	t.Cleanup(server.Close)
+	client, err := server.NewService(context.Background())
+	if err != nil {
+		t.Fatalf("creating client: %s", err)
+	}
+	return server, client
+}
+
+func wantStatus(t *testing.T, err error, code int) {
+	t.Helper()
+
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want HTTP %d", err, code)
+	}
+	if apiErr.Code != code {
+		t.Fatalf("got HTTP %d (%s), want HTTP %d", apiErr.Code, apiErr.Message, code)
+	}
+}
+
+func TestRegistryLifecycle(t *testing.T) {
+	_, client := newTestService(t)
+	registries := client.Projects.Locations.Registries
+
+	created, err := registries.Create(location, &iot.DeviceRegistry{Id: "test-registry", LogLevel: "INFO"}).Do()
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if created.Name != registryName || created.MqttConfig.MqttEnabledState != "MQTT_ENABLED" {
+		t.Errorf("created %+v", created)
+	}
+
+	_, err = registries.Create(location, &iot.DeviceRegistry{Id: "test-registry"}).Do()
+	wantStatus(t, err, http.StatusConflict)
+	_, err = registries.Create(location, &iot.DeviceRegistry{Id: "1-invalid"}).Do()
+	wantStatus(t, err, http.StatusBadRequest)
+
+	patched, err := registries.Patch(registryName, &iot.DeviceRegistry{
+		LogLevel:   "DEBUG",
+		HttpConfig: &iot.HttpConfig{HttpEnabledState: "HTTP_DISABLED"},
+	}).UpdateMask("httpConfig.http_enabled_state,logLevel").Do()
+	if err != nil {
+		t.Fatalf("patch: %s", err)
+	}
+	if patched.LogLevel != "DEBUG" || patched.HttpConfig.HttpEnabledState != "HTTP_DISABLED" {
+		t.Errorf("patched %+v", patched)
+	}
+
+	_, err = registries.Patch(registryName, &iot.DeviceRegistry{Id: "renamed"}).UpdateMask("id").Do()
+	wantStatus(t, err, http.StatusBadRequest)
+	_, err = registries.Patch(registryName, &iot.DeviceRegistry{}).Do()
+	wantStatus(t, err, http.StatusBadRequest)
+
+	got, err := registries.Get(registryName).Do()
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if got.LogLevel != "DEBUG" {
+		t.Errorf("got log level %q, want DEBUG", got.LogLevel)
+	}
+
+	if _, err := registries.Delete(registryName).Do(); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	_, err = registries.Get(registryName).Do()
+	wantStatus(t, err, http.StatusNotFound)
+	_, err = registries.Delete(registryName).Do()
+	wantStatus(t, err, http.StatusNotFound)
+}
+
+func TestDeviceLifecycle(t *testing.T) {
+	server, client := newTestService(t)
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	devices := client.Projects.Locations.Registries.Devices
+	deviceName := registryName + "/devices/test-device"
+
+	created, err := devices.Create(registryName, &iot.Device{
+		Id:          "test-device",
+		Credentials: []*iot.DeviceCredential{{PublicKey: &iot.PublicKeyCredential{Format: "RSA_PEM", Key: "key"}}},
+		Metadata:    map[string]string{"site": "a"},
+	}).Do()
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if created.Name != deviceName || created.NumId == 0 || created.Config.Version != 1 {
+		t.Errorf("created %+v", created)
+	}
+	if created.Credentials[0].ExpirationTime != "1970-01-01T00:00:00Z" {
+		t.Errorf("got credential expiration %q, want the Unix epoch", created.Credentials[0].ExpirationTime)
+	}
+
+	_, err = devices.Create(registryName, &iot.Device{Id: "test-device"}).Do()
+	wantStatus(t, err, http.StatusConflict)
+
+	patched, err := devices.Patch(deviceName, &iot.Device{Blocked: true, LogLevel: "ERROR"}).UpdateMask("blocked,logLevel").Do()
+	if err != nil {
+		t.Fatalf("patch: %s", err)
+	}
+	if !patched.Blocked || patched.LogLevel != "ERROR" || patched.Metadata["site"] != "a" {
+		t.Errorf("patched %+v", patched)
+	}
+	_, err = devices.Patch(deviceName, &iot.Device{}).UpdateMask("blocked,gatewayConfig.gatewayType").Do()
+	wantStatus(t, err, http.StatusBadRequest)
+
+	byNumID, err := devices.Get(fmt.Sprintf("%s/devices/%d", registryName, created.NumId)).Do()
+	if err != nil {
+		t.Fatalf("get by numeric ID: %s", err)
+	}
+	if byNumID.Id != "test-device" {
+		t.Errorf("got device %q, want test-device", byNumID.Id)
+	}
+
+	_, err = client.Projects.Locations.Registries.Delete(registryName).Do()
+	wantStatus(t, err, http.StatusBadRequest)
+
+	if _, err := devices.Delete(deviceName).Do(); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	_, err = devices.Get(deviceName).Do()
+	wantStatus(t, err, http.StatusNotFound)
+	if _, ok := server.Device(deviceName); ok {
+		t.Error("device still stored after delete")
+	}
+}
+
+func TestDeviceListPagination(t *testing.T) {
+	server, client := newTestService(t)
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	for i := 0; i < 25; i++ {
+		if err := server.PutDevice(registryName, &iot.Device{Id: fmt.Sprintf("device-%02d", i), Blocked: i%2 == 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	pageToken := ""
+	for {
+		resp, err := client.Projects.Locations.Registries.Devices.List(registryName).PageSize(10).PageToken(pageToken).Do()
+		if err != nil {
+			t.Fatalf("list: %s", err)
+		}
+		for _, device := range resp.Devices {
+			ids = append(ids, device.Id)
+			if device.Blocked {
+				t.Errorf("device %s returned blocked without a field mask", device.Id)
+			}
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+	if len(ids) != 25 || ids[0] != "device-00" || ids[24] != "device-24" {
+		t.Errorf("listed %v", ids)
+	}
+	if n := server.RequestCount("registries.devices.list"); n != 3 {
+		t.Errorf("made %d list requests, want 3", n)
+	}
+
+	resp, err := client.Projects.Locations.Registries.Devices.List(registryName).DeviceIds("device-00", "device-01").FieldMask("blocked").Do()
+	if err != nil {
+		t.Fatalf("list by ID: %s", err)
+	}
+	if len(resp.Devices) != 2 || !resp.Devices[0].Blocked {
+		t.Errorf("listed %+v", resp.Devices)
+	}
+}
+
+func TestRegistryListPagination(t *testing.T) {
+	server, client := newTestService(t)
+	for i := 0; i < 5; i++ {
+		server.PutRegistry(location, &iot.DeviceRegistry{Id: fmt.Sprintf("registry-%d", i)})
+	}
+
+	resp, err := client.Projects.Locations.Registries.List(location).PageSize(3).Do()
+	if err != nil {
+		t.Fatalf("list: %s", err)
+	}
+	if len(resp.DeviceRegistries) != 3 || resp.NextPageToken == "" {
+		t.Fatalf("first page has %d registries and token %q", len(resp.DeviceRegistries), resp.NextPageToken)
+	}
+	resp, err = client.Projects.Locations.Registries.List(location).PageSize(3).PageToken(resp.NextPageToken).Do()
+	if err != nil {
+		t.Fatalf("list: %s", err)
+	}
+	if len(resp.DeviceRegistries) != 2 || resp.NextPageToken != "" {
+		t.Errorf("last page has %d registries and token %q", len(resp.DeviceRegistries), resp.NextPageToken)
+	}
+}
+
+func TestGatewayBinding(t *testing.T) {
+	server, client := newTestService(t)
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	_ = server.PutDevice(registryName, &iot.Device{Id: "gateway", GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY"}})
+	_ = server.PutDevice(registryName, &iot.Device{Id: "sensor"})
+	registries := client.Projects.Locations.Registries
+
+	_, err := registries.BindDeviceToGateway(registryName, &iot.BindDeviceToGatewayRequest{GatewayId: "sensor", DeviceId: "gateway"}).Do()
+	wantStatus(t, err, http.StatusBadRequest)
+	_, err = registries.BindDeviceToGateway(registryName, &iot.BindDeviceToGatewayRequest{GatewayId: "gateway", DeviceId: "missing"}).Do()
+	wantStatus(t, err, http.StatusNotFound)
+
+	if _, err := registries.BindDeviceToGateway(registryName, &iot.BindDeviceToGatewayRequest{GatewayId: "gateway", DeviceId: "sensor"}).Do(); err != nil {
+		t.Fatalf("bind: %s", err)
+	}
+	bound, err := registries.Devices.List(registryName).GatewayListOptionsAssociationsGatewayId("gateway").Do()
+	if err != nil {
+		t.Fatalf("list bound devices: %s", err)
+	}
+	if len(bound.Devices) != 1 || bound.Devices[0].Id != "sensor" {
+		t.Errorf("bound devices %+v", bound.Devices)
+	}
+
+	_, err = registries.Devices.Delete(registryName + "/devices/gateway").Do()
+	wantStatus(t, err, http.StatusBadRequest)
+
+	if _, err := registries.UnbindDeviceFromGateway(registryName, &iot.UnbindDeviceFromGatewayRequest{GatewayId: "gateway", DeviceId: "sensor"}).Do(); err != nil {
+		t.Fatalf("unbind: %s", err)
+	}
+	_, err = registries.UnbindDeviceFromGateway(registryName, &iot.UnbindDeviceFromGatewayRequest{GatewayId: "gateway", DeviceId: "sensor"}).Do()
+	wantStatus(t, err, http.StatusNotFound)
+	if got := server.BoundDevices(registryName + "/devices/gateway"); len(got) != 0 {
+		t.Errorf("still bound: %v", got)
+	}
+}
+
+func TestConfigVersionsStatesAndCommands(t *testing.T) {
+	server, client := newTestService(t)
+	server.PutRegistry(location, &iot.DeviceRegistry{Id: "test-registry"})
+	_ = server.PutDevice(registryName, &iot.Device{Id: "test-device"})
+	deviceName := registryName + "/devices/test-device"
+	devices := client.Projects.Locations.Registries.Devices
+
+	config, err := devices.ModifyCloudToDeviceConfig(deviceName, &iot.ModifyCloudToDeviceConfigRequest{BinaryData: "djI="}).Do()
+	if err != nil {
+		t.Fatalf("modify config: %s", err)
+	}
+	if config.Version != 2 {
+		t.Errorf("got config version %d, want 2", config.Version)
+	}
+	_, err = devices.ModifyCloudToDeviceConfig(deviceName, &iot.ModifyCloudToDeviceConfigRequest{BinaryData: "djM=", VersionToUpdate: 1}).Do()
+	wantStatus(t, err, http.StatusConflict)
+
+	versions, err := devices.ConfigVersions.List(deviceName).Do()
+	if err != nil {
+		t.Fatalf("list config versions: %s", err)
+	}
+	if len(versions.DeviceConfigs) != 2 || versions.DeviceConfigs[0].Version != 2 {
+		t.Errorf("config versions %+v", versions.DeviceConfigs)
+	}
+
+	for _, state := range []string{"one", "two", "three"} {
+		if err := server.AddDeviceState(deviceName, []byte(state)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	states, err := devices.States.List(deviceName).NumStates(2).Do()
+	if err != nil {
+		t.Fatalf("list states: %s", err)
+	}
+	if len(states.DeviceStates) != 2 || states.DeviceStates[0].BinaryData != "dGhyZWU=" {
+		t.Errorf("states %+v", states.DeviceStates)
+	}
+
+	if _, err := devices.SendCommandToDevice(deviceName, &iot.SendCommandToDeviceRequest{BinaryData: "cmVib290", Subfolder: "ops"}).Do(); err != nil {
+		t.Fatalf("send command: %s", err)
+	}
+	commands := server.Commands(deviceName)
+	if len(commands) != 1 || commands[0].Subfolder != "ops" {
+		t.Errorf("commands %+v", commands)
+	}
+
+	_, err = devices.States.List(registryName + "/devices/missing").Do()
+	wantStatus(t, err, http.StatusNotFound)
+}
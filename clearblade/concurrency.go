@@ -0,0 +1,84 @@
+package clearblade
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// defaultConcurrency is the number of parallel API calls used when fanning
+// out requests and no other limit is configured.
+const defaultConcurrency = 8
+
+// runBounded calls fn for every index in [0, n) using at most limit
+// goroutines. It returns the error of each call by index. Calls that have not
+// started when ctx is done are not made and report ctx.Err().
+func runBounded(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return errs
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// primeRegistryCredentials fetches and caches the credentials of each registry
+// one at a time. The client's registry credential cache is not safe for
+// concurrent first use, so this must run before requests to those registries
+// are made from several goroutines.
+func primeRegistryCredentials(client *iot.Service, region string, registries ...string) error {
+	for _, registry := range registries {
+		if _, err := iot.GetRegistryCredentials(registry, region, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressLogger logs the progress of operations running in parallel, every
+// 100 completed operations and once all of them are.
+type progressLogger struct {
+	message   string
+	total     int
+	start     time.Time
+	mu        sync.Mutex
+	completed int
+}
+
+func newProgressLogger(message string, total int) *progressLogger {
+	return &progressLogger{message: message, total: total, start: time.Now()}
+}
+
+// done records a completed operation.
+func (p *progressLogger) done(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed++
+	if p.completed%100 == 0 || p.completed == p.total {
+		tflog.Info(ctx, p.message, map[string]any{"completed": p.completed, "total": p.total, "elapsed": time.Since(p.start).String()})
+	}
+}
@@ -0,0 +1,356 @@
+package clearblade
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"terraform-provider-clearblade/clearblade/resourcename"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// convertFiles are the files written by Convert.
+var convertFiles = []string{"registries.tf", "devices.tf"}
+
+// convertedRegistryFields are the fields of an exported Google Cloud IoT Core
+// registry that the registry resource sets, or that are output only. A field
+// covers the fields of its value, and [] the elements of a list.
+var convertedRegistryFields = map[string]bool{
+	"id":       true,
+	"name":     true,
+	"logLevel": true,
+	"eventNotificationConfigs[].pubsubTopicName":     true,
+	"eventNotificationConfigs[].subfolderMatches":    true,
+	"stateNotificationConfig.pubsubTopicName":        true,
+	"mqttConfig.mqttEnabledState":                    true,
+	"httpConfig.httpEnabledState":                    true,
+	"credentials[].publicKeyCertificate.format":      true,
+	"credentials[].publicKeyCertificate.certificate": true,
+	"credentials[].publicKeyCertificate.x509Details": true,
+}
+
+// convertedDeviceFields are the fields of an exported Google Cloud IoT Core
+// device that the device resource sets, or that are output only.
+var convertedDeviceFields = map[string]bool{
+	"id":                                    true,
+	"name":                                  true,
+	"numId":                                 true,
+	"blocked":                               true,
+	"logLevel":                              true,
+	"metadata":                              true,
+	"credentials[].expirationTime":          true,
+	"credentials[].publicKey.format":        true,
+	"credentials[].publicKey.key":           true,
+	"gatewayConfig.gatewayType":             true,
+	"gatewayConfig.gatewayAuthMethod":       true,
+	"gatewayConfig.lastAccessedGatewayId":   true,
+	"gatewayConfig.lastAccessedGatewayTime": true,
+	"lastHeartbeatTime":                     true,
+	"lastEventTime":                         true,
+	"lastStateTime":                         true,
+	"lastConfigAckTime":                     true,
+	"lastConfigSendTime":                    true,
+	"lastErrorTime":                         true,
+	"lastErrorStatus":                       true,
+	"state":                                 true,
+	"config.version":                        true,
+	"config.cloudUpdateTime":                true,
+	"config.deviceAckTime":                  true,
+}
+
+// Convert runs the convert command with args, the command line arguments
+// after "convert". It reads the JSON exports of Google Cloud IoT Core
+// registries and devices and writes the equivalent clearblade_iot_registry and
+// clearblade_iot_device resources to a directory, without calling any API.
+func Convert(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: terraform-provider-clearblade convert [flags] EXPORT...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Writes the configuration of the Google Cloud IoT Core registries and devices exported with")
+		fmt.Fprintln(stderr, "`gcloud iot registries describe --format=json` and `gcloud iot devices describe --format=json`,")
+		fmt.Fprintln(stderr, "or their list equivalents. An EXPORT of - reads standard input.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	out := flags.String("out", ".", "directory to write registries.tf and devices.tf to")
+	force := flags.Bool("force", false, "overwrite existing files")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing exports")
+	}
+	if !*force {
+		for _, name := range convertFiles {
+			if _, err := os.Stat(filepath.Join(*out, name)); err == nil {
+				return fmt.Errorf("%s already exists; use -force to overwrite it", filepath.Join(*out, name))
+			}
+		}
+	}
+
+	exports := make([]convertExport, flags.NArg())
+	for i, file := range flags.Args() {
+		var err error
+		exports[i].File = file
+		if file == "-" {
+			exports[i].Data, err = io.ReadAll(os.Stdin)
+		} else {
+			exports[i].Data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	files, summary, err := convertExports(exports)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	for _, name := range convertFiles {
+		if err := os.WriteFile(filepath.Join(*out, name), files[name], 0o644); err != nil {
+			return err
+		}
+	}
+
+	for _, warning := range summary.Warnings {
+		fmt.Fprintln(stderr, "Warning:", warning)
+	}
+	for _, field := range summary.Unsupported {
+		fmt.Fprintln(stderr, "Not converted:", field)
+	}
+	fmt.Fprintf(stdout, "Converted %d registries and %d devices to %s\n", summary.Registries, summary.Devices, *out)
+	return nil
+}
+
+// convertExport is the content of an export file.
+type convertExport struct {
+	File string
+	Data []byte
+}
+
+// convertSummary describes the configuration written by Convert.
+type convertSummary struct {
+	Registries int
+	Devices    int
+	// Unsupported lists the exported fields that have no ClearBlade
+	// equivalent, and so are not converted.
+	Unsupported []string
+	Warnings    []string
+}
+
+// exportedRegistry is a registry read from an export.
+type exportedRegistry struct {
+	location string
+	registry *iot.DeviceRegistry
+}
+
+// exportedDevice is a device read from an export.
+type exportedDevice struct {
+	location, registryID string
+	device               *iot.Device
+}
+
+// convertExports reads the registries and devices of exports and returns the
+// contents of each of convertFiles.
+func convertExports(exports []convertExport) (map[string][]byte, convertSummary, error) {
+	var summary convertSummary
+
+	registries := []exportedRegistry{}
+	devices := []exportedDevice{}
+	seen := map[string]string{}
+	locations := map[string]bool{}
+	for _, export := range exports {
+		resources, err := readExport(export.Data)
+		if err != nil {
+			return nil, summary, fmt.Errorf("%s: %w", export.File, err)
+		}
+		for i, raw := range resources {
+			var fields map[string]any
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return nil, summary, fmt.Errorf("%s: resource %d: %w", export.File, i+1, err)
+			}
+			name, _ := fields["name"].(string)
+			parts := strings.Split(name, "/")
+			if len(parts) != 6 && len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "registries" || len(parts) == 8 && parts[6] != "devices" {
+				return nil, summary, fmt.Errorf("%s: resource %d is neither a registry nor a device: its name is %q", export.File, i+1, name)
+			}
+			location := strings.Join(parts[:4], "/")
+			locations[location] = true
+
+			if len(parts) == 6 {
+				registry := &iot.DeviceRegistry{}
+				if err := json.Unmarshal(raw, registry); err != nil {
+					return nil, summary, fmt.Errorf("%s: registry %s: %w", export.File, name, err)
+				}
+				registry.Id = parts[5]
+				if file, ok := seen[name]; ok {
+					return nil, summary, fmt.Errorf("%s: registry %s is also exported in %s", export.File, registry.Id, file)
+				}
+				seen[name] = export.File
+				registries = append(registries, exportedRegistry{location: location, registry: registry})
+				for _, field := range unsupportedFields(fields, "", convertedRegistryFields) {
+					summary.Unsupported = append(summary.Unsupported, fmt.Sprintf("registry %s: %s", registry.Id, field))
+				}
+				continue
+			}
+
+			device := &iot.Device{}
+			if err := json.Unmarshal(raw, device); err != nil {
+				return nil, summary, fmt.Errorf("%s: device %s: %w", export.File, name, err)
+			}
+			// The names of Google Cloud IoT Core devices end with their
+			// numeric ID, so the ID is only in the id field.
+			if device.Id == "" {
+				return nil, summary, fmt.Errorf("%s: device %s has no id", export.File, name)
+			}
+			key := strings.Join(parts[:6], "/") + "/devices/" + device.Id
+			if file, ok := seen[key]; ok {
+				return nil, summary, fmt.Errorf("%s: device %s/%s is also exported in %s", export.File, parts[5], device.Id, file)
+			}
+			seen[key] = export.File
+			devices = append(devices, exportedDevice{location: location, registryID: parts[5], device: device})
+			for _, field := range unsupportedFields(fields, "", convertedDeviceFields) {
+				summary.Unsupported = append(summary.Unsupported, fmt.Sprintf("device %s/%s: %s", parts[5], device.Id, field))
+			}
+		}
+	}
+	if len(locations) > 1 {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("the exports are from %d projects or regions, but the provider manages the registries of one project and region", len(locations)))
+	}
+
+	sort.Slice(registries, func(i, j int) bool {
+		if registries[i].registry.Id != registries[j].registry.Id {
+			return registries[i].registry.Id < registries[j].registry.Id
+		}
+		return registries[i].location < registries[j].location
+	})
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].registryID != devices[j].registryID {
+			return devices[i].registryID < devices[j].registryID
+		}
+		if devices[i].device.Id != devices[j].device.Id {
+			return devices[i].device.Id < devices[j].device.Id
+		}
+		return devices[i].location < devices[j].location
+	})
+
+	names := resourcename.Names{}
+	registryNames := map[string]string{}
+	registriesFile := &hclFile{File: hclwrite.NewEmptyFile()}
+	devicesFile := &hclFile{File: hclwrite.NewEmptyFile()}
+	for _, exported := range registries {
+		name := names.Name("clearblade_iot_registry", exported.registry.Id)
+		registryNames[exported.location+"/registries/"+exported.registry.Id] = name
+		generateRegistry(registriesFile.appendBlock("resource", "clearblade_iot_registry", name), exported.registry)
+		summary.Registries++
+	}
+	for _, exported := range devices {
+		body := devicesFile.appendBlock("resource", "clearblade_iot_device", names.Name("clearblade_iot_device", exported.registryID+"_"+exported.device.Id))
+		body.SetAttributeValue("id", cty.StringVal(exported.device.Id))
+		// Devices of registries that were not exported refer to the
+		// registry by its ID.
+		if registryName, ok := registryNames[exported.location+"/registries/"+exported.registryID]; ok {
+			body.SetAttributeTraversal("registry", resourceIDTraversal("clearblade_iot_registry", registryName))
+		} else {
+			body.SetAttributeValue("registry", cty.StringVal(exported.registryID))
+		}
+		generateDevice(body, exported.device)
+		summary.Devices++
+	}
+
+	files := map[string][]byte{
+		"registries.tf": hclwrite.Format(registriesFile.Bytes()),
+		"devices.tf":    hclwrite.Format(devicesFile.Bytes()),
+	}
+	return files, summary, nil
+}
+
+// readExport returns the resources of an export, which holds JSON objects,
+// arrays of them as listed by gcloud, or both.
+func readExport(data []byte) ([]json.RawMessage, error) {
+	resources := []json.RawMessage{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch raw[0] {
+		case '{':
+			resources = append(resources, raw)
+		case '[':
+			var list []json.RawMessage
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, err
+			}
+			resources = append(resources, list...)
+		default:
+			return nil, fmt.Errorf("unexpected %s, want a JSON object or array", raw)
+		}
+	}
+}
+
+// unsupportedFields returns the paths below prefix of the fields of v that
+// are set but not among converted, in the form of the converted paths.
+func unsupportedFields(v any, prefix string, converted map[string]bool) []string {
+	if prefix != "" && converted[prefix] {
+		return nil
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fields := []string{}
+		for _, key := range keys {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			fields = append(fields, unsupportedFields(v[key], path, converted)...)
+		}
+		return fields
+	case []any:
+		fields := []string{}
+		seen := map[string]bool{}
+		for _, element := range v {
+			for _, field := range unsupportedFields(element, prefix+"[]", converted) {
+				if !seen[field] {
+					seen[field] = true
+					fields = append(fields, field)
+				}
+			}
+		}
+		return fields
+	case string:
+		if v == "" {
+			return nil
+		}
+	}
+	return []string{prefix}
+}
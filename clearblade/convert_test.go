@@ -0,0 +1,163 @@
+package clearblade
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+// testGoogleRegistryExport is a registry as described by gcloud iot registries
+// describe --format=json.
+const testGoogleRegistryExport = `{
+  "credentials": [],
+  "eventNotificationConfigs": [
+    {"pubsubTopicName": "projects/test-project/topics/events"}
+  ],
+  "httpConfig": {"httpEnabledState": "HTTP_DISABLED"},
+  "id": "bas-plant",
+  "logLevel": "INFO",
+  "mqttConfig": {"mqttEnabledState": "MQTT_ENABLED"},
+  "name": "projects/test-project/locations/us-central1/registries/bas-plant",
+  "stateNotificationConfig": {"pubsubTopicName": "projects/test-project/topics/states"}
+}`
+
+// testGoogleDevicesExport are devices as listed by gcloud iot devices list
+// --format=json, one of a registry that is not exported.
+const testGoogleDevicesExport = `[
+  {
+    "config": {"binaryData": "b24=", "cloudUpdateTime": "2023-01-01T00:00:00Z", "version": "3"},
+    "credentials": [
+      {"expirationTime": "1970-01-01T00:00:00Z", "publicKey": {"format": "ES256_PEM", "key": "key-data"}},
+      {"expirationTime": "2030-01-01T00:00:00Z", "publicKey": {"format": "RSA_PEM", "key": "other-key"}}
+    ],
+    "gatewayConfig": {"gatewayAuthMethod": "ASSOCIATION_ONLY", "gatewayType": "GATEWAY"},
+    "id": "gateway-1",
+    "lastHeartbeatTime": "2023-01-01T00:00:00Z",
+    "metadata": {"site": "north"},
+    "name": "projects/test-project/locations/us-central1/registries/bas-plant/devices/2817193",
+    "numId": "2817193",
+    "state": {"binaryData": "b2s=", "updateTime": "2023-01-01T00:00:00Z"}
+  },
+  {
+    "blocked": true,
+    "id": "probe-1",
+    "logLevel": "DEBUG",
+    "name": "projects/test-project/locations/us-central1/registries/lab/devices/2817194",
+    "numId": "2817194",
+    "resourceLabels": {"team": "lab"}
+  }
+]`
+
+func TestConvertExports(t *testing.T) {
+	files, summary, err := convertExports([]convertExport{
+		{File: "registry.json", Data: []byte(testGoogleRegistryExport)},
+		{File: "devices.json", Data: []byte(testGoogleDevicesExport)},
+	})
+	if err != nil {
+		t.Fatalf("convertExports: %s", err)
+	}
+	parser := hclparse.NewParser()
+	for _, name := range convertFiles {
+		if _, diags := parser.ParseHCL(files[name], name); diags.HasErrors() {
+			t.Fatalf("%s: %s\n%s", name, diags, files[name])
+		}
+	}
+
+	if summary.Registries != 1 || summary.Devices != 2 {
+		t.Errorf("converted %d registries and %d devices, want 1 and 2", summary.Registries, summary.Devices)
+	}
+	for _, want := range []struct{ file, content string }{
+		{"registries.tf", `resource "clearblade_iot_registry" "bas-plant"`},
+		{"registries.tf", `sub_folder_matches = ""`},
+		{"registries.tf", `mqtt_enabled_state = "MQTT_ENABLED"`},
+		{"devices.tf", `resource "clearblade_iot_device" "bas-plant_gateway-1"`},
+		{"devices.tf", `registry = clearblade_iot_registry.bas-plant.id`},
+		{"devices.tf", `expiration_time = "2030-01-01T00:00:00Z"`},
+		{"devices.tf", `gateway_auth_method = "ASSOCIATION_ONLY"`},
+		{"devices.tf", `registry  = "lab"`},
+		{"devices.tf", `blocked   = true`},
+	} {
+		if !strings.Contains(string(files[want.file]), want.content) {
+			t.Errorf("%s does not contain %q:\n%s", want.file, want.content, files[want.file])
+		}
+	}
+	for _, unwanted := range []string{"2817193", "1970-01-01", "b2s="} {
+		if strings.Contains(string(files["devices.tf"]), unwanted) {
+			t.Errorf("devices.tf contains %q:\n%s", unwanted, files["devices.tf"])
+		}
+	}
+
+	want := []string{
+		"device bas-plant/gateway-1: config.binaryData",
+		"device lab/probe-1: resourceLabels.team",
+	}
+	if strings.Join(summary.Unsupported, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unsupported fields %q, want %q", summary.Unsupported, want)
+	}
+	if len(summary.Warnings) != 0 {
+		t.Errorf("warnings %q, want none", summary.Warnings)
+	}
+}
+
+func TestConvertExportsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		exports []convertExport
+		want    string
+	}{
+		{
+			name:    "not JSON",
+			exports: []convertExport{{File: "registry.yaml", Data: []byte("id: bas-plant\n")}},
+			want:    "registry.yaml: invalid character",
+		},
+		{
+			name:    "other resource",
+			exports: []convertExport{{File: "topic.json", Data: []byte(`{"name": "projects/test-project/topics/events"}`)}},
+			want:    "topic.json: resource 1 is neither a registry nor a device",
+		},
+		{
+			name: "duplicate registry",
+			exports: []convertExport{
+				{File: "a.json", Data: []byte(testGoogleRegistryExport)},
+				{File: "b.json", Data: []byte(testGoogleRegistryExport)},
+			},
+			want: "b.json: registry bas-plant is also exported in a.json",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := convertExports(tc.exports)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestAccConvert_apply applies the converted configuration and checks that it
+// is stable, so the converted attributes are valid and read back unchanged.
+func TestAccConvert_apply(t *testing.T) {
+	server := newTestAccServer(t)
+	files, _, err := convertExports([]convertExport{
+		{File: "registry.json", Data: []byte(testGoogleRegistryExport)},
+		{File: "devices.json", Data: []byte(strings.Replace(testGoogleDevicesExport, "registries/lab/", "registries/bas-plant/", 1))},
+	})
+	if err != nil {
+		t.Fatalf("convertExports: %s", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + string(files["registries.tf"]) + string(files["devices.tf"]),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_registry.bas-plant", "log_level", "INFO"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.bas-plant_gateway-1", "credentials.#", "2"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.bas-plant_probe-1", "blocked", "true"),
+				),
+			},
+		},
+	})
+}
@@ -8,8 +8,10 @@ import (
 
 	"github.com/clearblade/go-iot"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -17,21 +19,16 @@ import (
 
 // Ensure the implementation satisfies the expected interfaces.
 var (
-	_ datasource.DataSource              = &devicesDataSource{}
-	_ datasource.DataSourceWithConfigure = &devicesDataSource{}
+	_ datasource.DataSource              = &deviceDataSource{}
+	_ datasource.DataSourceWithConfigure = &deviceDataSource{}
 )
 
-// devicesDataSourceModel maps the data source schema data.
-type devicesDataSourceModel struct {
-	Devices  []devicesModel `tfsdk:"devices"`
-	Registry types.String   `tfsdk:"registry"`
-}
-
-// devicesModel maps device schema data.
-type devicesModel struct {
+// deviceDataSourceModel maps the data source schema data.
+type deviceDataSourceModel struct {
 	ID                 types.String                      `tfsdk:"id"`
 	Name               types.String                      `tfsdk:"name"`
 	NumID              types.String                      `tfsdk:"num_id"`
+	Registry           types.String                      `tfsdk:"registry"`
 	Credentials        []DevicePublicKeyCertificateModel `tfsdk:"credentials"`
 	LastHeartbeatTime  types.String                      `tfsdk:"last_heartbeat_time"`
 	LastEventTime      types.String                      `tfsdk:"last_event_time"`
@@ -41,300 +38,309 @@ type devicesModel struct {
 	Blocked            types.Bool                        `tfsdk:"blocked"`
 	LastErrorTime      types.String                      `tfsdk:"last_error_time"`
 	LastErrorStatus    LastErrorStatusModel              `tfsdk:"last_error_status"`
-	Config             ConfigModel                       `tfsdk:"config"`
-	State              StateModel                        `tfsdk:"state"`
+	Config             deviceConfigModel                 `tfsdk:"config"`
+	State              deviceStateModel                  `tfsdk:"state"`
 	LogLevel           types.String                      `tfsdk:"log_level"`
-	// Metadata           types.Map                         `tfsdk:"metadata"`
-	GatewayConfig GatewayConfigModel `tfsdk:"gateway_config"`
+	Metadata           types.Map                         `tfsdk:"metadata"`
+	GatewayConfig      GatewayConfigModel                `tfsdk:"gateway_config"`
 }
 
-func NewDevicesDataSource() datasource.DataSource {
-	return &devicesDataSource{}
+// deviceConfigModel maps a device configuration, including its decoded data.
+type deviceConfigModel struct {
+	Version         types.Int64  `tfsdk:"version"`
+	CloudUpdateTime types.String `tfsdk:"cloud_update_time"`
+	DeviceAckTime   types.String `tfsdk:"device_ack_time"`
+	BinaryData      types.String `tfsdk:"binary_data"`
+	DataText        types.String `tfsdk:"data_text"`
 }
 
-type devicesDataSource struct {
+func NewDeviceDataSource() datasource.DataSource {
+	return &deviceDataSource{}
+}
+
+type deviceDataSource struct {
 	client *iot.Service
 }
 
-func (d *devicesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+func (d *deviceDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_device"
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *deviceDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*providerData).client
+}
+
+func (d *deviceDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "List devices in a device registry.",
+		Description: "Get a single device in a device registry by its identifier or numeric ID.",
 		Attributes: map[string]schema.Attribute{
-			"devices": schema.ListNestedAttribute{
-				Computed: true,
+			"id": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The user-defined device identifier. Exactly one of id or num_id must be set.",
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("num_id")),
+				},
+			},
+			"num_id": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "A server-defined unique numeric ID for the device. This is a more compact way to identify devices, and it is globally unique.",
+			},
+			"registry": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the device registry the device belongs to.",
+			},
+			"name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The resource path name. For example, projects/p1/locations/us-central1/registries/registry0/devices/dev0 or projects/p1/locations/us-central1/registries/registry0/devices/{numId}.",
+			},
+			"credentials": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The credentials used to authenticate this device.",
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
-						"id": schema.StringAttribute{
-							Computed:    true,
-							Description: "The user-defined device identifier. The device ID must be unique within a device registry.",
-						},
-						"name": schema.StringAttribute{
-							Computed:    true,
-							Description: "The resource path name. For example, projects/p1/locations/us-central1/registries/registry0/devices/dev0 or projects/p1/locations/us-central1/registries/registry0/devices/{numId}.",
-						},
-						"num_id": schema.StringAttribute{
-							Computed:    true,
-							Description: "A server-defined unique numeric ID for the device. This is a more compact way to identify devices, and it is globally unique.",
-						},
-						"credentials": schema.ListNestedAttribute{
-							Computed:    true,
-							Description: "The credentials used to authenticate this device.",
-							NestedObject: schema.NestedAttributeObject{
-								Attributes: map[string]schema.Attribute{
-									"expiration_time": schema.StringAttribute{
-										Computed:    true,
-										Description: "The time at which this credential becomes invalid.",
-									},
-									"public_key": schema.SingleNestedAttribute{
-										Optional:            true,
-										MarkdownDescription: "A public key used to verify the signature of JSON Web Tokens (JWTs).",
-										Attributes: map[string]schema.Attribute{
-											"format": schema.StringAttribute{
-												Computed: true,
-												Validators: []validator.String{
-													stringvalidator.OneOf(
-														"RSA_PEM",
-														"RSA_X509_PEM",
-														"ES256_PEM",
-														"ES256_X509_PEM",
-													),
-												},
-												Description: `The format of the key. Possible values: ["RSA_PEM", "RSA_X509_PEM", "ES256_PEM", "ES256_X509_PEM"]`,
-											},
-											"key": schema.StringAttribute{
-												Computed:    true,
-												Description: "The key data.",
-											},
-										},
-									},
-								},
-							},
-						},
-						"last_heartbeat_time": schema.StringAttribute{
-							Computed:            true,
-							MarkdownDescription: "The last time an MQTT PINGREQ was received.",
-						},
-						"last_event_time": schema.StringAttribute{
+						"expiration_time": schema.StringAttribute{
 							Computed:    true,
-							Description: "The last time a telemetry event was received.",
+							Description: "The time at which this credential becomes invalid.",
 						},
-						"last_state_time": schema.StringAttribute{
+						"public_key": schema.SingleNestedAttribute{
 							Computed:            true,
-							MarkdownDescription: "The last time a state event was received.",
-						},
-						"last_config_ack_time": schema.StringAttribute{
-							Computed:    true,
-							Description: "The last time a cloud-to-device config version acknowledgment was received from the device.",
-						},
-						"last_config_send_time": schema.StringAttribute{
-							Computed:    true,
-							Description: "The last time a cloud-to-device config version was sent to the device.",
-						},
-						"blocked": schema.BoolAttribute{
-							Computed:    true,
-							Description: "If a device is blocked, connections or requests from this device will fail.",
-						},
-						"last_error_time": schema.StringAttribute{
-							Computed:    true,
-							Description: "The time the most recent error occurred, such as a failure to publish to Cloud Pub/Sub.",
-						},
-						"last_error_status": schema.SingleNestedAttribute{
-							Computed:    true,
-							Description: "The error message of the most recent error, such as a failure to publish to Cloud Pub/Sub.",
-							Attributes: map[string]schema.Attribute{
-								"code": schema.Int64Attribute{
-									Computed:    true,
-									Description: `The status code, which should be an enum value of google.rpc.Code.`,
-								},
-								"message": schema.StringAttribute{
-									Computed:    true,
-									Description: `A developer-facing error message, which should be in English.`,
-								},
-							},
-						},
-						"config": schema.SingleNestedAttribute{
-							Computed:    true,
-							Description: "The most recent device configuration, which is eventually sent from Cloud IoT Core to the device.",
-							Attributes: map[string]schema.Attribute{
-								"version": schema.Int64Attribute{
-									Computed:    true,
-									Description: `The version of this update.`,
-								},
-								"cloud_update_time": schema.StringAttribute{
-									Computed:    true,
-									Description: `The time at which this configuration version was updated in Cloud IoT Core.`,
-								},
-								"device_ack_time": schema.StringAttribute{
-									Computed:    true,
-									Description: `The time at which Cloud IoT Core received the acknowledgment from the device, indicating that the device has received this configuration version.`,
-								},
-								"binary_data": schema.StringAttribute{
-									Computed:    true,
-									Description: `The device configuration data.`,
-								},
-							},
-						},
-						"state": schema.SingleNestedAttribute{
-							Computed:    true,
-							Description: "The state most recently received from the device.",
-							Attributes: map[string]schema.Attribute{
-								"update_time": schema.StringAttribute{
-									Computed:    true,
-									Description: `The time at which this state version was updated in Cloud IoT Core.`,
-								},
-								"binary_data": schema.StringAttribute{
-									Computed:    true,
-									Description: `The device state data.`,
-								},
-							},
-						},
-						"log_level": schema.StringAttribute{
-							Computed: true,
-							Validators: []validator.String{
-								stringvalidator.OneOf(
-									"NONE",
-									"ERROR",
-									"INFO",
-									"DEBUG",
-									"",
-								),
-							},
-							Description: `The logging verbosity for device activity. Possible values: ["NONE", "ERROR", "INFO", "DEBUG"]`,
-						},
-						// "metadata": schema.MapAttribute{
-						// 	Computed:    true,
-						// 	ElementType: types.StringType,
-						// },
-						"gateway_config": schema.SingleNestedAttribute{
-							Computed:    true,
-							Description: `Gateway-related configuration and state.`,
+							MarkdownDescription: "A public key used to verify the signature of JSON Web Tokens (JWTs).",
 							Attributes: map[string]schema.Attribute{
-								"gateway_type": schema.StringAttribute{
-									Computed: true,
-									Validators: []validator.String{
-										stringvalidator.OneOf(
-											"GATEWAY",
-											"NON_GATEWAY",
-											"",
-										),
-									},
-									Description: `Indicates whether the device is a gateway. Default value: "NON_GATEWAY" Possible values: ["GATEWAY", "NON_GATEWAY"]`,
-								},
-								"gateway_auth_method": schema.StringAttribute{
-									Computed: true,
-									Validators: []validator.String{
-										stringvalidator.OneOf(
-											"ASSOCIATION_ONLY",
-											"DEVICE_AUTH_TOKEN_ONLY",
-											"ASSOCIATION_AND_DEVICE_AUTH_TOKEN",
-										),
-									},
-									Description: `Indicates whether the device is a gateway. Possible values: ["ASSOCIATION_ONLY", "DEVICE_AUTH_TOKEN_ONLY", "ASSOCIATION_AND_DEVICE_AUTH_TOKEN"]`,
-								},
-								"last_accessed_gateway_id": schema.StringAttribute{
+								"format": schema.StringAttribute{
 									Computed:    true,
-									Description: `The ID of the gateway the device accessed most recently.`,
+									Description: `The format of the key. Possible values: ["RSA_PEM", "RSA_X509_PEM", "ES256_PEM", "ES256_X509_PEM"]`,
 								},
-								"last_accessed_gateway_time": schema.StringAttribute{
+								"key": schema.StringAttribute{
 									Computed:    true,
-									Description: `The most recent time at which the device accessed the gateway specified in last_accessed_gateway.`,
+									Description: "The key data.",
 								},
 							},
 						},
 					},
 				},
 			},
-			"registry": schema.StringAttribute{
-				Description: "The name of the device registry where this device should be created.",
-				Required:    true,
+			"last_heartbeat_time": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The last time an MQTT PINGREQ was received.",
+			},
+			"last_event_time": schema.StringAttribute{
+				Computed:    true,
+				Description: "The last time a telemetry event was received.",
+			},
+			"last_state_time": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The last time a state event was received.",
+			},
+			"last_config_ack_time": schema.StringAttribute{
+				Computed:    true,
+				Description: "The last time a cloud-to-device config version acknowledgment was received from the device.",
+			},
+			"last_config_send_time": schema.StringAttribute{
+				Computed:    true,
+				Description: "The last time a cloud-to-device config version was sent to the device.",
+			},
+			"blocked": schema.BoolAttribute{
+				Computed:    true,
+				Description: "If a device is blocked, connections or requests from this device will fail.",
+			},
+			"last_error_time": schema.StringAttribute{
+				Computed:    true,
+				Description: "The time the most recent error occurred, such as a failure to publish to Cloud Pub/Sub.",
+			},
+			"last_error_status": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The error message of the most recent error, such as a failure to publish to Cloud Pub/Sub.",
+				Attributes: map[string]schema.Attribute{
+					"code": schema.Int64Attribute{
+						Computed:    true,
+						Description: `The status code, which should be an enum value of google.rpc.Code.`,
+					},
+					"message": schema.StringAttribute{
+						Computed:    true,
+						Description: `A developer-facing error message, which should be in English.`,
+					},
+				},
+			},
+			"config": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The most recent device configuration, which is eventually sent from Cloud IoT Core to the device.",
+				Attributes: map[string]schema.Attribute{
+					"version": schema.Int64Attribute{
+						Computed:    true,
+						Description: `The version of this update.`,
+					},
+					"cloud_update_time": schema.StringAttribute{
+						Computed:    true,
+						Description: `The time at which this configuration version was updated in Cloud IoT Core.`,
+					},
+					"device_ack_time": schema.StringAttribute{
+						Computed:    true,
+						Description: `The time at which Cloud IoT Core received the acknowledgment from the device, indicating that the device has received this configuration version.`,
+					},
+					"binary_data": schema.StringAttribute{
+						Computed:    true,
+						Description: `The device configuration data, base64 encoded.`,
+					},
+					"data_text": schema.StringAttribute{
+						Computed:    true,
+						Description: `The device configuration data decoded as text.`,
+					},
+				},
+			},
+			"state": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The state most recently received from the device.",
+				Attributes: map[string]schema.Attribute{
+					"update_time": schema.StringAttribute{
+						Computed:    true,
+						Description: `The time at which this state version was updated in Cloud IoT Core.`,
+					},
+					"binary_data": schema.StringAttribute{
+						Computed:    true,
+						Description: `The device state data, base64 encoded.`,
+					},
+					"data_text": schema.StringAttribute{
+						Computed:    true,
+						Description: `The device state data decoded as text.`,
+					},
+				},
+			},
+			"log_level": schema.StringAttribute{
+				Computed:    true,
+				Description: `The logging verbosity for device activity. Possible values: ["NONE", "ERROR", "INFO", "DEBUG"]`,
+			},
+			"metadata": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The metadata key-value pairs assigned to the device.",
+			},
+			"gateway_config": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: `Gateway-related configuration and state.`,
+				Attributes: map[string]schema.Attribute{
+					"gateway_type": schema.StringAttribute{
+						Computed:    true,
+						Description: `Indicates whether the device is a gateway. Possible values: ["GATEWAY", "NON_GATEWAY"]`,
+					},
+					"gateway_auth_method": schema.StringAttribute{
+						Computed:    true,
+						Description: `The authentication method used by the gateway. Possible values: ["ASSOCIATION_ONLY", "DEVICE_AUTH_TOKEN_ONLY", "ASSOCIATION_AND_DEVICE_AUTH_TOKEN"]`,
+					},
+					"last_accessed_gateway_id": schema.StringAttribute{
+						Computed:    true,
+						Description: `The ID of the gateway the device accessed most recently.`,
+					},
+					"last_accessed_gateway_time": schema.StringAttribute{
+						Computed:    true,
+						Description: `The most recent time at which the device accessed the gateway specified in last_accessed_gateway.`,
+					},
+				},
 			},
 		},
 	}
 }
 
-func (d *devicesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
-	var state devicesDataSourceModel
+func (d *deviceDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state deviceDataSourceModel
 
-	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	// Only the lookup attributes are read from the configuration; the
+	// computed objects are null there and cannot be decoded into the model.
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("id"), &state.ID)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("num_id"), &state.NumID)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("registry"), &state.Registry)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The devices API accepts either the user-defined ID or the numeric ID in the resource path.
+	deviceID := state.ID.ValueString()
+	if state.ID.IsNull() {
+		deviceID = state.NumID.ValueString()
+	}
 
-	tflog.Info(ctx, "requesting device listing from Clearblade IoT Core")
-	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString())
-	devices, err := d.client.Projects.Locations.Registries.Devices.List(parent).Do()
+	tflog.Info(ctx, "requesting device detail from Clearblade IoT Core")
+	name := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString(), deviceID)
+	device, err := d.client.Projects.Locations.Registries.Devices.Get(name).Context(ctx).Do()
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Unable to read Clearblade IoT Core devices. Make sure your credentials are correct and you have access "+
-				"to the project, or that you have the correct permissions.",
-			err.Error(),
+			"Unable to read Clearblade IoT Core device",
+			"Could not read ClearBlade IoT Core device "+deviceID+": "+err.Error(),
 		)
 		return
 	}
 
-	ctx = tflog.SetField(ctx, "device length", strconv.Itoa(len(devices.Devices)))
-
 	// Map response body to model
-	for _, device := range devices.Devices {
-		deviceState := devicesModel{
-			ID:    types.StringValue(device.Id),
-			Name:  types.StringValue(device.Name),
-			NumID: types.StringValue(strconv.FormatUint(device.NumId, 10)),
-		}
+	state.ID = types.StringValue(device.Id)
+	state.Name = types.StringValue(device.Name)
+	state.NumID = types.StringValue(strconv.FormatUint(device.NumId, 10))
+	state.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
+	state.LastEventTime = types.StringValue(device.LastEventTime)
+	state.LastStateTime = types.StringValue(device.LastStateTime)
+	state.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
+	state.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
+	state.Blocked = types.BoolValue(device.Blocked)
+	state.LastErrorTime = types.StringValue(device.LastErrorTime)
+	state.LogLevel = types.StringValue(device.LogLevel)
 
-		for _, credential := range device.Credentials {
-			deviceState.Credentials = append(deviceState.Credentials, DevicePublicKeyCertificateModel{
-				ExpirationTime: types.StringValue(credential.ExpirationTime),
-				PublicKey: PublicKeyModel{
-					Format: types.StringValue(credential.PublicKey.Format),
-					Key:    types.StringValue(credential.PublicKey.Key),
-				},
-			})
+	state.Credentials = []DevicePublicKeyCertificateModel{}
+	for _, credential := range device.Credentials {
+		m := DevicePublicKeyCertificateModel{
+			ExpirationTime: types.StringValue(credential.ExpirationTime),
 		}
-
-		deviceState.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
-		deviceState.LastEventTime = types.StringValue(device.LastEventTime)
-		deviceState.LastStateTime = types.StringValue(device.LastStateTime)
-		deviceState.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
-		deviceState.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
-		deviceState.Blocked = types.BoolValue(device.Blocked)
-		deviceState.LastErrorTime = types.StringValue(device.LastErrorTime)
-
-		deviceState.LastErrorStatus = LastErrorStatusModel{
-			Code:    types.Int64Value(device.LastErrorStatus.Code),
-			Message: types.StringValue(device.LastErrorStatus.Message),
+		if credential.PublicKey != nil {
+			m.PublicKey = PublicKeyModel{
+				Format: types.StringValue(credential.PublicKey.Format),
+				Key:    types.StringValue(credential.PublicKey.Key),
+			}
 		}
+		state.Credentials = append(state.Credentials, m)
+	}
+
+	state.LastErrorStatus = LastErrorStatusModel{}
+	if device.LastErrorStatus != nil {
+		state.LastErrorStatus.Code = types.Int64Value(device.LastErrorStatus.Code)
+		state.LastErrorStatus.Message = types.StringValue(device.LastErrorStatus.Message)
+	}
 
-		deviceState.Config = ConfigModel{
+	state.Config = deviceConfigModel{}
+	if device.Config != nil {
+		state.Config = deviceConfigModel{
 			Version:         types.Int64Value(device.Config.Version),
 			CloudUpdateTime: types.StringValue(device.Config.CloudUpdateTime),
 			DeviceAckTime:   types.StringValue(device.Config.DeviceAckTime),
 			BinaryData:      types.StringValue(device.Config.BinaryData),
+			DataText:        decodeBinaryData(device.Config.BinaryData),
 		}
+	}
 
-		deviceState.State = StateModel{
+	state.State = deviceStateModel{}
+	if device.State != nil {
+		state.State = deviceStateModel{
 			UpdateTime: types.StringValue(device.State.UpdateTime),
 			BinaryData: types.StringValue(device.State.BinaryData),
+			DataText:   decodeBinaryData(device.State.BinaryData),
 		}
+	}
 
-		deviceState.LogLevel = types.StringValue(device.LogLevel)
-
-		// attributes := map[string]attr.Value{}
-		// for k, v := range device.Metadata {
-		// 	s, _ := strconv.Unquote(v)
-		// 	ctx = tflog.SetField(ctx, "state attributes ll", s)
-		// 	tflog.Debug(ctx, "state attributes read")
-		// 	attributes[k] = types.StringValue(s)
-		// }
-		// deviceState.Metadata, _ = types.MapValueFrom(ctx, state.Metadata.ElementType(ctx), attributes)
-
-		deviceState.GatewayConfig = GatewayConfigModel{
+	state.GatewayConfig = GatewayConfigModel{}
+	if device.GatewayConfig != nil {
+		state.GatewayConfig = GatewayConfigModel{
 			GatewayType:             types.StringValue(device.GatewayConfig.GatewayType),
 			GatewayAuthMethod:       types.StringValue(device.GatewayConfig.GatewayAuthMethod),
 			LastAccessedGatewayID:   types.StringValue(device.GatewayConfig.LastAccessedGatewayId),
 			LastAccessedGatewayTime: types.StringValue(device.GatewayConfig.LastAccessedGatewayTime),
 		}
-
-		state.Devices = append(state.Devices, deviceState)
 	}
 
+	state.Metadata = flattenDeviceMetadata(device.Metadata, types.MapNull(types.StringType))
+
 	// Set state
 	diags := resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
@@ -343,15 +349,20 @@ func (d *devicesDataSource) Read(ctx context.Context, req datasource.ReadRequest
 	}
 }
 
-func (d *devicesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
-	resp.TypeName = req.ProviderTypeName + "_devices"
-}
-
-// Configure adds the provider configured client to the data source.
-func (d *devicesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
-	if req.ProviderData == nil {
-		return
+// flattenDeviceMetadata converts device metadata returned by the API into a
+// Terraform map. Earlier versions of the provider stored metadata values
+// quoted, so a value that is the quoted form of its prior value keeps the
+// prior value; every other value is kept verbatim. prior is null when there
+// is no prior value.
+func flattenDeviceMetadata(metadata map[string]string, prior types.Map) types.Map {
+	priorValues := prior.Elements()
+	attributes := map[string]attr.Value{}
+	for k, v := range metadata {
+		if p, ok := priorValues[k].(types.String); ok && !p.IsNull() && v == strconv.Quote(p.ValueString()) {
+			attributes[k] = p
+			continue
+		}
+		attributes[k] = types.StringValue(v)
 	}
-
-	d.client = req.ProviderData.(*iot.Service)
+	return types.MapValueMust(types.StringType, attributes)
 }
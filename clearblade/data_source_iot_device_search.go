@@ -0,0 +1,297 @@
+package clearblade
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &deviceSearchDataSource{}
+	_ datasource.DataSourceWithConfigure = &deviceSearchDataSource{}
+)
+
+// deviceSearchDataSourceModel maps the data source schema data.
+type deviceSearchDataSourceModel struct {
+	ID          types.String              `tfsdk:"id"`
+	IDPrefix    types.String              `tfsdk:"id_prefix"`
+	IDRegex     types.String              `tfsdk:"id_regex"`
+	NumID       types.String              `tfsdk:"num_id"`
+	Metadata    types.Map                 `tfsdk:"metadata"`
+	Concurrency types.Int64               `tfsdk:"concurrency"`
+	Devices     []deviceSearchResultModel `tfsdk:"devices"`
+}
+
+// deviceSearchResultModel maps a device found by the search.
+type deviceSearchResultModel struct {
+	Registry          types.String `tfsdk:"registry"`
+	ID                types.String `tfsdk:"id"`
+	NumID             types.String `tfsdk:"num_id"`
+	Name              types.String `tfsdk:"name"`
+	Blocked           types.Bool   `tfsdk:"blocked"`
+	LastHeartbeatTime types.String `tfsdk:"last_heartbeat_time"`
+	Metadata          types.Map    `tfsdk:"metadata"`
+}
+
+func NewDeviceSearchDataSource() datasource.DataSource {
+	return &deviceSearchDataSource{}
+}
+
+type deviceSearchDataSource struct {
+	client *iot.Service
+}
+
+func (d *deviceSearchDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_device_search"
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *deviceSearchDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*providerData).client
+}
+
+func (d *deviceSearchDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	matchers := path.Expressions{
+		path.MatchRoot("id"),
+		path.MatchRoot("id_prefix"),
+		path.MatchRoot("id_regex"),
+		path.MatchRoot("num_id"),
+		path.MatchRoot("metadata"),
+	}
+
+	resp.Schema = schema.Schema{
+		Description: "Search every device registry in the project for devices. A device is returned only if it matches every criterion that is set, and at least one criterion must be set.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Description: "The exact user-defined device identifier to search for.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.AtLeastOneOf(matchers...),
+				},
+			},
+			"id_prefix": schema.StringAttribute{
+				Description: "Only return devices whose ID starts with this prefix.",
+				Optional:    true,
+			},
+			"id_regex": schema.StringAttribute{
+				Description: "Only return devices whose ID matches this regular expression.",
+				Optional:    true,
+			},
+			"num_id": schema.StringAttribute{
+				Description: "The numeric device ID to search for.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.RegexMatches(regexp.MustCompile(`^[0-9]+$`), "must be a numeric device ID"),
+				},
+			},
+			"metadata": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "Metadata key-value pairs the device metadata must contain exactly.",
+				Optional:    true,
+			},
+			"concurrency": schema.Int64Attribute{
+				Description: fmt.Sprintf("The maximum number of registries listed in parallel. Defaults to %d.", defaultConcurrency),
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 64),
+				},
+			},
+			"devices": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The matching devices, ordered by registry and device ID.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"registry": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the device registry the device belongs to.",
+						},
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The user-defined device identifier.",
+						},
+						"num_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "A server-defined unique numeric ID for the device.",
+						},
+						"name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The resource path name of the device.",
+						},
+						"blocked": schema.BoolAttribute{
+							Computed:    true,
+							Description: "If a device is blocked, connections or requests from this device will fail.",
+						},
+						"last_heartbeat_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The last time an MQTT PINGREQ was received.",
+						},
+						"metadata": schema.MapAttribute{
+							Computed:    true,
+							ElementType: types.StringType,
+							Description: "The metadata key-value pairs assigned to the device.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *deviceSearchDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state deviceSearchDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Exact ID lookups are narrowed on the server, everything else is
+	// matched once the devices of a registry have been listed. Listings only
+	// return the ID, name and numeric ID of a device unless more fields are
+	// requested.
+	opts := deviceListOptions{FieldMask: "blocked,last_heartbeat_time,metadata"}
+	if !state.ID.IsNull() {
+		opts.DeviceIDs = []string{state.ID.ValueString()}
+	}
+	if !state.NumID.IsNull() {
+		numID, err := strconv.ParseUint(state.NumID.ValueString(), 10, 64)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("num_id"), "Invalid device numeric ID", err.Error())
+			return
+		}
+		opts.DeviceNumIDs = []uint64{numID}
+	}
+
+	var idRegex *regexp.Regexp
+	if !state.IDRegex.IsNull() {
+		re, err := regexp.Compile(state.IDRegex.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("id_regex"), "Invalid regular expression", "Could not compile id_regex: "+err.Error())
+			return
+		}
+		idRegex = re
+	}
+
+	metadataFilter := &devicesFilter{}
+	if !state.Metadata.IsNull() {
+		resp.Diagnostics.Append(state.Metadata.ElementsAs(ctx, &metadataFilter.metadata, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	concurrency := defaultConcurrency
+	if !state.Concurrency.IsNull() {
+		concurrency = int(state.Concurrency.ValueInt64())
+	}
+
+	project := os.Getenv("CLEARBLADE_PROJECT")
+	region := os.Getenv("CLEARBLADE_REGION")
+
+	tflog.Info(ctx, "requesting device registry listing from Clearblade IoT Core")
+	registries, err := listRegistries(ctx, d.client, fmt.Sprintf("projects/%s/locations/%s", project, region), 0, 0)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to read Clearblade IoT Core device registries",
+			"Could not list the device registries to search: "+err.Error(),
+		)
+		return
+	}
+
+	registryIDs := make([]string, len(registries))
+	for i, registry := range registries {
+		registryIDs[i] = registry.Id
+	}
+	if err := primeRegistryCredentials(d.client, region, registryIDs...); err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to read Clearblade IoT Core device registries",
+			"Could not get the credentials of the device registries to search: "+err.Error(),
+		)
+		return
+	}
+
+	ctx = tflog.SetField(ctx, "registry count", len(registryIDs))
+	tflog.Info(ctx, "searching device registries")
+
+	results := make([][]*iot.Device, len(registryIDs))
+	errs := runBounded(ctx, len(registryIDs), concurrency, func(ctx context.Context, i int) error {
+		parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", project, region, registryIDs[i])
+		devices, err := listDevices(ctx, d.client, parent, opts)
+		results[i] = devices
+		return err
+	})
+
+	now := time.Now()
+	state.Devices = []deviceSearchResultModel{}
+	for i, registryID := range registryIDs {
+		if errs[i] != nil {
+			resp.Diagnostics.AddError(
+				"Unable to read Clearblade IoT Core devices",
+				"Could not list the devices of registry "+registryID+": "+errs[i].Error(),
+			)
+			continue
+		}
+
+		for _, device := range results[i] {
+			if !state.IDPrefix.IsNull() && !strings.HasPrefix(device.Id, state.IDPrefix.ValueString()) {
+				continue
+			}
+			if idRegex != nil && !idRegex.MatchString(device.Id) {
+				continue
+			}
+			if !metadataFilter.match(device, now) {
+				continue
+			}
+
+			state.Devices = append(state.Devices, deviceSearchResultModel{
+				Registry:          types.StringValue(registryID),
+				ID:                types.StringValue(device.Id),
+				NumID:             types.StringValue(strconv.FormatUint(device.NumId, 10)),
+				Name:              types.StringValue(device.Name),
+				Blocked:           types.BoolValue(device.Blocked),
+				LastHeartbeatTime: types.StringValue(device.LastHeartbeatTime),
+				Metadata:          flattenDeviceMetadata(device.Metadata, types.MapNull(types.StringType)),
+			})
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	sort.SliceStable(state.Devices, func(i, j int) bool {
+		if state.Devices[i].Registry.ValueString() != state.Devices[j].Registry.ValueString() {
+			return state.Devices[i].Registry.ValueString() < state.Devices[j].Registry.ValueString()
+		}
+		return state.Devices[i].ID.ValueString() < state.Devices[j].ID.ValueString()
+	})
+
+	ctx = tflog.SetField(ctx, "match count", len(state.Devices))
+	tflog.Debug(ctx, "device search complete")
+
+	// Set state
+	diags := resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
@@ -0,0 +1,95 @@
+package clearblade
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccDeviceSearchDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+	heartbeat := "2024-06-01T12:00:00Z"
+	for _, registryID := range []string{"plant-north", "plant-south"} {
+		server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: registryID})
+		registry := testAccLocation + "/registries/" + registryID
+		for _, device := range []*iot.Device{
+			{Id: "sensor-1", Metadata: map[string]string{"site": registryID}},
+			{Id: "sensor-2", Blocked: true, LastHeartbeatTime: heartbeat, Metadata: map[string]string{"site": registryID, "kind": "thermostat"}},
+			{Id: "gateway-" + registryID},
+		} {
+			if err := server.PutDevice(registry, device); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	stored, _ := server.Device(testAccLocation + "/registries/plant-south/devices/sensor-1")
+	numID := strconv.FormatUint(stored.NumId, 10)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + fmt.Sprintf(`
+data "clearblade_iot_device_search" "by_id" {
+  id = "sensor-2"
+}
+
+data "clearblade_iot_device_search" "by_prefix" {
+  id_prefix = "gateway-"
+}
+
+data "clearblade_iot_device_search" "by_regex" {
+  id_regex = "^sensor-[0-9]$"
+}
+
+data "clearblade_iot_device_search" "by_num_id" {
+  num_id = %q
+}
+
+data "clearblade_iot_device_search" "by_metadata" {
+  metadata = {
+    kind = "thermostat"
+    site = "plant-north"
+  }
+}
+`, numID),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					// Matches are ordered by registry and device ID.
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.#", "2"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.0.registry", "plant-north"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.0.id", "sensor-2"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.0.blocked", "true"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.0.last_heartbeat_time", heartbeat),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.0.metadata.kind", "thermostat"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.1.registry", "plant-south"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_id", "devices.1.metadata.site", "plant-south"),
+
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_prefix", "devices.#", "2"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_prefix", "devices.0.id", "gateway-plant-north"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_prefix", "devices.0.registry", "plant-north"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_prefix", "devices.1.id", "gateway-plant-south"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_prefix", "devices.1.registry", "plant-south"),
+
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_regex", "devices.#", "4"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_regex", "devices.1.registry", "plant-north"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_regex", "devices.1.id", "sensor-2"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_regex", "devices.2.registry", "plant-south"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_regex", "devices.2.id", "sensor-1"),
+
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_num_id", "devices.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_num_id", "devices.0.registry", "plant-south"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_num_id", "devices.0.id", "sensor-1"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_num_id", "devices.0.num_id", numID),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_num_id", "devices.0.blocked", "false"),
+
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_metadata", "devices.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_metadata", "devices.0.registry", "plant-north"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_search.by_metadata", "devices.0.id", "sensor-2"),
+				),
+			},
+		},
+	})
+}
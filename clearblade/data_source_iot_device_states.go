@@ -0,0 +1,158 @@
+package clearblade
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &deviceStatesDataSource{}
+	_ datasource.DataSourceWithConfigure = &deviceStatesDataSource{}
+)
+
+// deviceStatesDataSourceModel maps the data source schema data.
+type deviceStatesDataSourceModel struct {
+	Registry  types.String       `tfsdk:"registry"`
+	DeviceID  types.String       `tfsdk:"device_id"`
+	NumStates types.Int64        `tfsdk:"num_states"`
+	States    []deviceStateModel `tfsdk:"states"`
+}
+
+// deviceStateModel maps a single device state version.
+type deviceStateModel struct {
+	UpdateTime types.String `tfsdk:"update_time"`
+	BinaryData types.String `tfsdk:"binary_data"`
+	DataText   types.String `tfsdk:"data_text"`
+}
+
+func NewDeviceStatesDataSource() datasource.DataSource {
+	return &deviceStatesDataSource{}
+}
+
+type deviceStatesDataSource struct {
+	client *iot.Service
+}
+
+func (d *deviceStatesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_device_states"
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *deviceStatesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*providerData).client
+}
+
+func (d *deviceStatesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists the last few versions of the device state in descending order (i.e.: newest first).",
+		Attributes: map[string]schema.Attribute{
+			"registry": schema.StringAttribute{
+				Description: "The name of the device registry the device belongs to.",
+				Required:    true,
+			},
+			"device_id": schema.StringAttribute{
+				Description: "The user-defined device identifier or the numeric ID of the device.",
+				Required:    true,
+			},
+			"num_states": schema.Int64Attribute{
+				Description: "The number of states to list. States are listed in descending order of update time. The maximum number of states retained is 10. If this value is zero or unset, it will return all the states available.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 10),
+				},
+			},
+			"states": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The last few device states, newest first.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"update_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The time at which this state version was updated in Cloud IoT Core.",
+						},
+						"binary_data": schema.StringAttribute{
+							Computed:    true,
+							Description: "The device state data, base64 encoded.",
+						},
+						"data_text": schema.StringAttribute{
+							Computed:    true,
+							Description: "The device state data decoded as text. Null when binary_data is not valid base64 or does not decode to UTF-8 text.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *deviceStatesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state deviceStatesDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	tflog.Info(ctx, "requesting device state listing from Clearblade IoT Core")
+	name := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString(), state.DeviceID.ValueString())
+	call := d.client.Projects.Locations.Registries.Devices.States.List(name).Context(ctx)
+	if state.NumStates.ValueInt64() > 0 {
+		call = call.NumStates(state.NumStates.ValueInt64())
+	}
+	states, err := call.Do()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to read Clearblade IoT Core device states",
+			"Could not list the states of device "+state.DeviceID.ValueString()+": "+err.Error(),
+		)
+		return
+	}
+
+	ctx = tflog.SetField(ctx, "device state length", strconv.Itoa(len(states.DeviceStates)))
+	tflog.Debug(ctx, "device states read")
+
+	// Map response body to model
+	state.States = []deviceStateModel{}
+	for _, deviceState := range states.DeviceStates {
+		state.States = append(state.States, deviceStateModel{
+			UpdateTime: types.StringValue(deviceState.UpdateTime),
+			BinaryData: types.StringValue(deviceState.BinaryData),
+			DataText:   decodeBinaryData(deviceState.BinaryData),
+		})
+	}
+
+	// Set state
+	diags := resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// decodeBinaryData decodes the base64 payload used by device configs, states
+// and commands, returning null when the payload is not valid base64 or not
+// text, which Terraform strings cannot hold.
+func decodeBinaryData(data string) types.String {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || !utf8.Valid(decoded) {
+		return types.StringNull()
+	}
+	return types.StringValue(string(decoded))
+}
@@ -0,0 +1,74 @@
+package clearblade
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccDeviceStatesDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	registry := testAccLocation + "/registries/test-registry"
+	if err := server.PutDevice(registry, &iot.Device{Id: "test-device"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range [][]byte{[]byte("one"), []byte("two"), {0xff, 0xfe}} {
+		if err := server.AddDeviceState(registry+"/devices/test-device", state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_iot_device_states" "all" {
+  registry  = "test-registry"
+  device_id = "test-device"
+}
+
+data "clearblade_iot_device_states" "last" {
+  registry   = "test-registry"
+  device_id  = "test-device"
+  num_states = 2
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.all", "states.#", "3"),
+					// States are listed newest first.
+					resource.TestCheckResourceAttrSet("data.clearblade_iot_device_states.all", "states.0.update_time"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.all", "states.0.binary_data", base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe})),
+					resource.TestCheckNoResourceAttr("data.clearblade_iot_device_states.all", "states.0.data_text"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.all", "states.1.binary_data", base64.StdEncoding.EncodeToString([]byte("two"))),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.all", "states.1.data_text", "two"),
+					resource.TestCheckResourceAttrSet("data.clearblade_iot_device_states.all", "states.2.update_time"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.all", "states.2.data_text", "one"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.last", "states.#", "2"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device_states.last", "states.1.data_text", "two"),
+				),
+			},
+		},
+	})
+}
+
+func TestDecodeBinaryData(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want types.String
+	}{
+		{"text", base64.StdEncoding.EncodeToString([]byte("reboot")), types.StringValue("reboot")},
+		{"empty", "", types.StringValue("")},
+		{"not UTF-8", base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0x00}), types.StringNull()},
+		{"not base64", "not base64!", types.StringNull()},
+	} {
+		if got := decodeBinaryData(tc.data); !got.Equal(tc.want) {
+			t.Errorf("%s: decodeBinaryData(%q) = %s, want %s", tc.name, tc.data, got, tc.want)
+		}
+	}
+}
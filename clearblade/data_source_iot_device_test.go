@@ -0,0 +1,92 @@
+package clearblade
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccDeviceDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	registry := testAccLocation + "/registries/test-registry"
+	device := &iot.Device{
+		Id:      "test-device",
+		Blocked: true,
+		Metadata: map[string]string{
+			"site": "plant-1",
+			// Quoted by an earlier version of the provider, or written
+			// quoted by another client.
+			"owner": `"ops"`,
+		},
+	}
+	if err := server.PutDevice(registry, device); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := server.Device(registry + "/devices/test-device")
+	numID := strconv.FormatUint(stored.NumId, 10)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_iot_device" "by_id" {
+  registry = "test-registry"
+  id       = "test-device"
+}
+
+data "clearblade_iot_device" "by_num_id" {
+  registry = "test-registry"
+  num_id   = "` + numID + `"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_id", "num_id", numID),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_id", "blocked", "true"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_id", "metadata.site", "plant-1"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_id", "metadata.owner", `"ops"`),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_num_id", "id", "test-device"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_num_id", "num_id", numID),
+					resource.TestCheckResourceAttrPair("data.clearblade_iot_device.by_num_id", "name", "data.clearblade_iot_device.by_id", "name"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_device.by_num_id", "metadata.site", "plant-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestFlattenDeviceMetadata(t *testing.T) {
+	metadata := map[string]string{
+		"site":   "plant-1",
+		"legacy": `"plant-2"`,
+		"quoted": `"plant-3"`,
+	}
+	prior := types.MapValueMust(types.StringType, map[string]attr.Value{
+		"site": types.StringValue("plant-0"),
+		// Written quoted by an earlier version of the provider.
+		"legacy": types.StringValue("plant-2"),
+	})
+
+	for _, tc := range []struct {
+		name  string
+		prior types.Map
+		want  map[string]string
+	}{
+		{"no prior", types.MapNull(types.StringType), map[string]string{"site": "plant-1", "legacy": `"plant-2"`, "quoted": `"plant-3"`}},
+		{"prior", prior, map[string]string{"site": "plant-1", "legacy": "plant-2", "quoted": `"plant-3"`}},
+	} {
+		got := map[string]string{}
+		for k, v := range flattenDeviceMetadata(metadata, tc.prior).Elements() {
+			got[k] = v.(types.String).ValueString()
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: flattenDeviceMetadata = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,779 @@
+package clearblade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &devicesDataSource{}
+	_ datasource.DataSourceWithConfigure = &devicesDataSource{}
+)
+
+// devicesDataSourceModel maps the data source schema data.
+type devicesDataSourceModel struct {
+	Devices    []devicesModel `tfsdk:"devices"`
+	Registry   types.String   `tfsdk:"registry"`
+	PageSize   types.Int64    `tfsdk:"page_size"`
+	MaxResults types.Int64    `tfsdk:"max_results"`
+
+	DeviceIDs             []types.String `tfsdk:"device_ids"`
+	DeviceNumIDs          []types.String `tfsdk:"device_num_ids"`
+	FieldMask             types.String   `tfsdk:"field_mask"`
+	GatewayType           types.String   `tfsdk:"gateway_type"`
+	AssociationsGatewayID types.String   `tfsdk:"associations_gateway_id"`
+	AssociationsDeviceID  types.String   `tfsdk:"associations_device_id"`
+
+	Filter *devicesFilterModel `tfsdk:"filter"`
+}
+
+// devicesFilterModel maps the client-side device filter.
+type devicesFilterModel struct {
+	Metadata               types.Map    `tfsdk:"metadata"`
+	MetadataRegex          types.Map    `tfsdk:"metadata_regex"`
+	Blocked                types.Bool   `tfsdk:"blocked"`
+	LogLevel               types.String `tfsdk:"log_level"`
+	LastHeartbeatWithin    types.String `tfsdk:"last_heartbeat_within"`
+	LastHeartbeatOlderThan types.String `tfsdk:"last_heartbeat_older_than"`
+	LastErrorWithin        types.String `tfsdk:"last_error_within"`
+	LastErrorOlderThan     types.String `tfsdk:"last_error_older_than"`
+}
+
+// devicesModel maps device schema data.
+type devicesModel struct {
+	ID                 types.String                      `tfsdk:"id"`
+	Name               types.String                      `tfsdk:"name"`
+	NumID              types.String                      `tfsdk:"num_id"`
+	Credentials        []DevicePublicKeyCertificateModel `tfsdk:"credentials"`
+	LastHeartbeatTime  types.String                      `tfsdk:"last_heartbeat_time"`
+	LastEventTime      types.String                      `tfsdk:"last_event_time"`
+	LastStateTime      types.String                      `tfsdk:"last_state_time"`
+	LastConfigAckTime  types.String                      `tfsdk:"last_config_ack_time"`
+	LastConfigSendTime types.String                      `tfsdk:"last_config_send_time"`
+	Blocked            types.Bool                        `tfsdk:"blocked"`
+	LastErrorTime      types.String                      `tfsdk:"last_error_time"`
+	LastErrorStatus    LastErrorStatusModel              `tfsdk:"last_error_status"`
+	Config             ConfigModel                       `tfsdk:"config"`
+	State              StateModel                        `tfsdk:"state"`
+	LogLevel           types.String                      `tfsdk:"log_level"`
+	Metadata           types.Map                         `tfsdk:"metadata"`
+	GatewayConfig      GatewayConfigModel                `tfsdk:"gateway_config"`
+}
+
+func NewDevicesDataSource() datasource.DataSource {
+	return &devicesDataSource{}
+}
+
+type devicesDataSource struct {
+	client *iot.Service
+}
+
+func (d *devicesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "List devices in a device registry.",
+		Attributes: map[string]schema.Attribute{
+			"devices": schema.ListNestedAttribute{
+				Computed: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The user-defined device identifier. The device ID must be unique within a device registry.",
+						},
+						"name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The resource path name. For example, projects/p1/locations/us-central1/registries/registry0/devices/dev0 or projects/p1/locations/us-central1/registries/registry0/devices/{numId}.",
+						},
+						"num_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "A server-defined unique numeric ID for the device. This is a more compact way to identify devices, and it is globally unique.",
+						},
+						"credentials": schema.ListNestedAttribute{
+							Computed:    true,
+							Description: "The credentials used to authenticate this device.",
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"expiration_time": schema.StringAttribute{
+										Computed:    true,
+										Description: "The time at which this credential becomes invalid.",
+									},
+									"public_key": schema.SingleNestedAttribute{
+										Optional:            true,
+										MarkdownDescription: "A public key used to verify the signature of JSON Web Tokens (JWTs).",
+										Attributes: map[string]schema.Attribute{
+											"format": schema.StringAttribute{
+												Computed: true,
+												Validators: []validator.String{
+													stringvalidator.OneOf(
+														"RSA_PEM",
+														"RSA_X509_PEM",
+														"ES256_PEM",
+														"ES256_X509_PEM",
+													),
+												},
+												Description: `The format of the key. Possible values: ["RSA_PEM", "RSA_X509_PEM", "ES256_PEM", "ES256_X509_PEM"]`,
+											},
+											"key": schema.StringAttribute{
+												Computed:    true,
+												Description: "The key data.",
+											},
+										},
+									},
+								},
+							},
+						},
+						"last_heartbeat_time": schema.StringAttribute{
+							Computed:            true,
+							MarkdownDescription: "The last time an MQTT PINGREQ was received.",
+						},
+						"last_event_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The last time a telemetry event was received.",
+						},
+						"last_state_time": schema.StringAttribute{
+							Computed:            true,
+							MarkdownDescription: "The last time a state event was received.",
+						},
+						"last_config_ack_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The last time a cloud-to-device config version acknowledgment was received from the device.",
+						},
+						"last_config_send_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The last time a cloud-to-device config version was sent to the device.",
+						},
+						"blocked": schema.BoolAttribute{
+							Computed:    true,
+							Description: "If a device is blocked, connections or requests from this device will fail.",
+						},
+						"last_error_time": schema.StringAttribute{
+							Computed:    true,
+							Description: "The time the most recent error occurred, such as a failure to publish to Cloud Pub/Sub.",
+						},
+						"last_error_status": schema.SingleNestedAttribute{
+							Computed:    true,
+							Description: "The error message of the most recent error, such as a failure to publish to Cloud Pub/Sub.",
+							Attributes: map[string]schema.Attribute{
+								"code": schema.Int64Attribute{
+									Computed:    true,
+									Description: `The status code, which should be an enum value of google.rpc.Code.`,
+								},
+								"message": schema.StringAttribute{
+									Computed:    true,
+									Description: `A developer-facing error message, which should be in English.`,
+								},
+							},
+						},
+						"config": schema.SingleNestedAttribute{
+							Computed:    true,
+							Description: "The most recent device configuration, which is eventually sent from Cloud IoT Core to the device.",
+							Attributes: map[string]schema.Attribute{
+								"version": schema.Int64Attribute{
+									Computed:    true,
+									Description: `The version of this update.`,
+								},
+								"cloud_update_time": schema.StringAttribute{
+									Computed:    true,
+									Description: `The time at which this configuration version was updated in Cloud IoT Core.`,
+								},
+								"device_ack_time": schema.StringAttribute{
+									Computed:    true,
+									Description: `The time at which Cloud IoT Core received the acknowledgment from the device, indicating that the device has received this configuration version.`,
+								},
+								"binary_data": schema.StringAttribute{
+									Computed:    true,
+									Description: `The device configuration data.`,
+								},
+							},
+						},
+						"state": schema.SingleNestedAttribute{
+							Computed:    true,
+							Description: "The state most recently received from the device.",
+							Attributes: map[string]schema.Attribute{
+								"update_time": schema.StringAttribute{
+									Computed:    true,
+									Description: `The time at which this state version was updated in Cloud IoT Core.`,
+								},
+								"binary_data": schema.StringAttribute{
+									Computed:    true,
+									Description: `The device state data.`,
+								},
+							},
+						},
+						"log_level": schema.StringAttribute{
+							Computed: true,
+							Validators: []validator.String{
+								stringvalidator.OneOf(
+									"NONE",
+									"ERROR",
+									"INFO",
+									"DEBUG",
+									"",
+								),
+							},
+							Description: `The logging verbosity for device activity. Possible values: ["NONE", "ERROR", "INFO", "DEBUG"]`,
+						},
+						"metadata": schema.MapAttribute{
+							Computed:    true,
+							ElementType: types.StringType,
+							Description: "The metadata key-value pairs assigned to the device.",
+						},
+						"gateway_config": schema.SingleNestedAttribute{
+							Computed:    true,
+							Description: `Gateway-related configuration and state.`,
+							Attributes: map[string]schema.Attribute{
+								"gateway_type": schema.StringAttribute{
+									Computed: true,
+									Validators: []validator.String{
+										stringvalidator.OneOf(
+											"GATEWAY",
+											"NON_GATEWAY",
+											"",
+										),
+									},
+									Description: `Indicates whether the device is a gateway. Default value: "NON_GATEWAY" Possible values: ["GATEWAY", "NON_GATEWAY"]`,
+								},
+								"gateway_auth_method": schema.StringAttribute{
+									Computed: true,
+									Validators: []validator.String{
+										stringvalidator.OneOf(
+											"ASSOCIATION_ONLY",
+											"DEVICE_AUTH_TOKEN_ONLY",
+											"ASSOCIATION_AND_DEVICE_AUTH_TOKEN",
+										),
+									},
+									Description: `Indicates whether the device is a gateway. Possible values: ["ASSOCIATION_ONLY", "DEVICE_AUTH_TOKEN_ONLY", "ASSOCIATION_AND_DEVICE_AUTH_TOKEN"]`,
+								},
+								"last_accessed_gateway_id": schema.StringAttribute{
+									Computed:    true,
+									Description: `The ID of the gateway the device accessed most recently.`,
+								},
+								"last_accessed_gateway_time": schema.StringAttribute{
+									Computed:    true,
+									Description: `The most recent time at which the device accessed the gateway specified in last_accessed_gateway.`,
+								},
+							},
+						},
+					},
+				},
+			},
+			"registry": schema.StringAttribute{
+				Description: "The name of the device registry where this device should be created.",
+				Required:    true,
+			},
+			"page_size": schema.Int64Attribute{
+				Description: "The maximum number of devices to request per page. All pages are read until max_results is reached. If unset, the server default is used.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"max_results": schema.Int64Attribute{
+				Description: "The maximum number of devices to return. If unset, all devices in the registry are returned.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"device_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "Only list the devices with these user-defined identifiers. Maximum 10,000 IDs.",
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(10000),
+				},
+			},
+			"device_num_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "Only list the devices with these numeric IDs. Maximum 10,000 IDs.",
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(10000),
+					listvalidator.ValueStringsAre(
+						stringvalidator.RegexMatches(regexp.MustCompile(`^[0-9]+$`), "must be a numeric device ID"),
+					),
+				},
+			},
+			"field_mask": schema.StringAttribute{
+				Description: "The fields of each device to return, in snake_case and separated by commas, for example last_heartbeat_time,blocked. The id and num_id fields are always returned. Fields that are not requested are left empty.",
+				Optional:    true,
+			},
+			"gateway_type": schema.StringAttribute{
+				Description: `Only list devices of this gateway type. Possible values: ["GATEWAY", "NON_GATEWAY"]. Conflicts with associations_gateway_id and associations_device_id.`,
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(
+						"GATEWAY",
+						"NON_GATEWAY",
+					),
+					stringvalidator.ConflictsWith(
+						path.MatchRoot("associations_gateway_id"),
+						path.MatchRoot("associations_device_id"),
+					),
+				},
+			},
+			"associations_gateway_id": schema.StringAttribute{
+				Description: "Only list the devices bound to this gateway. The gateway ID can be the user-defined id or the num_id. Conflicts with associations_device_id.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(
+						path.MatchRoot("associations_device_id"),
+					),
+				},
+			},
+			"associations_device_id": schema.StringAttribute{
+				Description: "Only list the gateways this device is bound to. The device ID can be the user-defined id or the num_id.",
+				Optional:    true,
+			},
+			"filter": schema.SingleNestedAttribute{
+				Description: "Filters evaluated by the provider after all pages have been listed. A device is returned only if it matches every filter that is set. The fields filtered on are added to field_mask, and max_results applies to the devices that match.",
+				Optional:    true,
+				Attributes: map[string]schema.Attribute{
+					"metadata": schema.MapAttribute{
+						ElementType: types.StringType,
+						Description: "Metadata key-value pairs the device metadata must contain exactly.",
+						Optional:    true,
+					},
+					"metadata_regex": schema.MapAttribute{
+						ElementType: types.StringType,
+						Description: "Metadata keys mapped to regular expressions the value of that key must match. Devices without the key do not match.",
+						Optional:    true,
+					},
+					"blocked": schema.BoolAttribute{
+						Description: "Only return devices that are, or are not, blocked.",
+						Optional:    true,
+					},
+					"log_level": schema.StringAttribute{
+						Description: `Only return devices with this log level. Possible values: ["NONE", "ERROR", "INFO", "DEBUG"]`,
+						Optional:    true,
+						Validators: []validator.String{
+							stringvalidator.OneOf(
+								"NONE",
+								"ERROR",
+								"INFO",
+								"DEBUG",
+							),
+						},
+					},
+					"last_heartbeat_within": schema.StringAttribute{
+						Description: "Only return devices that sent a heartbeat within this duration, for example 24h.",
+						Optional:    true,
+					},
+					"last_heartbeat_older_than": schema.StringAttribute{
+						Description: "Only return devices whose last heartbeat is older than this duration, for example 72h, including devices that never sent one.",
+						Optional:    true,
+					},
+					"last_error_within": schema.StringAttribute{
+						Description: "Only return devices that reported an error within this duration, for example 24h.",
+						Optional:    true,
+					},
+					"last_error_older_than": schema.StringAttribute{
+						Description: "Only return devices whose last error is older than this duration, for example 168h, including devices that never reported one.",
+						Optional:    true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *devicesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state devicesDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+
+	tflog.Info(ctx, "requesting device listing from Clearblade IoT Core")
+	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString())
+	opts := deviceListOptions{
+		PageSize:              state.PageSize.ValueInt64(),
+		MaxResults:            state.MaxResults.ValueInt64(),
+		FieldMask:             state.FieldMask.ValueString(),
+		GatewayType:           state.GatewayType.ValueString(),
+		AssociationsGatewayID: state.AssociationsGatewayID.ValueString(),
+		AssociationsDeviceID:  state.AssociationsDeviceID.ValueString(),
+	}
+	for _, id := range state.DeviceIDs {
+		opts.DeviceIDs = append(opts.DeviceIDs, id.ValueString())
+	}
+	for _, numID := range state.DeviceNumIDs {
+		n, err := strconv.ParseUint(numID.ValueString(), 10, 64)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("device_num_ids"),
+				"Invalid device numeric ID",
+				"Could not parse device numeric ID "+numID.ValueString()+": "+err.Error(),
+			)
+			return
+		}
+		opts.DeviceNumIDs = append(opts.DeviceNumIDs, n)
+	}
+
+	filter, diags := newDevicesFilter(ctx, state.Filter)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	// A filtered listing is read in full and limited once it is filtered, and
+	// must return the fields the filter evaluates.
+	maxResults := opts.MaxResults
+	if fields := filter.fields(); len(fields) > 0 {
+		opts.MaxResults = 0
+		opts.FieldMask = addFieldMaskPaths(opts.FieldMask, fields)
+	}
+
+	devices, err := listDevices(ctx, d.client, parent, opts)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to read Clearblade IoT Core devices. Make sure your credentials are correct and you have access "+
+				"to the project, or that you have the correct permissions.",
+			err.Error(),
+		)
+		return
+	}
+
+	ctx = tflog.SetField(ctx, "device length", strconv.Itoa(len(devices)))
+
+	// Map response body to model
+	now := time.Now()
+	for _, device := range devices {
+		if maxResults > 0 && int64(len(state.Devices)) >= maxResults {
+			break
+		}
+		if !filter.match(device, now) {
+			continue
+		}
+
+		deviceState := devicesModel{
+			ID:    types.StringValue(device.Id),
+			Name:  types.StringValue(device.Name),
+			NumID: types.StringValue(strconv.FormatUint(device.NumId, 10)),
+		}
+
+		for _, credential := range device.Credentials {
+			if credential.PublicKey == nil {
+				continue
+			}
+			deviceState.Credentials = append(deviceState.Credentials, DevicePublicKeyCertificateModel{
+				ExpirationTime: types.StringValue(credential.ExpirationTime),
+				PublicKey: PublicKeyModel{
+					Format: types.StringValue(credential.PublicKey.Format),
+					Key:    types.StringValue(credential.PublicKey.Key),
+				},
+			})
+		}
+
+		deviceState.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
+		deviceState.LastEventTime = types.StringValue(device.LastEventTime)
+		deviceState.LastStateTime = types.StringValue(device.LastStateTime)
+		deviceState.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
+		deviceState.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
+		deviceState.Blocked = types.BoolValue(device.Blocked)
+		deviceState.LastErrorTime = types.StringValue(device.LastErrorTime)
+
+		// Nested objects are omitted when a field_mask excludes them.
+		if device.LastErrorStatus != nil {
+			deviceState.LastErrorStatus = LastErrorStatusModel{
+				Code:    types.Int64Value(device.LastErrorStatus.Code),
+				Message: types.StringValue(device.LastErrorStatus.Message),
+			}
+		}
+
+		if device.Config != nil {
+			deviceState.Config = ConfigModel{
+				Version:         types.Int64Value(device.Config.Version),
+				CloudUpdateTime: types.StringValue(device.Config.CloudUpdateTime),
+				DeviceAckTime:   types.StringValue(device.Config.DeviceAckTime),
+				BinaryData:      types.StringValue(device.Config.BinaryData),
+			}
+		}
+
+		if device.State != nil {
+			deviceState.State = StateModel{
+				UpdateTime: types.StringValue(device.State.UpdateTime),
+				BinaryData: types.StringValue(device.State.BinaryData),
+			}
+		}
+
+		deviceState.LogLevel = types.StringValue(device.LogLevel)
+
+		deviceState.Metadata = flattenDeviceMetadata(device.Metadata, types.MapNull(types.StringType))
+
+		if device.GatewayConfig != nil {
+			deviceState.GatewayConfig = GatewayConfigModel{
+				GatewayType:             types.StringValue(device.GatewayConfig.GatewayType),
+				GatewayAuthMethod:       types.StringValue(device.GatewayConfig.GatewayAuthMethod),
+				LastAccessedGatewayID:   types.StringValue(device.GatewayConfig.LastAccessedGatewayId),
+				LastAccessedGatewayTime: types.StringValue(device.GatewayConfig.LastAccessedGatewayTime),
+			}
+		}
+
+		state.Devices = append(state.Devices, deviceState)
+	}
+
+	// Set state
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+func (d *devicesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_devices"
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *devicesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*providerData).client
+}
+
+// deviceListOptions narrows a device listing on the server side.
+type deviceListOptions struct {
+	PageSize   int64
+	MaxResults int64
+
+	DeviceIDs             []string
+	DeviceNumIDs          []uint64
+	FieldMask             string
+	GatewayType           string
+	AssociationsGatewayID string
+	AssociationsDeviceID  string
+}
+
+// listDevices reads every page of devices in the registry parent matching
+// opts, stopping early once opts.MaxResults devices have been read when it
+// is positive.
+func listDevices(ctx context.Context, client *iot.Service, parent string, opts deviceListOptions) ([]*iot.Device, error) {
+	call := client.Projects.Locations.Registries.Devices.List(parent)
+	if opts.PageSize > 0 {
+		call = call.PageSize(opts.PageSize)
+	}
+	if len(opts.DeviceIDs) > 0 {
+		call = call.DeviceIds(opts.DeviceIDs...)
+	}
+	if len(opts.DeviceNumIDs) > 0 {
+		call = call.DeviceNumIds(opts.DeviceNumIDs...)
+	}
+	if opts.FieldMask != "" {
+		call = call.FieldMask(opts.FieldMask)
+	}
+	if opts.GatewayType != "" {
+		call = call.GatewayListOptionsGatewayType(opts.GatewayType)
+	}
+	if opts.AssociationsGatewayID != "" {
+		call = call.GatewayListOptionsAssociationsGatewayId(opts.AssociationsGatewayID)
+	}
+	if opts.AssociationsDeviceID != "" {
+		call = call.GatewayListOptionsAssociationsDeviceId(opts.AssociationsDeviceID)
+	}
+
+	maxResults := opts.MaxResults
+	devices := []*iot.Device{}
+	err := call.Pages(ctx, func(page *iot.ListDevicesResponse) error {
+		devices = append(devices, page.Devices...)
+		if maxResults > 0 && int64(len(devices)) >= maxResults {
+			return errPagingDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPagingDone) {
+		return nil, err
+	}
+
+	if maxResults > 0 && int64(len(devices)) > maxResults {
+		devices = devices[:maxResults]
+	}
+	return devices, nil
+}
+
+// devicesFilter is the compiled form of devicesFilterModel.
+type devicesFilter struct {
+	metadata               map[string]string
+	metadataRegex          map[string]*regexp.Regexp
+	blocked                *bool
+	logLevel               string
+	lastHeartbeatWithin    time.Duration
+	lastHeartbeatOlderThan time.Duration
+	lastErrorWithin        time.Duration
+	lastErrorOlderThan     time.Duration
+}
+
+// newDevicesFilter validates and compiles the filter. A nil model yields a
+// filter that matches every device.
+func newDevicesFilter(ctx context.Context, model *devicesFilterModel) (*devicesFilter, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	filter := &devicesFilter{}
+	if model == nil {
+		return filter, diags
+	}
+
+	if !model.Metadata.IsNull() {
+		diags.Append(model.Metadata.ElementsAs(ctx, &filter.metadata, false)...)
+	}
+
+	if !model.MetadataRegex.IsNull() {
+		expressions := map[string]string{}
+		diags.Append(model.MetadataRegex.ElementsAs(ctx, &expressions, false)...)
+		filter.metadataRegex = map[string]*regexp.Regexp{}
+		for key, expression := range expressions {
+			re, err := regexp.Compile(expression)
+			if err != nil {
+				diags.AddAttributeError(
+					path.Root("filter").AtName("metadata_regex").AtMapKey(key),
+					"Invalid regular expression",
+					"Could not compile the regular expression for metadata key "+key+": "+err.Error(),
+				)
+				continue
+			}
+			filter.metadataRegex[key] = re
+		}
+	}
+
+	if !model.Blocked.IsNull() {
+		blocked := model.Blocked.ValueBool()
+		filter.blocked = &blocked
+	}
+	filter.logLevel = model.LogLevel.ValueString()
+
+	durations := []struct {
+		name  string
+		value types.String
+		dest  *time.Duration
+	}{
+		{"last_heartbeat_within", model.LastHeartbeatWithin, &filter.lastHeartbeatWithin},
+		{"last_heartbeat_older_than", model.LastHeartbeatOlderThan, &filter.lastHeartbeatOlderThan},
+		{"last_error_within", model.LastErrorWithin, &filter.lastErrorWithin},
+		{"last_error_older_than", model.LastErrorOlderThan, &filter.lastErrorOlderThan},
+	}
+	for _, d := range durations {
+		if d.value.IsNull() {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value.ValueString())
+		if err != nil || duration <= 0 {
+			diags.AddAttributeError(
+				path.Root("filter").AtName(d.name),
+				"Invalid duration",
+				"Expected a positive duration such as 24h or 90m, got: "+d.value.ValueString(),
+			)
+			continue
+		}
+		*d.dest = duration
+	}
+
+	return filter, diags
+}
+
+// fields returns the snake_case device fields the filter evaluates, which a
+// listing must return for the filter to see them. It is empty when the filter
+// matches every device.
+func (f *devicesFilter) fields() []string {
+	var fields []string
+	if len(f.metadata) > 0 || len(f.metadataRegex) > 0 {
+		fields = append(fields, "metadata")
+	}
+	if f.blocked != nil {
+		fields = append(fields, "blocked")
+	}
+	if f.logLevel != "" {
+		fields = append(fields, "log_level")
+	}
+	if f.lastHeartbeatWithin > 0 || f.lastHeartbeatOlderThan > 0 {
+		fields = append(fields, "last_heartbeat_time")
+	}
+	if f.lastErrorWithin > 0 || f.lastErrorOlderThan > 0 {
+		fields = append(fields, "last_error_time")
+	}
+	return fields
+}
+
+// addFieldMaskPaths returns the comma-separated field mask with each of
+// paths appended unless the mask already selects it.
+func addFieldMaskPaths(mask string, paths []string) string {
+	selected := map[string]bool{}
+	var result []string
+	for _, p := range strings.Split(mask, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		selected[p] = true
+		result = append(result, p)
+	}
+	for _, p := range paths {
+		if !selected[p] {
+			result = append(result, p)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// match reports whether the device satisfies every predicate of the filter.
+func (f *devicesFilter) match(device *iot.Device, now time.Time) bool {
+	for key, want := range f.metadata {
+		got, ok := device.Metadata[key]
+		if !ok || got != want {
+			return false
+		}
+	}
+	for key, re := range f.metadataRegex {
+		got, ok := device.Metadata[key]
+		if !ok || !re.MatchString(got) {
+			return false
+		}
+	}
+	if f.blocked != nil && device.Blocked != *f.blocked {
+		return false
+	}
+	if f.logLevel != "" && device.LogLevel != f.logLevel {
+		return false
+	}
+	return matchTimeWindow(device.LastHeartbeatTime, now, f.lastHeartbeatWithin, f.lastHeartbeatOlderThan) &&
+		matchTimeWindow(device.LastErrorTime, now, f.lastErrorWithin, f.lastErrorOlderThan)
+}
+
+// matchTimeWindow reports whether timestamp lies within the last within
+// duration and before the last olderThan duration, ignoring unset bounds. An
+// empty or unparsable timestamp never matches within and always matches
+// olderThan.
+func matchTimeWindow(timestamp string, now time.Time, within, olderThan time.Duration) bool {
+	if within == 0 && olderThan == 0 {
+		return true
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return within == 0
+	}
+
+	if within > 0 && t.Before(now.Add(-within)) {
+		return false
+	}
+	if olderThan > 0 && t.After(now.Add(-olderThan)) {
+		return false
+	}
+	return true
+}
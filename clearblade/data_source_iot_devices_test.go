@@ -0,0 +1,227 @@
+package clearblade
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccDevicesDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	registry := testAccLocation + "/registries/test-registry"
+	for i := 0; i < 5; i++ {
+		device := &iot.Device{Id: fmt.Sprintf("device-%d", i), Blocked: i%2 == 1}
+		if err := server.PutDevice(registry, device); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gateway := &iot.Device{Id: "gateway", GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY", GatewayAuthMethod: "ASSOCIATION_ONLY"}}
+	if err := server.PutDevice(registry, gateway); err != nil {
+		t.Fatal(err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_devices" "all" {
+  registry  = "test-registry"
+  page_size = 2
+}
+
+data "clearblade_devices" "limited" {
+  registry    = "test-registry"
+  max_results = 3
+}
+
+data "clearblade_devices" "selected" {
+  registry   = "test-registry"
+  device_ids = ["device-1", "device-3"]
+  field_mask = "blocked"
+}
+
+data "clearblade_devices" "gateways" {
+  registry     = "test-registry"
+  gateway_type = "GATEWAY"
+  field_mask   = "gateway_config"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.clearblade_devices.all", "devices.#", "6"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.limited", "devices.#", "3"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.selected", "devices.#", "2"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.clearblade_devices.selected", "devices.*", map[string]string{
+						"id":      "device-3",
+						"blocked": "true",
+					}),
+					resource.TestCheckResourceAttr("data.clearblade_devices.gateways", "devices.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.gateways", "devices.0.id", "gateway"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.gateways", "devices.0.gateway_config.gateway_auth_method", "ASSOCIATION_ONLY"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDevicesDataSource_filter(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	registry := testAccLocation + "/registries/test-registry"
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for i := 0; i < 6; i++ {
+		site := "north"
+		if i%2 == 1 {
+			site = "south"
+		}
+		device := &iot.Device{
+			Id:       fmt.Sprintf("device-%d", i),
+			Blocked:  i >= 4,
+			LogLevel: "INFO",
+			Metadata: map[string]string{"site": site},
+		}
+		if i < 3 {
+			device.LastHeartbeatTime = recent
+		}
+		if err := server.PutDevice(registry, device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				// None of the data sources sets a field_mask that includes
+				// the fields filtered on.
+				Config: testAccProviderConfig(server) + `
+data "clearblade_devices" "south" {
+  registry = "test-registry"
+
+  filter = {
+    metadata = {
+      site = "south"
+    }
+  }
+}
+
+data "clearblade_devices" "blocked" {
+  registry   = "test-registry"
+  field_mask = "log_level"
+
+  filter = {
+    blocked = true
+  }
+}
+
+data "clearblade_devices" "limited" {
+  registry    = "test-registry"
+  page_size   = 1
+  max_results = 2
+
+  filter = {
+    metadata_regex = {
+      site = "^s"
+    }
+  }
+}
+
+data "clearblade_devices" "silent" {
+  registry = "test-registry"
+
+  filter = {
+    last_heartbeat_older_than = "24h"
+    log_level                 = "INFO"
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.clearblade_devices.south", "devices.#", "3"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.clearblade_devices.south", "devices.*", map[string]string{
+						"id":            "device-1",
+						"metadata.site": "south",
+					}),
+					resource.TestCheckResourceAttr("data.clearblade_devices.blocked", "devices.#", "2"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.clearblade_devices.blocked", "devices.*", map[string]string{
+						"id":        "device-4",
+						"blocked":   "true",
+						"log_level": "INFO",
+					}),
+					// The devices that match are limited, not the devices listed.
+					resource.TestCheckResourceAttr("data.clearblade_devices.limited", "devices.#", "2"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.limited", "devices.0.id", "device-1"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.limited", "devices.1.id", "device-3"),
+					resource.TestCheckResourceAttr("data.clearblade_devices.silent", "devices.#", "3"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.clearblade_devices.silent", "devices.*", map[string]string{
+						"id": "device-5",
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestDevicesFilter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	hoursAgo := func(h int) string {
+		return now.Add(-time.Duration(h) * time.Hour).Format(time.RFC3339)
+	}
+	device := &iot.Device{
+		Blocked:           true,
+		LogLevel:          "DEBUG",
+		Metadata:          map[string]string{"site": "plant-1", "legacy": `"old"`},
+		LastHeartbeatTime: hoursAgo(2),
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter devicesFilter
+		fields []string
+		want   bool
+	}{
+		{"empty", devicesFilter{}, nil, true},
+		{"metadata", devicesFilter{metadata: map[string]string{"site": "plant-1"}}, []string{"metadata"}, true},
+		// Values quoted by earlier versions of the provider match verbatim.
+		{"quoted metadata", devicesFilter{metadata: map[string]string{"legacy": `"old"`}}, []string{"metadata"}, true},
+		{"unquoted metadata", devicesFilter{metadata: map[string]string{"legacy": "old"}}, []string{"metadata"}, false},
+		{"other metadata", devicesFilter{metadata: map[string]string{"site": "plant-2"}}, []string{"metadata"}, false},
+		{"missing metadata", devicesFilter{metadata: map[string]string{"floor": "1"}}, []string{"metadata"}, false},
+		{"metadata regex", devicesFilter{metadataRegex: map[string]*regexp.Regexp{"site": regexp.MustCompile(`^plant-\d$`)}}, []string{"metadata"}, true},
+		{"blocked", devicesFilter{blocked: new(bool)}, []string{"blocked"}, false},
+		{"log level", devicesFilter{logLevel: "DEBUG"}, []string{"log_level"}, true},
+		{"heartbeat within", devicesFilter{lastHeartbeatWithin: time.Hour}, []string{"last_heartbeat_time"}, false},
+		{"heartbeat older than", devicesFilter{lastHeartbeatOlderThan: time.Hour}, []string{"last_heartbeat_time"}, true},
+		{"never reported an error", devicesFilter{lastErrorOlderThan: time.Hour}, []string{"last_error_time"}, true},
+		{"no error within", devicesFilter{lastErrorWithin: time.Hour}, []string{"last_error_time"}, false},
+	} {
+		if got := tc.filter.match(device, now); got != tc.want {
+			t.Errorf("%s: match = %t, want %t", tc.name, got, tc.want)
+		}
+		if got := tc.filter.fields(); strings.Join(got, ",") != strings.Join(tc.fields, ",") {
+			t.Errorf("%s: fields = %q, want %q", tc.name, got, tc.fields)
+		}
+	}
+}
+
+func TestAddFieldMaskPaths(t *testing.T) {
+	for _, tc := range []struct {
+		mask  string
+		paths []string
+		want  string
+	}{
+		{"", []string{"metadata", "blocked"}, "metadata,blocked"},
+		{"blocked", []string{"metadata", "blocked"}, "blocked,metadata"},
+		{"log_level, blocked", []string{"blocked"}, "log_level,blocked"},
+		{"log_level", nil, "log_level"},
+	} {
+		if got := addFieldMaskPaths(tc.mask, tc.paths); got != tc.want {
+			t.Errorf("addFieldMaskPaths(%q, %q) = %q, want %q", tc.mask, tc.paths, got, tc.want)
+		}
+	}
+}
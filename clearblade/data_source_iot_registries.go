@@ -0,0 +1,428 @@
+package clearblade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &deviceRegistriesDataSource{}
+	_ datasource.DataSourceWithConfigure = &deviceRegistriesDataSource{}
+)
+
+// deviceRegistriesDataSourceModel maps the data source schema data.
+type deviceRegistriesDataSourceModel struct {
+	DeviceRegistries []deviceRegistriesModel `tfsdk:"device_registries"`
+	PageSize         types.Int64             `tfsdk:"page_size"`
+	MaxResults       types.Int64             `tfsdk:"max_results"`
+	NameRegex        types.String            `tfsdk:"name_regex"`
+	IDs              types.List              `tfsdk:"ids"`
+	RegistryIDs      types.List              `tfsdk:"registry_ids"`
+	MqttEnabledState types.String            `tfsdk:"mqtt_enabled_state"`
+	HttpEnabledState types.String            `tfsdk:"http_enabled_state"`
+	LogLevel         types.String            `tfsdk:"log_level"`
+}
+
+// deviceRegistriesModel maps deviceRegistry schema data.
+type deviceRegistriesModel struct {
+	ID                       types.String                    `tfsdk:"id"`
+	Name                     types.String                    `tfsdk:"name"`
+	EventNotificationConfigs []EventNotificationConfigsModel `tfsdk:"event_notification_configs"`
+	StateNotificationConfig  StateNotificationConfigModel    `tfsdk:"state_notification_config"`
+	HttpConfig               HttpConfigModel                 `tfsdk:"http_config"`
+	MqttConfig               MqttConfigModel                 `tfsdk:"mqtt_config"`
+	LogLevel                 types.String                    `tfsdk:"log_level"`
+	Credentials              []CredentialsModel              `tfsdk:"credentials"`
+}
+
+func NewDeviceRegistriesDataSource() datasource.DataSource {
+	return &deviceRegistriesDataSource{}
+}
+
+type deviceRegistriesDataSource struct {
+	client *iot.Service
+}
+
+func (d *deviceRegistriesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_registries"
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *deviceRegistriesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*providerData).client
+}
+
+func (d *deviceRegistriesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "List of device registries in a project.",
+		Attributes: map[string]schema.Attribute{
+			"page_size": schema.Int64Attribute{
+				Description: "The maximum number of registries to request per page. All pages are read until max_results is reached. If unset, the server default is used.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"max_results": schema.Int64Attribute{
+				Description: "The maximum number of registries to return. It applies to the registries that match the filters. If unset, all registries are returned.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"name_regex": schema.StringAttribute{
+				Description: "Only return registries whose ID matches this regular expression, for example ^bas-.",
+				Optional:    true,
+			},
+			"ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "Only return registries with these IDs.",
+				Optional:    true,
+			},
+			"registry_ids": schema.ListAttribute{
+				ElementType: types.StringType,
+				Description: "The IDs of the returned registries, in the order they are listed.",
+				Computed:    true,
+			},
+			"mqtt_enabled_state": schema.StringAttribute{
+				Description: `Only return registries with this MQTT state. Possible values: ["MQTT_ENABLED", "MQTT_DISABLED"]`,
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(
+						"MQTT_ENABLED",
+						"MQTT_DISABLED",
+					),
+				},
+			},
+			"http_enabled_state": schema.StringAttribute{
+				Description: `Only return registries with this HTTP state. Possible values: ["HTTP_ENABLED", "HTTP_DISABLED"]`,
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(
+						"HTTP_ENABLED",
+						"HTTP_DISABLED",
+					),
+				},
+			},
+			"log_level": schema.StringAttribute{
+				Description: `Only return registries with this default log level. Possible values: ["NONE", "ERROR", "INFO", "DEBUG"]`,
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(
+						"NONE",
+						"ERROR",
+						"INFO",
+						"DEBUG",
+					),
+				},
+			},
+			"device_registries": schema.ListNestedAttribute{
+				Computed: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							Computed: true,
+						},
+						"name": schema.StringAttribute{
+							Computed: true,
+						},
+						"event_notification_configs": schema.ListNestedAttribute{
+							Optional: true,
+							// Computed: true,
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"pubsub_topic_name": schema.StringAttribute{
+										Required:    true,
+										Description: "A Cloud Pub/Sub topic name. For example, projects/myProject/topics/deviceEvents.",
+									},
+									"sub_folder_matches": schema.StringAttribute{
+										Optional:    true,
+										Computed:    true,
+										Description: "This field is used only for telemetry events; subfolders are not supported for state changes.",
+									},
+								},
+							},
+						},
+						"state_notification_config": schema.SingleNestedAttribute{
+							Required:    true,
+							Description: "The configuration for notification of new states received from the device.",
+							Attributes: map[string]schema.Attribute{
+								"pubsub_topic_name": schema.StringAttribute{
+									Description: "A Cloud Pub/Sub topic name. For example, projects/myProject/topics/deviceEvents.",
+									Computed:    true,
+								},
+							},
+						},
+						"http_config": schema.SingleNestedAttribute{
+							Required:    true,
+							Description: "The configuration of the HTTP bridge for a device registry.",
+							Attributes: map[string]schema.Attribute{
+								"http_enabled_state": schema.StringAttribute{
+									Description: "If enabled, allows devices to use DeviceService via the HTTP protocol. Otherwise, any requests to DeviceService will fail for this registry.",
+									Computed:    true,
+								},
+							},
+						},
+						"mqtt_config": schema.SingleNestedAttribute{
+							Required:    true,
+							Description: "The configuration of MQTT for a device registry.",
+							Attributes: map[string]schema.Attribute{
+								"mqtt_enabled_state": schema.StringAttribute{
+									Description: "If enabled, allows connections using the MQTT protocol. Otherwise, MQTT connections to this registry will fail.",
+									Computed:    true,
+								},
+							},
+						},
+						"log_level": schema.StringAttribute{
+							Description: "The logging verbosity for device activity. Specifies which events should be written to logs.",
+							Computed:    true,
+						},
+						"credentials": schema.ListNestedAttribute{
+							Optional: true,
+							Computed: true,
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"public_key_certificate": schema.SingleNestedAttribute{
+										Optional:    true,
+										Description: "A public key certificate format and data.",
+										Attributes: map[string]schema.Attribute{
+											"format": schema.StringAttribute{
+												Description: "The certificate format.",
+												Computed:    true,
+											},
+											"certificate": schema.StringAttribute{
+												Description: "The certificate data.",
+												Computed:    true,
+											},
+											"x509_details": schema.SingleNestedAttribute{
+												Optional:    true,
+												Description: "Details of an X.509 certificate.",
+												Attributes: map[string]schema.Attribute{
+													"issuer": schema.StringAttribute{
+														Description: "The entity that signed the certificate.",
+														Computed:    true,
+													},
+													"subject": schema.StringAttribute{
+														Description: "The entity the certificate and public key belong to.",
+														Computed:    true,
+													},
+													"start_time": schema.StringAttribute{
+														Description: "The time the certificate becomes valid.",
+														Computed:    true,
+													},
+													"expiry_time": schema.StringAttribute{
+														Description: "The time the certificate becomes invalid.",
+														Computed:    true,
+													},
+													"signature_algorithm": schema.StringAttribute{
+														Description: "The algorithm used to sign the certificate.",
+														Computed:    true,
+													},
+													"public_key_type": schema.StringAttribute{
+														Description: "The type of public key in the certificate.",
+														Computed:    true,
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *deviceRegistriesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state deviceRegistriesDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filter := registriesFilter{
+		mqttEnabledState: state.MqttEnabledState.ValueString(),
+		httpEnabledState: state.HttpEnabledState.ValueString(),
+		logLevel:         state.LogLevel.ValueString(),
+	}
+	if !state.NameRegex.IsNull() {
+		re, err := regexp.Compile(state.NameRegex.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("name_regex"),
+				"Invalid regular expression",
+				"Could not compile name_regex: "+err.Error(),
+			)
+			return
+		}
+		filter.nameRegex = re
+	}
+	if !state.IDs.IsNull() && !state.IDs.IsUnknown() {
+		var ids []string
+		resp.Diagnostics.Append(state.IDs.ElementsAs(ctx, &ids, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		filter.ids = map[string]bool{}
+		for _, id := range ids {
+			filter.ids[id] = true
+		}
+	}
+
+	tflog.Info(ctx, "requesting device registry listing from Clearblade IoT Core")
+	parent := fmt.Sprintf("projects/%s/locations/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"))
+	// A filtered listing is read in full and limited once it is filtered.
+	maxResults := state.MaxResults.ValueInt64()
+	listMaxResults := maxResults
+	if !filter.empty() {
+		listMaxResults = 0
+	}
+	registries, err := listRegistries(ctx, d.client, parent, state.PageSize.ValueInt64(), listMaxResults)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to read Clearblade IoT Core device registries. Make sure your credentials are correct and you have access "+
+				"to the project, or that you have the correct permissions.",
+			err.Error(),
+		)
+		return
+	}
+	tflog.Info(ctx, "device registry")
+	tflog.Info(ctx, strconv.Itoa(len(registries)))
+
+	// Map response body to model
+	ids := []string{}
+	for _, registry := range registries {
+		if maxResults > 0 && int64(len(ids)) >= maxResults {
+			break
+		}
+		if !filter.match(registry) {
+			continue
+		}
+		ids = append(ids, registry.Id)
+
+		registryState := deviceRegistriesModel{
+			ID:       types.StringValue(registry.Id),
+			LogLevel: types.StringValue(registry.LogLevel),
+			Name:     types.StringValue(registry.Name),
+		}
+		if registry.HttpConfig != nil {
+			registryState.HttpConfig.HttpEnabledState = types.StringValue(registry.HttpConfig.HttpEnabledState)
+		}
+		if registry.MqttConfig != nil {
+			registryState.MqttConfig.MqttEnabledState = types.StringValue(registry.MqttConfig.MqttEnabledState)
+		}
+		if registry.StateNotificationConfig != nil {
+			registryState.StateNotificationConfig.PubsubTopicName = types.StringValue(registry.StateNotificationConfig.PubsubTopicName)
+		}
+
+		registryState.Credentials = flattenRegistryCredentials(ctx, registry.Credentials)
+
+		for _, eventNotificationConfig := range registry.EventNotificationConfigs {
+			registryState.EventNotificationConfigs = append(registryState.EventNotificationConfigs, EventNotificationConfigsModel{
+				PubsubTopicName:  types.StringValue(eventNotificationConfig.PubsubTopicName),
+				SubfolderMatches: types.StringValue(eventNotificationConfig.SubfolderMatches),
+			})
+		}
+
+		state.DeviceRegistries = append(state.DeviceRegistries, registryState)
+	}
+
+	idList, diags := types.ListValueFrom(ctx, types.StringType, ids)
+	resp.Diagnostics.Append(diags...)
+	state.RegistryIDs = idList
+
+	// Set state
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+}
+
+// registriesFilter selects registries on their ID and settings. Unset
+// fields match every registry.
+type registriesFilter struct {
+	nameRegex        *regexp.Regexp
+	ids              map[string]bool
+	mqttEnabledState string
+	httpEnabledState string
+	logLevel         string
+}
+
+// empty reports whether the filter matches every registry.
+func (f registriesFilter) empty() bool {
+	return f.nameRegex == nil && f.ids == nil && f.mqttEnabledState == "" && f.httpEnabledState == "" && f.logLevel == ""
+}
+
+// match reports whether the registry satisfies every set field of the filter.
+func (f registriesFilter) match(registry *iot.DeviceRegistry) bool {
+	if f.nameRegex != nil && !f.nameRegex.MatchString(registry.Id) {
+		return false
+	}
+	if f.ids != nil && !f.ids[registry.Id] {
+		return false
+	}
+	if f.mqttEnabledState != "" && (registry.MqttConfig == nil || registry.MqttConfig.MqttEnabledState != f.mqttEnabledState) {
+		return false
+	}
+	if f.httpEnabledState != "" && (registry.HttpConfig == nil || registry.HttpConfig.HttpEnabledState != f.httpEnabledState) {
+		return false
+	}
+	if f.logLevel != "" && registry.LogLevel != f.logLevel {
+		return false
+	}
+	return true
+}
+
+// errPagingDone stops a Pages call once enough results have been read.
+var errPagingDone = errors.New("paging done")
+
+// listRegistries reads every page of device registries under parent, stopping
+// early once maxResults registries have been read when maxResults is positive.
+func listRegistries(ctx context.Context, client *iot.Service, parent string, pageSize, maxResults int64) ([]*iot.DeviceRegistry, error) {
+	call := client.Projects.Locations.Registries.List(parent)
+	if pageSize > 0 {
+		call = call.PageSize(pageSize)
+	}
+
+	registries := []*iot.DeviceRegistry{}
+	err := call.Pages(ctx, func(page *iot.ListDeviceRegistriesResponse) error {
+		registries = append(registries, page.DeviceRegistries...)
+		if maxResults > 0 && int64(len(registries)) >= maxResults {
+			return errPagingDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPagingDone) {
+		return nil, err
+	}
+
+	if maxResults > 0 && int64(len(registries)) > maxResults {
+		registries = registries[:maxResults]
+	}
+	return registries, nil
+}
@@ -0,0 +1,147 @@
+package clearblade
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccRegistriesDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "bas-a", LogLevel: "INFO"})
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "bas-b", MqttConfig: &iot.MqttConfig{MqttEnabledState: "MQTT_DISABLED"}})
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "other", HttpConfig: &iot.HttpConfig{HttpEnabledState: "HTTP_DISABLED"}})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_registries" "all" {
+  page_size = 2
+}
+
+data "clearblade_registries" "bas" {
+  name_regex = "^bas-"
+}
+
+data "clearblade_registries" "mqtt" {
+  name_regex         = "^bas-"
+  mqtt_enabled_state = "MQTT_ENABLED"
+}
+
+data "clearblade_registries" "mqtt_disabled" {
+  mqtt_enabled_state = "MQTT_DISABLED"
+}
+
+data "clearblade_registries" "http_disabled" {
+  http_enabled_state = "HTTP_DISABLED"
+}
+
+data "clearblade_registries" "selected" {
+  ids = ["other", "bas-b", "missing"]
+}
+
+# max_results applies to the matching registries, not to the listing.
+data "clearblade_registries" "last" {
+  page_size   = 1
+  max_results = 1
+  name_regex  = "^other$"
+}
+
+data "clearblade_registries" "first_bas" {
+  max_results = 1
+  name_regex  = "^bas-"
+}
+
+data "clearblade_registries" "none" {
+  ids       = ["bas-a"]
+  log_level = "DEBUG"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.clearblade_registries.all", "device_registries.#", "3"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.all", "registry_ids.#", "3"),
+					resource.TestCheckNoResourceAttr("data.clearblade_registries.all", "ids"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.bas", "registry_ids.#", "2"),
+					resource.TestCheckTypeSetElemAttr("data.clearblade_registries.bas", "registry_ids.*", "bas-a"),
+					resource.TestCheckTypeSetElemAttr("data.clearblade_registries.bas", "registry_ids.*", "bas-b"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.mqtt", "device_registries.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.mqtt", "device_registries.0.id", "bas-a"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.mqtt", "device_registries.0.log_level", "INFO"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.mqtt_disabled", "registry_ids.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.mqtt_disabled", "registry_ids.0", "bas-b"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.http_disabled", "registry_ids.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.http_disabled", "registry_ids.0", "other"),
+					// The configured IDs are kept as they were written.
+					resource.TestCheckResourceAttr("data.clearblade_registries.selected", "ids.#", "3"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.selected", "ids.2", "missing"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.selected", "registry_ids.#", "2"),
+					resource.TestCheckTypeSetElemAttr("data.clearblade_registries.selected", "registry_ids.*", "bas-b"),
+					resource.TestCheckTypeSetElemAttr("data.clearblade_registries.selected", "registry_ids.*", "other"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.last", "registry_ids.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.last", "registry_ids.0", "other"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.first_bas", "registry_ids.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.first_bas", "device_registries.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.none", "ids.#", "1"),
+					resource.TestCheckResourceAttr("data.clearblade_registries.none", "registry_ids.#", "0"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_registries" "invalid" {
+  name_regex = "(bas"
+}
+`,
+				ExpectError: regexp.MustCompile(`Could not compile name_regex`),
+			},
+		},
+	})
+}
+
+func TestRegistriesFilter(t *testing.T) {
+	registry := &iot.DeviceRegistry{
+		Id:         "bas-a",
+		LogLevel:   "INFO",
+		MqttConfig: &iot.MqttConfig{MqttEnabledState: "MQTT_ENABLED"},
+		HttpConfig: &iot.HttpConfig{HttpEnabledState: "HTTP_DISABLED"},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter registriesFilter
+		want   bool
+	}{
+		{"empty", registriesFilter{}, true},
+		{"name regex", registriesFilter{nameRegex: regexp.MustCompile(`^bas-`)}, true},
+		{"other name regex", registriesFilter{nameRegex: regexp.MustCompile(`^lab-`)}, false},
+		{"ids", registriesFilter{ids: map[string]bool{"bas-a": true}}, true},
+		{"other ids", registriesFilter{ids: map[string]bool{"bas-b": true}}, false},
+		{"no ids", registriesFilter{ids: map[string]bool{}}, false},
+		{"mqtt enabled", registriesFilter{mqttEnabledState: "MQTT_ENABLED"}, true},
+		{"mqtt disabled", registriesFilter{mqttEnabledState: "MQTT_DISABLED"}, false},
+		{"http disabled", registriesFilter{httpEnabledState: "HTTP_DISABLED"}, true},
+		{"http enabled", registriesFilter{httpEnabledState: "HTTP_ENABLED"}, false},
+		{"log level", registriesFilter{logLevel: "DEBUG"}, false},
+		{"every field", registriesFilter{
+			nameRegex:        regexp.MustCompile(`-a$`),
+			ids:              map[string]bool{"bas-a": true},
+			mqttEnabledState: "MQTT_ENABLED",
+			httpEnabledState: "HTTP_DISABLED",
+			logLevel:         "INFO",
+		}, true},
+	} {
+		if got := tc.filter.match(registry); got != tc.want {
+			t.Errorf("%s: match = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+
+	if !(registriesFilter{}).empty() || (registriesFilter{ids: map[string]bool{}}).empty() {
+		t.Error("empty reports the wrong filters as empty")
+	}
+	if (registriesFilter{mqttEnabledState: "MQTT_ENABLED"}).match(&iot.DeviceRegistry{Id: "bare"}) {
+		t.Error("a registry without an MQTT config matched an MQTT state")
+	}
+}
@@ -2,173 +2,155 @@ package clearblade
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/clearblade/go-iot"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
 var (
-	_ datasource.DataSource              = &deviceRegistriesDataSource{}
-	_ datasource.DataSourceWithConfigure = &deviceRegistriesDataSource{}
+	_ datasource.DataSource              = &deviceRegistryDataSource{}
+	_ datasource.DataSourceWithConfigure = &deviceRegistryDataSource{}
 )
 
-// deviceRegistriesDataSourceModel maps the data source schema data.
-type deviceRegistriesDataSourceModel struct {
-	DeviceRegistries []deviceRegistriesModel `tfsdk:"device_registries"`
+func NewDeviceRegistryDataSource() datasource.DataSource {
+	return &deviceRegistryDataSource{}
 }
 
-// deviceRegistriesModel maps deviceRegistry schema data.
-type deviceRegistriesModel struct {
-	ID                       types.String                    `tfsdk:"id"`
-	Name                     types.String                    `tfsdk:"name"`
-	EventNotificationConfigs []EventNotificationConfigsModel `tfsdk:"event_notification_configs"`
-	StateNotificationConfig  StateNotificationConfigModel    `tfsdk:"state_notification_config"`
-	HttpConfig               HttpConfigModel                 `tfsdk:"http_config"`
-	MqttConfig               MqttConfigModel                 `tfsdk:"mqtt_config"`
-	LogLevel                 types.String                    `tfsdk:"log_level"`
-	Credentials              []CredentialsModel              `tfsdk:"credentials"`
-}
-
-func NewDeviceRegistriesDataSource() datasource.DataSource {
-	return &deviceRegistriesDataSource{}
-}
-
-type deviceRegistriesDataSource struct {
+type deviceRegistryDataSource struct {
 	client *iot.Service
 }
 
-func (d *deviceRegistriesDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
-	resp.TypeName = req.ProviderTypeName + "_registries"
+func (d *deviceRegistryDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_registry"
 }
 
 // Configure adds the provider configured client to the data source.
-func (d *deviceRegistriesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+func (d *deviceRegistryDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
 	if req.ProviderData == nil {
 		return
 	}
 
-	d.client = req.ProviderData.(*iot.Service)
+	d.client = req.ProviderData.(*providerData).client
 }
 
-func (d *deviceRegistriesDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+func (d *deviceRegistryDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		Description: "List of device registries in a project.",
+		Description: "Get a single device registry by its identifier.",
 		Attributes: map[string]schema.Attribute{
-			"device_registries": schema.ListNestedAttribute{
-				Computed: true,
+			"id": schema.StringAttribute{
+				Required:    true,
+				Description: "The identifier of the device registry, for example myRegistry, or its full resource path, for example projects/example-project/locations/us-central1/registries/myRegistry.",
+			},
+			"name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The resource path name. For example, projects/example-project/locations/us-central1/registries/my-registry.",
+			},
+			"event_notification_configs": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The configuration for notification of telemetry events received from the device.",
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
-						"id": schema.StringAttribute{
-							Computed: true,
-						},
-						"name": schema.StringAttribute{
-							Computed: true,
+						"pubsub_topic_name": schema.StringAttribute{
+							Computed:    true,
+							Description: "A Cloud Pub/Sub topic name. For example, projects/myProject/topics/deviceEvents.",
 						},
-						"event_notification_configs": schema.ListNestedAttribute{
-							Optional: true,
-							// Computed: true,
-							NestedObject: schema.NestedAttributeObject{
-								Attributes: map[string]schema.Attribute{
-									"pubsub_topic_name": schema.StringAttribute{
-										Required:    true,
-										Description: "A Cloud Pub/Sub topic name. For example, projects/myProject/topics/deviceEvents.",
-									},
-									"sub_folder_matches": schema.StringAttribute{
-										Optional:    true,
-										Computed:    true,
-										Description: "This field is used only for telemetry events; subfolders are not supported for state changes.",
-									},
-								},
-							},
+						"sub_folder_matches": schema.StringAttribute{
+							Computed:    true,
+							Description: "If the subfolder name matches this string exactly, this configuration will be used.",
 						},
-						"state_notification_config": schema.SingleNestedAttribute{
-							Required:    true,
-							Description: "The configuration for notification of new states received from the device.",
+					},
+				},
+			},
+			"state_notification_config": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The configuration for notification of new states received from the device.",
+				Attributes: map[string]schema.Attribute{
+					"pubsub_topic_name": schema.StringAttribute{
+						Computed:    true,
+						Description: "A Cloud Pub/Sub topic name. For example, projects/myProject/topics/deviceEvents.",
+					},
+				},
+			},
+			"http_config": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The configuration of the HTTP bridge for a device registry.",
+				Attributes: map[string]schema.Attribute{
+					"http_enabled_state": schema.StringAttribute{
+						Computed:    true,
+						Description: "If enabled, allows devices to use DeviceService via the HTTP protocol. Otherwise, any requests to DeviceService will fail for this registry.",
+					},
+				},
+			},
+			"mqtt_config": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The configuration of MQTT for a device registry.",
+				Attributes: map[string]schema.Attribute{
+					"mqtt_enabled_state": schema.StringAttribute{
+						Computed:    true,
+						Description: "If enabled, allows connections using the MQTT protocol. Otherwise, MQTT connections to this registry will fail.",
+					},
+				},
+			},
+			"log_level": schema.StringAttribute{
+				Computed:    true,
+				Description: "The default logging verbosity for activity from devices in this registry.",
+			},
+			"credentials": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "List of public key certificates to authenticate devices.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"public_key_certificate": schema.SingleNestedAttribute{
+							Computed:    true,
+							Description: "A public key certificate format and data.",
 							Attributes: map[string]schema.Attribute{
-								"pubsub_topic_name": schema.StringAttribute{
-									Description: "A Cloud Pub/Sub topic name. For example, projects/myProject/topics/deviceEvents.",
+								"format": schema.StringAttribute{
 									Computed:    true,
+									Description: "The certificate format.",
 								},
-							},
-						},
-						"http_config": schema.SingleNestedAttribute{
-							Required:    true,
-							Description: "The configuration of the HTTP bridge for a device registry.",
-							Attributes: map[string]schema.Attribute{
-								"http_enabled_state": schema.StringAttribute{
-									Description: "If enabled, allows devices to use DeviceService via the HTTP protocol. Otherwise, any requests to DeviceService will fail for this registry.",
+								"certificate": schema.StringAttribute{
 									Computed:    true,
+									Description: "The certificate data.",
 								},
-							},
-						},
-						"mqtt_config": schema.SingleNestedAttribute{
-							Required:    true,
-							Description: "The configuration of MQTT for a device registry.",
-							Attributes: map[string]schema.Attribute{
-								"mqtt_enabled_state": schema.StringAttribute{
-									Description: "If enabled, allows connections using the MQTT protocol. Otherwise, MQTT connections to this registry will fail.",
+								"x509_details": schema.SingleNestedAttribute{
 									Computed:    true,
-								},
-							},
-						},
-						"log_level": schema.StringAttribute{
-							Description: "The logging verbosity for device activity. Specifies which events should be written to logs.",
-							Computed:    true,
-						},
-						"credentials": schema.ListNestedAttribute{
-							Optional: true,
-							Computed: true,
-							NestedObject: schema.NestedAttributeObject{
-								Attributes: map[string]schema.Attribute{
-									"public_key_certificate": schema.SingleNestedAttribute{
-										Optional:    true,
-										Description: "A public key certificate format and data.",
-										Attributes: map[string]schema.Attribute{
-											"format": schema.StringAttribute{
-												Description: "The certificate format.",
-												Computed:    true,
-											},
-											"certificate": schema.StringAttribute{
-												Description: "The certificate data.",
-												Computed:    true,
-											},
-											"x509_details": schema.SingleNestedAttribute{
-												Optional:    true,
-												Description: "Details of an X.509 certificate.",
-												Attributes: map[string]schema.Attribute{
-													"issuer": schema.StringAttribute{
-														Description: "The entity that signed the certificate.",
-														Computed:    true,
-													},
-													"subject": schema.StringAttribute{
-														Description: "The entity the certificate and public key belong to.",
-														Computed:    true,
-													},
-													"start_time": schema.StringAttribute{
-														Description: "The time the certificate becomes valid.",
-														Computed:    true,
-													},
-													"expiry_time": schema.StringAttribute{
-														Description: "The time the certificate becomes invalid.",
-														Computed:    true,
-													},
-													"signature_algorithm": schema.StringAttribute{
-														Description: "The algorithm used to sign the certificate.",
-														Computed:    true,
-													},
-													"public_key_type": schema.StringAttribute{
-														Description: "The type of public key in the certificate.",
-														Computed:    true,
-													},
-												},
-											},
+									Description: "Details of the X.509 certificate. Parsed from the certificate when the API does not return them.",
+									Attributes: map[string]schema.Attribute{
+										"issuer": schema.StringAttribute{
+											Computed:    true,
+											Description: "The entity that signed the certificate.",
+										},
+										"subject": schema.StringAttribute{
+											Computed:    true,
+											Description: "The entity the certificate and public key belong to.",
+										},
+										"start_time": schema.StringAttribute{
+											Computed:    true,
+											Description: "The time the certificate becomes valid.",
+										},
+										"expiry_time": schema.StringAttribute{
+											Computed:    true,
+											Description: "The time the certificate becomes invalid.",
+										},
+										"signature_algorithm": schema.StringAttribute{
+											Computed:    true,
+											Description: "The algorithm used to sign the certificate.",
+										},
+										"public_key_type": schema.StringAttribute{
+											Computed:    true,
+											Description: "The type of public key in the certificate.",
 										},
 									},
 								},
@@ -181,74 +163,121 @@ func (d *deviceRegistriesDataSource) Schema(_ context.Context, _ datasource.Sche
 	}
 }
 
-func (d *deviceRegistriesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
-	var state deviceRegistriesDataSourceModel
+func (d *deviceRegistryDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state deviceRegistriesModel
+
+	// Only id is read from the configuration; the computed objects are null
+	// there and cannot be decoded into the model.
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("id"), &state.ID)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	name := state.ID.ValueString()
+	if !strings.HasPrefix(name, "projects/") {
+		name = fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), name)
+	}
 
-	tflog.Info(ctx, "requesting device registry listing from Clearblade IoT Core")
-	parent := fmt.Sprintf("projects/%s/locations/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"))
-	registries, err := d.client.Projects.Locations.Registries.List(parent).Do()
+	tflog.Info(ctx, "requesting device registry detail from Clearblade IoT Core")
+	registry, err := d.client.Projects.Locations.Registries.Get(name).Context(ctx).Do()
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Unable to read Clearblade IoT Core device registries. Make sure your credentials are correct and you have access "+
-				"to the project, or that you have the correct permissions.",
-			err.Error(),
+			"Unable to read Clearblade IoT Core device registry",
+			"Could not read ClearBlade IoT Core registry "+name+": "+err.Error(),
 		)
 		return
 	}
-	tflog.Info(ctx, "device registry")
-	tflog.Info(ctx, strconv.Itoa(len(registries.DeviceRegistries)))
 
 	// Map response body to model
-	for _, registry := range registries.DeviceRegistries {
-		registryState := deviceRegistriesModel{
-			ID:       types.StringValue(registry.Id),
-			LogLevel: types.StringValue(registry.LogLevel),
-			HttpConfig: HttpConfigModel{
-				HttpEnabledState: types.StringValue(registry.HttpConfig.HttpEnabledState),
-			},
-			MqttConfig: MqttConfigModel{
-				MqttEnabledState: types.StringValue(registry.MqttConfig.MqttEnabledState),
-			},
-			Name: types.StringValue(registry.Name),
-			StateNotificationConfig: StateNotificationConfigModel{
-				PubsubTopicName: types.StringValue(registry.StateNotificationConfig.PubsubTopicName),
-			},
-		}
+	state.Name = types.StringValue(registry.Name)
+	state.LogLevel = types.StringValue(registry.LogLevel)
 
-		for _, credential := range registry.Credentials {
-			registryState.Credentials = append(registryState.Credentials, CredentialsModel{
-				PublicKeyCertificate: PublicKeyCertificateModel{
-					Format:      types.StringValue(credential.PublicKeyCertificate.Format),
-					Certificate: types.StringValue(credential.PublicKeyCertificate.Certificate),
-					X509Details: X509CertificateDetailsModel{
-						Issuer:             types.StringValue(credential.PublicKeyCertificate.X509Details.Issuer),
-						Subject:            types.StringValue(credential.PublicKeyCertificate.X509Details.Subject),
-						StartTime:          types.StringValue(credential.PublicKeyCertificate.X509Details.StartTime),
-						ExpiryTime:         types.StringValue(credential.PublicKeyCertificate.X509Details.ExpiryTime),
-						SignatureAlgorithm: types.StringValue(credential.PublicKeyCertificate.X509Details.SignatureAlgorithm),
-						PublicKeyType:      types.StringValue(credential.PublicKeyCertificate.X509Details.PublicKeyType),
-					},
-				},
-			})
-		}
-
-		for _, eventNotificationConfig := range registry.EventNotificationConfigs {
-			registryState.EventNotificationConfigs = append(registryState.EventNotificationConfigs, EventNotificationConfigsModel{
-				PubsubTopicName:  types.StringValue(eventNotificationConfig.PubsubTopicName),
-				SubfolderMatches: types.StringValue(eventNotificationConfig.SubfolderMatches),
-			})
-		}
+	state.StateNotificationConfig = StateNotificationConfigModel{}
+	if registry.StateNotificationConfig != nil {
+		state.StateNotificationConfig.PubsubTopicName = types.StringValue(registry.StateNotificationConfig.PubsubTopicName)
+	}
+	state.HttpConfig = HttpConfigModel{}
+	if registry.HttpConfig != nil {
+		state.HttpConfig.HttpEnabledState = types.StringValue(registry.HttpConfig.HttpEnabledState)
+	}
+	state.MqttConfig = MqttConfigModel{}
+	if registry.MqttConfig != nil {
+		state.MqttConfig.MqttEnabledState = types.StringValue(registry.MqttConfig.MqttEnabledState)
+	}
 
-		state.DeviceRegistries = append(state.DeviceRegistries, registryState)
+	state.EventNotificationConfigs = []EventNotificationConfigsModel{}
+	for _, eventNotificationConfig := range registry.EventNotificationConfigs {
+		state.EventNotificationConfigs = append(state.EventNotificationConfigs, EventNotificationConfigsModel{
+			PubsubTopicName:  types.StringValue(eventNotificationConfig.PubsubTopicName),
+			SubfolderMatches: types.StringValue(eventNotificationConfig.SubfolderMatches),
+		})
 	}
 
+	state.Credentials = flattenRegistryCredentials(ctx, registry.Credentials)
+
 	// Set state
 	diags := resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+}
+
+// flattenRegistryCredentials maps registry credentials to their model. When
+// the API does not return X.509 details they are parsed from the certificate.
+func flattenRegistryCredentials(ctx context.Context, registryCredentials []*iot.RegistryCredential) []CredentialsModel {
+	credentials := []CredentialsModel{}
+	for _, credential := range registryCredentials {
+		if credential.PublicKeyCertificate == nil {
+			continue
+		}
+
+		details := credential.PublicKeyCertificate.X509Details
+		if details == nil || (details.Issuer == "" && details.Subject == "" && details.ExpiryTime == "") {
+			parsed, err := parseX509Details(credential.PublicKeyCertificate.Certificate)
+			if err != nil {
+				tflog.Debug(ctx, "could not parse registry certificate: "+err.Error())
+				parsed = &iot.X509CertificateDetails{}
+			}
+			details = parsed
+		}
+
+		credentials = append(credentials, CredentialsModel{
+			PublicKeyCertificate: PublicKeyCertificateModel{
+				Format:      types.StringValue(credential.PublicKeyCertificate.Format),
+				Certificate: types.StringValue(credential.PublicKeyCertificate.Certificate),
+				X509Details: X509CertificateDetailsModel{
+					Issuer:             types.StringValue(details.Issuer),
+					Subject:            types.StringValue(details.Subject),
+					StartTime:          types.StringValue(details.StartTime),
+					ExpiryTime:         types.StringValue(details.ExpiryTime),
+					SignatureAlgorithm: types.StringValue(details.SignatureAlgorithm),
+					PublicKeyType:      types.StringValue(details.PublicKeyType),
+				},
+			},
+		})
+	}
+	return credentials
+}
+
+// parseX509Details extracts the X.509 details of a PEM encoded certificate.
+func parseX509Details(certificate string) (*iot.X509CertificateDetails, error) {
+	block, _ := pem.Decode([]byte(certificate))
+	if block == nil {
+		return nil, errors.New("certificate is not PEM encoded")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
 
+	return &iot.X509CertificateDetails{
+		Issuer:             cert.Issuer.String(),
+		Subject:            cert.Subject.String(),
+		StartTime:          cert.NotBefore.UTC().Format(time.RFC3339),
+		ExpiryTime:         cert.NotAfter.UTC().Format(time.RFC3339),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		PublicKeyType:      cert.PublicKeyAlgorithm.String(),
+	}, nil
 }
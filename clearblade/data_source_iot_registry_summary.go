@@ -0,0 +1,244 @@
+package clearblade
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &registrySummaryDataSource{}
+	_ datasource.DataSourceWithConfigure = &registrySummaryDataSource{}
+)
+
+const (
+	defaultStaleAfterHours    = 24
+	defaultExpiringWithinDays = 30
+
+	// registrySummaryFieldMask limits the listing to the fields summarized.
+	registrySummaryFieldMask = "blocked,credentials,gateway_config,last_error_status,last_event_time,last_heartbeat_time"
+)
+
+// registrySummaryDataSourceModel maps the data source schema data.
+type registrySummaryDataSourceModel struct {
+	Registry                 types.String `tfsdk:"registry"`
+	PageSize                 types.Int64  `tfsdk:"page_size"`
+	StaleAfterHours          types.Int64  `tfsdk:"stale_after_hours"`
+	ExpiringWithinDays       types.Int64  `tfsdk:"expiring_within_days"`
+	DeviceCount              types.Int64  `tfsdk:"device_count"`
+	GatewayCount             types.Int64  `tfsdk:"gateway_count"`
+	NonGatewayCount          types.Int64  `tfsdk:"non_gateway_count"`
+	BlockedCount             types.Int64  `tfsdk:"blocked_count"`
+	ErrorCount               types.Int64  `tfsdk:"error_count"`
+	StaleCount               types.Int64  `tfsdk:"stale_count"`
+	ExpiringCredentialsCount types.Int64  `tfsdk:"expiring_credentials_count"`
+	ExpiredCredentialsCount  types.Int64  `tfsdk:"expired_credentials_count"`
+}
+
+func NewRegistrySummaryDataSource() datasource.DataSource {
+	return &registrySummaryDataSource{}
+}
+
+type registrySummaryDataSource struct {
+	client *iot.Service
+}
+
+func (d *registrySummaryDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_registry_summary"
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *registrySummaryDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	d.client = req.ProviderData.(*providerData).client
+}
+
+func (d *registrySummaryDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Aggregated device statistics for a device registry, computed from every page of its devices.",
+		Attributes: map[string]schema.Attribute{
+			"registry": schema.StringAttribute{
+				Description: "The name of the device registry to summarize.",
+				Required:    true,
+			},
+			"page_size": schema.Int64Attribute{
+				Description: "The maximum number of devices to request per page. If unset, the server default is used.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"stale_after_hours": schema.Int64Attribute{
+				Description: fmt.Sprintf("A device counts as stale when neither a heartbeat nor a telemetry event was received for this many hours. Defaults to %d.", defaultStaleAfterHours),
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"expiring_within_days": schema.Int64Attribute{
+				Description: fmt.Sprintf("A credential counts as expiring when it expires within this many days. Defaults to %d.", defaultExpiringWithinDays),
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"device_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of devices in the registry.",
+			},
+			"gateway_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of devices configured as gateways.",
+			},
+			"non_gateway_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of devices not configured as gateways.",
+			},
+			"blocked_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of blocked devices.",
+			},
+			"error_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of devices with a last_error_status.",
+			},
+			"stale_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of devices not seen for stale_after_hours, including devices that were never seen.",
+			},
+			"expiring_credentials_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of device credentials that have not expired yet but expire within expiring_within_days.",
+			},
+			"expired_credentials_count": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The number of device credentials that have already expired.",
+			},
+		},
+	}
+}
+
+func (d *registrySummaryDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var state registrySummaryDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	staleAfter := time.Duration(defaultStaleAfterHours) * time.Hour
+	if !state.StaleAfterHours.IsNull() {
+		staleAfter = time.Duration(state.StaleAfterHours.ValueInt64()) * time.Hour
+	}
+	expiringWithin := time.Duration(defaultExpiringWithinDays) * 24 * time.Hour
+	if !state.ExpiringWithinDays.IsNull() {
+		expiringWithin = time.Duration(state.ExpiringWithinDays.ValueInt64()) * 24 * time.Hour
+	}
+
+	tflog.Info(ctx, "requesting device listing from Clearblade IoT Core")
+	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString())
+	devices, err := listDevices(ctx, d.client, parent, deviceListOptions{
+		PageSize:  state.PageSize.ValueInt64(),
+		FieldMask: registrySummaryFieldMask,
+	})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to read Clearblade IoT Core devices",
+			"Could not list the devices of registry "+state.Registry.ValueString()+": "+err.Error(),
+		)
+		return
+	}
+
+	summary := summarizeDevices(devices, time.Now(), staleAfter, expiringWithin)
+	state.DeviceCount = types.Int64Value(summary.devices)
+	state.GatewayCount = types.Int64Value(summary.gateways)
+	state.NonGatewayCount = types.Int64Value(summary.devices - summary.gateways)
+	state.BlockedCount = types.Int64Value(summary.blocked)
+	state.ErrorCount = types.Int64Value(summary.errors)
+	state.StaleCount = types.Int64Value(summary.stale)
+	state.ExpiringCredentialsCount = types.Int64Value(summary.expiringCredentials)
+	state.ExpiredCredentialsCount = types.Int64Value(summary.expiredCredentials)
+
+	tflog.Debug(ctx, "registry summary computed", map[string]any{"device count": summary.devices})
+
+	// Set state
+	diags := resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// deviceSummary holds the totals computed by summarizeDevices.
+type deviceSummary struct {
+	devices             int64
+	gateways            int64
+	blocked             int64
+	errors              int64
+	stale               int64
+	expiringCredentials int64
+	expiredCredentials  int64
+}
+
+// summarizeDevices computes the registry totals as of now.
+func summarizeDevices(devices []*iot.Device, now time.Time, staleAfter, expiringWithin time.Duration) deviceSummary {
+	var summary deviceSummary
+	for _, device := range devices {
+		summary.devices++
+		if device.GatewayConfig != nil && device.GatewayConfig.GatewayType == "GATEWAY" {
+			summary.gateways++
+		}
+		if device.Blocked {
+			summary.blocked++
+		}
+		if device.LastErrorStatus != nil && (device.LastErrorStatus.Code != 0 || device.LastErrorStatus.Message != "") {
+			summary.errors++
+		}
+
+		lastSeen := latestTime(device.LastHeartbeatTime, device.LastEventTime)
+		if lastSeen.IsZero() || lastSeen.Before(now.Add(-staleAfter)) {
+			summary.stale++
+		}
+
+		for _, credential := range device.Credentials {
+			// Credentials without an expiration report the Unix epoch.
+			expiry, err := time.Parse(time.RFC3339Nano, credential.ExpirationTime)
+			if err != nil || expiry.Unix() == 0 {
+				continue
+			}
+			switch {
+			case !expiry.After(now):
+				summary.expiredCredentials++
+			case expiry.Before(now.Add(expiringWithin)):
+				summary.expiringCredentials++
+			}
+		}
+	}
+	return summary
+}
+
+// latestTime returns the latest of the RFC 3339 timestamps, ignoring empty or
+// invalid ones. It returns the zero time when none is valid.
+func latestTime(timestamps ...string) time.Time {
+	var latest time.Time
+	for _, timestamp := range timestamps {
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
@@ -0,0 +1,111 @@
+package clearblade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clearblade/go-iot"
+)
+
+func TestSummarizeDevices(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) string {
+		return now.Add(-d).Format(time.RFC3339)
+	}
+	in := func(d time.Duration) string {
+		return now.Add(d).Format(time.RFC3339)
+	}
+	credential := func(expiration string) *iot.DeviceCredential {
+		return &iot.DeviceCredential{ExpirationTime: expiration, PublicKey: &iot.PublicKeyCredential{Format: "ES256_PEM", Key: "key"}}
+	}
+	// seen is a device that sent a heartbeat recently, so that only the
+	// field a case sets decides its totals.
+	seen := func(device iot.Device) *iot.Device {
+		if device.LastHeartbeatTime == "" && device.LastEventTime == "" {
+			device.LastHeartbeatTime = ago(time.Minute)
+		}
+		return &device
+	}
+	const staleAfter, expiringWithin = 24 * time.Hour, 30 * 24 * time.Hour
+
+	for _, tc := range []struct {
+		name    string
+		devices []*iot.Device
+		want    deviceSummary
+	}{
+		{
+			name: "none",
+			want: deviceSummary{},
+		},
+		{
+			name: "gateways",
+			devices: []*iot.Device{
+				seen(iot.Device{GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY"}}),
+				seen(iot.Device{GatewayConfig: &iot.GatewayConfig{GatewayType: "NON_GATEWAY"}}),
+				seen(iot.Device{}),
+			},
+			want: deviceSummary{devices: 3, gateways: 1},
+		},
+		{
+			name: "blocked",
+			devices: []*iot.Device{
+				seen(iot.Device{Blocked: true}),
+				seen(iot.Device{}),
+			},
+			want: deviceSummary{devices: 2, blocked: 1},
+		},
+		{
+			name: "error status",
+			devices: []*iot.Device{
+				seen(iot.Device{LastErrorStatus: &iot.Status{Code: 9, Message: "mqtt: not authorized"}}),
+				seen(iot.Device{LastErrorStatus: &iot.Status{Message: "disconnected"}}),
+				// An empty status is reported for devices without errors.
+				seen(iot.Device{LastErrorStatus: &iot.Status{}}),
+			},
+			want: deviceSummary{devices: 3, errors: 2},
+		},
+		{
+			name: "stale",
+			devices: []*iot.Device{
+				{},
+				{LastHeartbeatTime: "not a time"},
+				{LastHeartbeatTime: ago(25 * time.Hour)},
+				// The latest of the heartbeat and event decides.
+				{LastHeartbeatTime: ago(48 * time.Hour), LastEventTime: ago(time.Hour)},
+				{LastEventTime: ago(23 * time.Hour)},
+			},
+			want: deviceSummary{devices: 5, stale: 3},
+		},
+		{
+			name: "credentials without expiry",
+			devices: []*iot.Device{
+				seen(iot.Device{Credentials: []*iot.DeviceCredential{
+					credential("1970-01-01T00:00:00Z"),
+					credential(""),
+				}}),
+			},
+			want: deviceSummary{devices: 1},
+		},
+		{
+			name: "expired and expiring credentials",
+			devices: []*iot.Device{
+				seen(iot.Device{Credentials: []*iot.DeviceCredential{
+					credential(ago(time.Hour)),
+					credential(now.Format(time.RFC3339)),
+					credential(in(29 * 24 * time.Hour)),
+					credential(in(31 * 24 * time.Hour)),
+				}}),
+				seen(iot.Device{Credentials: []*iot.DeviceCredential{
+					credential(in(time.Hour)),
+				}}),
+			},
+			want: deviceSummary{devices: 2, expiredCredentials: 2, expiringCredentials: 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := summarizeDevices(tc.devices, now, staleAfter, expiringWithin); got != tc.want {
+				t.Errorf("summarizeDevices = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
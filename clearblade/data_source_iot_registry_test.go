@@ -0,0 +1,134 @@
+package clearblade
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+// testCertificate returns a self-signed PEM certificate of commonName, valid
+// from 2024-01-01 for a year.
+func testCertificate(t *testing.T, commonName string) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestAccRegistryDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{
+		Id:       "test-registry",
+		LogLevel: "INFO",
+		EventNotificationConfigs: []*iot.EventNotificationConfig{
+			{PubsubTopicName: "projects/test-project/topics/events", SubfolderMatches: "telemetry"},
+		},
+		MqttConfig: &iot.MqttConfig{MqttEnabledState: "MQTT_ENABLED"},
+		HttpConfig: &iot.HttpConfig{HttpEnabledState: "HTTP_DISABLED"},
+		Credentials: []*iot.RegistryCredential{
+			// The API did not return the details of this certificate.
+			{PublicKeyCertificate: &iot.PublicKeyCertificate{Format: "X509_CERTIFICATE_PEM", Certificate: testCertificate(t, "test-ca")}},
+			// It did for this one.
+			{PublicKeyCertificate: &iot.PublicKeyCertificate{
+				Format:      "X509_CERTIFICATE_PEM",
+				Certificate: testCertificate(t, "other-ca"),
+				X509Details: &iot.X509CertificateDetails{Issuer: "CN=api-issuer", Subject: "CN=api-subject", ExpiryTime: "2030-01-01T00:00:00Z"},
+			}},
+		},
+	})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_iot_registry" "by_id" {
+  id = "test-registry"
+}
+
+data "clearblade_iot_registry" "by_path" {
+  id = "` + testAccLocation + `/registries/test-registry"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "name", testAccLocation+"/registries/test-registry"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "log_level", "INFO"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "event_notification_configs.0.sub_folder_matches", "telemetry"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "mqtt_config.mqtt_enabled_state", "MQTT_ENABLED"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "http_config.http_enabled_state", "HTTP_DISABLED"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.#", "2"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.subject", "CN=test-ca"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.issuer", "CN=test-ca"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.start_time", "2024-01-01T00:00:00Z"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.expiry_time", "2025-01-01T00:00:00Z"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.signature_algorithm", "ECDSA-SHA256"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.public_key_type", "ECDSA"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.1.public_key_certificate.x509_details.subject", "CN=api-subject"),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.by_id", "credentials.1.public_key_certificate.x509_details.expiry_time", "2030-01-01T00:00:00Z"),
+					resource.TestCheckResourceAttrPair("data.clearblade_iot_registry.by_path", "name", "data.clearblade_iot_registry.by_id", "name"),
+					resource.TestCheckResourceAttrPair("data.clearblade_iot_registry.by_path", "credentials.0.public_key_certificate.x509_details.subject", "data.clearblade_iot_registry.by_id", "credentials.0.public_key_certificate.x509_details.subject"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) + `
+data "clearblade_iot_registry" "missing" {
+  id = "missing-registry"
+}
+`,
+				ExpectError: regexp.MustCompile(`Could not read ClearBlade IoT Core registry`),
+			},
+		},
+	})
+}
+
+func TestParseX509Details(t *testing.T) {
+	details, err := parseX509Details(testCertificate(t, "test-ca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := iot.X509CertificateDetails{
+		Issuer:             "CN=test-ca",
+		Subject:            "CN=test-ca",
+		StartTime:          "2024-01-01T00:00:00Z",
+		ExpiryTime:         "2025-01-01T00:00:00Z",
+		SignatureAlgorithm: "ECDSA-SHA256",
+		PublicKeyType:      "ECDSA",
+	}
+	if !reflect.DeepEqual(*details, want) {
+		t.Errorf("parseX509Details = %+v, want %+v", *details, want)
+	}
+
+	for _, certificate := range []string{
+		"not a certificate",
+		testAccDeviceKey,
+		"-----BEGIN CERTIFICATE-----\nbm90IERFUg==\n-----END CERTIFICATE-----\n",
+	} {
+		if _, err := parseX509Details(certificate); err == nil {
+			t.Errorf("parseX509Details(%q) succeeded, want an error", certificate)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package clearblade
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// checkDeletionProtection returns an error when deletion protection prevents
+// destroying the resource of kind, such as "Registry", named name. protection is the
+// deletion_protection attribute of its state, which falls back to the provider
+// default when null. The state holds the applied configuration, so lifting
+// the protection takes an apply before the destroy.
+func checkDeletionProtection(protection types.Bool, providerDefault bool, kind, name string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	switch {
+	case !protection.IsNull() && !protection.IsUnknown():
+		if !protection.ValueBool() {
+			return diags
+		}
+		diags.AddError(
+			"Cannot Destroy Protected ClearBlade IoT Core "+kind,
+			fmt.Sprintf("The %s %q has deletion_protection set to true. "+
+				"To destroy it, set deletion_protection to false, apply the configuration, and then destroy it.", strings.ToLower(kind), name),
+		)
+	case providerDefault:
+		diags.AddError(
+			"Cannot Destroy Protected ClearBlade IoT Core "+kind,
+			fmt.Sprintf("The %s %q is protected by the deletion_protection setting of the provider. "+
+				"To destroy it, set its deletion_protection attribute to false, apply the configuration, and then destroy it.", strings.ToLower(kind), name),
+		)
+	}
+	return diags
+}
@@ -0,0 +1,112 @@
+package clearblade
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// deviceListFieldMask requests every device field when listing devices for
+// the cache, since the listing replaces individual Devices.Get calls.
+const deviceListFieldMask = "blocked,config,credentials,gateway_config,last_config_ack_time,last_config_send_time," +
+	"last_error_status,last_error_time,last_event_time,last_heartbeat_time,last_state_time,log_level,metadata,name,state"
+
+// deviceListCache serves device reads from a single paginated listing per
+// registry. Concurrent reads of a registry that has not been listed yet wait
+// for one shared listing instead of each listing the registry.
+type deviceListCache struct {
+	client *iot.Service
+
+	mu         sync.Mutex
+	registries map[string]*deviceListEntry
+}
+
+// deviceListEntry is the listing of one registry. done is closed once devices
+// and err are set. canceled records that the listing failed because the
+// context of the read that started it ended.
+type deviceListEntry struct {
+	done     chan struct{}
+	devices  map[string]*iot.Device
+	err      error
+	canceled bool
+}
+
+func newDeviceListCache(client *iot.Service) *deviceListCache {
+	return &deviceListCache{
+		client:     client,
+		registries: map[string]*deviceListEntry{},
+	}
+}
+
+// get returns the device with the user-defined or numeric id from the listing
+// of the registry parent, listing the registry on first use. It reports false
+// when the device is not part of the listing.
+func (c *deviceListCache) get(ctx context.Context, parent, id string) (*iot.Device, bool, error) {
+	for {
+		c.mu.Lock()
+		entry, ok := c.registries[parent]
+		if !ok {
+			entry = &deviceListEntry{done: make(chan struct{})}
+			c.registries[parent] = entry
+		}
+		c.mu.Unlock()
+
+		if !ok {
+			c.load(ctx, parent, entry)
+		}
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		// A listing canceled with the context of another read is started
+		// again by a read that is still waiting for it.
+		if entry.canceled && ctx.Err() == nil {
+			continue
+		}
+		if entry.err != nil {
+			return nil, false, entry.err
+		}
+
+		device, found := entry.devices[id]
+		return device, found, nil
+	}
+}
+
+// load lists the registry parent into entry. A failed listing is dropped from
+// the cache so that the next read tries again.
+func (c *deviceListCache) load(ctx context.Context, parent string, entry *deviceListEntry) {
+	defer close(entry.done)
+
+	tflog.Info(ctx, "listing devices to batch device reads", map[string]any{"registry": parent})
+	devices, err := listDevices(ctx, c.client, parent, deviceListOptions{FieldMask: deviceListFieldMask})
+	if err != nil {
+		entry.err = err
+		entry.canceled = ctx.Err() != nil
+		c.mu.Lock()
+		if c.registries[parent] == entry {
+			delete(c.registries, parent)
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	entry.devices = make(map[string]*iot.Device, 2*len(devices))
+	for _, device := range devices {
+		entry.devices[device.Id] = device
+		entry.devices[strconv.FormatUint(device.NumId, 10)] = device
+	}
+	tflog.Debug(ctx, "cached device listing", map[string]any{"registry": parent, "device count": len(devices)})
+}
+
+// invalidate drops the listing of the registry parent, for example after one
+// of its devices changed.
+func (c *deviceListCache) invalidate(parent string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.registries, parent)
+}
@@ -0,0 +1,134 @@
+package clearblade
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+
+	"github.com/clearblade/go-iot"
+)
+
+// newTestDeviceListCache returns a cache of a fake with a registry of two
+// devices, and the name of the registry.
+func newTestDeviceListCache(t *testing.T) (*clearbladetest.Server, *deviceListCache, string) {
+	t.Helper()
+
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	parent := testAccLocation + "/registries/test-registry"
+	for _, id := range []string{"device-a", "device-b"} {
+		if err := server.PutDevice(parent, &iot.Device{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, err := server.NewService(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primeRegistryCredentials(client, testAccRegion, "test-registry"); err != nil {
+		t.Fatal(err)
+	}
+	return server, newDeviceListCache(client), parent
+}
+
+func TestDeviceListCacheSharesListing(t *testing.T) {
+	server, cache, parent := newTestDeviceListCache(t)
+	// Keep the listing in flight while every read starts.
+	server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.list", Count: 1, Fault: clearbladetest.Slow, Delay: 100 * time.Millisecond})
+
+	const reads = 16
+	var wg sync.WaitGroup
+	errs := make([]error, reads)
+	for i := 0; i < reads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := "device-a"
+			if i%2 == 1 {
+				id = "device-b"
+			}
+			device, found, err := cache.get(context.Background(), parent, id)
+			switch {
+			case err != nil:
+				errs[i] = err
+			case !found || device.Id != id:
+				t.Errorf("read %d: found %t, want device %s", i, found, id)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("read %d: %s", i, err)
+		}
+	}
+	if got := server.RequestCount("registries.devices.list"); got != 1 {
+		t.Errorf("%d reads listed the registry %d times, want once", reads, got)
+	}
+
+	if _, found, err := cache.get(context.Background(), parent, "device-c"); err != nil || found {
+		t.Errorf("reading a device that does not exist: found %t, error %v", found, err)
+	}
+	if got := server.RequestCount("registries.devices.list"); got != 1 {
+		t.Errorf("a cached registry was listed again")
+	}
+}
+
+func TestDeviceListCacheRetriesFailedListing(t *testing.T) {
+	server, cache, parent := newTestDeviceListCache(t)
+	server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.list", Count: 1, Fault: clearbladetest.Unavailable})
+
+	if _, _, err := cache.get(context.Background(), parent, "device-a"); err == nil {
+		t.Fatal("the first read succeeded, want the listing error")
+	}
+	_, found, err := cache.get(context.Background(), parent, "device-a")
+	if err != nil || !found {
+		t.Fatalf("the second read found %t, error %v, want the device", found, err)
+	}
+	if got := server.RequestCount("registries.devices.list"); got != 2 {
+		t.Errorf("listed the registry %d times, want twice", got)
+	}
+}
+
+func TestDeviceListCacheCanceledListing(t *testing.T) {
+	server, cache, parent := newTestDeviceListCache(t)
+	server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.list", Count: 1, Fault: clearbladetest.Slow, Delay: 200 * time.Millisecond})
+
+	// The first read starts the listing and is canceled while a second read
+	// waits for it.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := cache.get(ctx, parent, "device-a")
+		first <- err
+	}()
+	for server.RequestCount("registries.devices.list") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, found, err := cache.get(context.Background(), parent, "device-b")
+		if err == nil && !found {
+			t.Error("the second read did not find device-b")
+		}
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-first; err == nil {
+		t.Error("the canceled read succeeded")
+	}
+	if err := <-second; err != nil {
+		t.Errorf("the second read failed with the canceled listing: %s", err)
+	}
+	if got := server.RequestCount("registries.devices.list"); got != 2 {
+		t.Errorf("listed the registry %d times, want twice", got)
+	}
+}
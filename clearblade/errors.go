@@ -0,0 +1,15 @@
+package clearblade
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// isNotFoundError reports whether err is the error returned for a registry or
+// device that does not exist.
+func isNotFoundError(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
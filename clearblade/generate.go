@@ -0,0 +1,397 @@
+package clearblade
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"terraform-provider-clearblade/clearblade/resourcename"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// generateDeviceFieldMask lists the device fields the generated configuration
+// sets.
+const generateDeviceFieldMask = "blocked,credentials,log_level,metadata,gateway_config"
+
+// generateFiles are the files written by Generate.
+var generateFiles = []string{"registries.tf", "devices.tf", "imports.tf"}
+
+// generateOptions scope the configuration written by Generate.
+type generateOptions struct {
+	// Registries matches the IDs of the registries to generate, or every
+	// registry if nil.
+	Registries *regexp.Regexp
+	// BulkDevices generates a clearblade_iot_devices resource per registry
+	// instead of a clearblade_iot_device per device.
+	BulkDevices bool
+	Concurrency int
+}
+
+// Generate runs the generate command with args, the command line arguments
+// after "generate". It lists the registries and devices of a project and
+// writes their clearblade_iot_registry and clearblade_iot_device resources,
+// and the import blocks that bring them under management, to a directory.
+func Generate(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: terraform-provider-clearblade generate [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Writes the configuration and import blocks of the existing registries and devices of a project.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	credentialsFile := flags.String("credentials-file", os.Getenv("CLEARBLADE_CONFIGURATION"), "service account credentials file; defaults to CLEARBLADE_CONFIGURATION")
+	project := flags.String("project", os.Getenv("CLEARBLADE_PROJECT"), "project ID; defaults to CLEARBLADE_PROJECT or the project of the credentials")
+	region := flags.String("region", os.Getenv("CLEARBLADE_REGION"), "region of the registries; defaults to CLEARBLADE_REGION")
+	registries := flags.String("registry", "", "regular expression the IDs of the registries to generate must match")
+	out := flags.String("out", ".", "directory to write registries.tf, devices.tf and imports.tf to")
+	force := flags.Bool("force", false, "overwrite existing files")
+	var opts generateOptions
+	flags.BoolVar(&opts.BulkDevices, "bulk-devices", false, "generate a clearblade_iot_devices resource per registry instead of a clearblade_iot_device per device")
+	flags.IntVar(&opts.Concurrency, "concurrency", defaultConcurrency, "number of registries whose devices are listed in parallel")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+	if *credentialsFile == "" {
+		return errors.New("missing credentials: set -credentials-file or CLEARBLADE_CONFIGURATION")
+	}
+	if *region == "" {
+		return errors.New("missing region: set -region or CLEARBLADE_REGION")
+	}
+	if *registries != "" {
+		re, err := regexp.Compile(*registries)
+		if err != nil {
+			return fmt.Errorf("invalid -registry: %w", err)
+		}
+		opts.Registries = re
+	}
+	if *project == "" {
+		credentials, err := os.ReadFile(*credentialsFile)
+		if err != nil {
+			return err
+		}
+		var serviceAccount iot.ServiceAccountCredentials
+		if err := json.Unmarshal(credentials, &serviceAccount); err != nil {
+			return fmt.Errorf("reading the project of %s: %w", *credentialsFile, err)
+		}
+		*project = serviceAccount.Project
+	}
+	if *project == "" {
+		return errors.New("missing project: set -project or CLEARBLADE_PROJECT")
+	}
+	if !*force {
+		for _, name := range generateFiles {
+			if _, err := os.Stat(filepath.Join(*out, name)); err == nil {
+				return fmt.Errorf("%s already exists; use -force to overwrite it", filepath.Join(*out, name))
+			}
+		}
+	}
+
+	// The client and the resources read the project, region and credentials
+	// from the environment, as when the provider is configured.
+	os.Setenv("CLEARBLADE_CONFIGURATION", *credentialsFile)
+	os.Setenv("CLEARBLADE_PROJECT", *project)
+	os.Setenv("CLEARBLADE_REGION", *region)
+	client, err := iot.NewService(ctx, iot.WithFileCredentials())
+	if err != nil {
+		return fmt.Errorf("creating the ClearBlade IoT Core client: %w", err)
+	}
+
+	files, summary, err := generateConfiguration(ctx, client, *project, *region, opts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	for _, name := range generateFiles {
+		if err := os.WriteFile(filepath.Join(*out, name), files[name], 0o644); err != nil {
+			return err
+		}
+	}
+
+	for _, warning := range summary.Warnings {
+		fmt.Fprintln(stderr, "Warning:", warning)
+	}
+	fmt.Fprintf(stdout, "Generated %d registries and %d devices in %s\n", summary.Registries, summary.Devices, *out)
+	return nil
+}
+
+// generateSummary describes the configuration written by Generate.
+type generateSummary struct {
+	Registries int
+	Devices    int
+	Warnings   []string
+}
+
+// generateConfiguration lists the registries of the project and region that
+// opts selects and their devices, and returns the contents of each of
+// generateFiles.
+func generateConfiguration(ctx context.Context, client *iot.Service, project, region string, opts generateOptions) (map[string][]byte, generateSummary, error) {
+	var summary generateSummary
+
+	location := fmt.Sprintf("projects/%s/locations/%s", project, region)
+	all, err := listRegistries(ctx, client, location, 0, 0)
+	if err != nil {
+		return nil, summary, fmt.Errorf("listing registries: %w", err)
+	}
+	registries := []*iot.DeviceRegistry{}
+	for _, registry := range all {
+		if opts.Registries == nil || opts.Registries.MatchString(registry.Id) {
+			registries = append(registries, registry)
+		}
+	}
+	sort.Slice(registries, func(i, j int) bool { return registries[i].Id < registries[j].Id })
+
+	registryIDs := make([]string, len(registries))
+	for i, registry := range registries {
+		registryIDs[i] = registry.Id
+	}
+	if err := primeRegistryCredentials(client, region, registryIDs...); err != nil {
+		return nil, summary, fmt.Errorf("fetching registry credentials: %w", err)
+	}
+	devices := make([][]*iot.Device, len(registries))
+	errs := runBounded(ctx, len(registries), opts.Concurrency, func(ctx context.Context, i int) (err error) {
+		devices[i], err = listDevices(ctx, client, location+"/registries/"+registries[i].Id, deviceListOptions{FieldMask: generateDeviceFieldMask})
+		return err
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, summary, fmt.Errorf("listing the devices of registry %s: %w", registries[i].Id, err)
+		}
+	}
+
+	names := resourcename.Names{}
+	registriesFile := &hclFile{File: hclwrite.NewEmptyFile()}
+	devicesFile := &hclFile{File: hclwrite.NewEmptyFile()}
+	importsFile := &hclFile{File: hclwrite.NewEmptyFile()}
+	for i, registry := range registries {
+		sort.Slice(devices[i], func(a, b int) bool { return devices[i][a].Id < devices[i][b].Id })
+
+		registryName := names.Name("clearblade_iot_registry", registry.Id)
+		generateRegistry(registriesFile.appendBlock("resource", "clearblade_iot_registry", registryName), registry)
+		importsFile.appendImport("clearblade_iot_registry", registryName, registry.Id)
+		summary.Registries++
+		summary.Devices += len(devices[i])
+
+		if opts.BulkDevices {
+			if len(devices[i]) == 0 {
+				continue
+			}
+			name := names.Name("clearblade_iot_devices", registry.Id)
+			body := devicesFile.appendBlock("resource", "clearblade_iot_devices", name)
+			body.SetAttributeTraversal("registry", resourceIDTraversal("clearblade_iot_registry", registryName))
+			summary.Warnings = append(summary.Warnings, generateBulkDevices(body, registry.Id, devices[i])...)
+			importsFile.appendImport("clearblade_iot_devices", name, registry.Id)
+			continue
+		}
+		for _, device := range devices[i] {
+			name := names.Name("clearblade_iot_device", registry.Id+"_"+device.Id)
+			body := devicesFile.appendBlock("resource", "clearblade_iot_device", name)
+			body.SetAttributeValue("id", cty.StringVal(device.Id))
+			body.SetAttributeTraversal("registry", resourceIDTraversal("clearblade_iot_registry", registryName))
+			generateDevice(body, device)
+			importsFile.appendImport("clearblade_iot_device", name, registry.Id+"/"+device.Id)
+		}
+	}
+
+	files := map[string][]byte{
+		"registries.tf": registriesFile.Bytes(),
+		"devices.tf":    devicesFile.Bytes(),
+		"imports.tf":    importsFile.Bytes(),
+	}
+	for name, content := range files {
+		files[name] = hclwrite.Format(content)
+	}
+	return files, summary, nil
+}
+
+// hclFile is a configuration file whose top-level blocks are separated by
+// empty lines.
+type hclFile struct {
+	*hclwrite.File
+	blocks int
+}
+
+// appendBlock appends a block of type typ with labels and returns its body.
+func (f *hclFile) appendBlock(typ string, labels ...string) *hclwrite.Body {
+	if f.blocks > 0 {
+		f.Body().AppendNewline()
+	}
+	f.blocks++
+	return f.Body().AppendNewBlock(typ, labels).Body()
+}
+
+// appendImport appends an import block of the resource typ.name with the
+// import ID id.
+func (f *hclFile) appendImport(typ, name, id string) {
+	block := f.appendBlock("import")
+	block.SetAttributeTraversal("to", hcl.Traversal{hcl.TraverseRoot{Name: typ}, hcl.TraverseAttr{Name: name}})
+	block.SetAttributeValue("id", cty.StringVal(id))
+}
+
+func resourceIDTraversal(typ, name string) hcl.Traversal {
+	return hcl.Traversal{hcl.TraverseRoot{Name: typ}, hcl.TraverseAttr{Name: name}, hcl.TraverseAttr{Name: "id"}}
+}
+
+// generateRegistry sets the attributes of the registry resource of registry.
+func generateRegistry(body *hclwrite.Body, registry *iot.DeviceRegistry) {
+	body.SetAttributeValue("id", cty.StringVal(registry.Id))
+	if registry.LogLevel != "" && registry.LogLevel != "NONE" {
+		body.SetAttributeValue("log_level", cty.StringVal(registry.LogLevel))
+	}
+
+	if len(registry.EventNotificationConfigs) > 0 {
+		configs := []cty.Value{}
+		for _, config := range registry.EventNotificationConfigs {
+			configs = append(configs, cty.ObjectVal(map[string]cty.Value{
+				"pubsub_topic_name":  cty.StringVal(config.PubsubTopicName),
+				"sub_folder_matches": cty.StringVal(config.SubfolderMatches),
+			}))
+		}
+		body.SetAttributeValue("event_notification_configs", cty.TupleVal(configs))
+	}
+	if config := registry.StateNotificationConfig; config != nil && config.PubsubTopicName != "" {
+		body.SetAttributeValue("state_notification_config", cty.ObjectVal(map[string]cty.Value{
+			"pubsub_topic_name": cty.StringVal(config.PubsubTopicName),
+		}))
+	}
+	if config := registry.MqttConfig; config != nil && config.MqttEnabledState != "" {
+		body.SetAttributeValue("mqtt_config", cty.ObjectVal(map[string]cty.Value{
+			"mqtt_enabled_state": cty.StringVal(config.MqttEnabledState),
+		}))
+	}
+	if config := registry.HttpConfig; config != nil && config.HttpEnabledState != "" {
+		body.SetAttributeValue("http_config", cty.ObjectVal(map[string]cty.Value{
+			"http_enabled_state": cty.StringVal(config.HttpEnabledState),
+		}))
+	}
+
+	credentials := []cty.Value{}
+	for _, credential := range registry.Credentials {
+		certificate := credential.PublicKeyCertificate
+		if certificate == nil {
+			continue
+		}
+		attributes := map[string]cty.Value{
+			"format":      cty.StringVal(certificate.Format),
+			"certificate": cty.StringVal(certificate.Certificate),
+		}
+		if details := certificate.X509Details; details != nil && details.Issuer+details.Subject+details.StartTime+details.ExpiryTime+details.SignatureAlgorithm+details.PublicKeyType != "" {
+			attributes["x509_details"] = cty.ObjectVal(map[string]cty.Value{
+				"issuer":              cty.StringVal(details.Issuer),
+				"subject":             cty.StringVal(details.Subject),
+				"start_time":          cty.StringVal(details.StartTime),
+				"expiry_time":         cty.StringVal(details.ExpiryTime),
+				"signature_algorithm": cty.StringVal(details.SignatureAlgorithm),
+				"public_key_type":     cty.StringVal(details.PublicKeyType),
+			})
+		}
+		credentials = append(credentials, cty.ObjectVal(map[string]cty.Value{
+			"public_key_certificate": cty.ObjectVal(attributes),
+		}))
+	}
+	if len(credentials) > 0 {
+		body.SetAttributeValue("credentials", cty.TupleVal(credentials))
+	}
+}
+
+// generateDevice sets the attributes of the device resource of device other
+// than its ID and registry.
+func generateDevice(body *hclwrite.Body, device *iot.Device) {
+	for name, value := range deviceAttributes(device) {
+		body.SetAttributeValue(name, value)
+	}
+	if config := device.GatewayConfig; config != nil && config.GatewayType == "GATEWAY" {
+		body.SetAttributeValue("gateway_config", cty.ObjectVal(map[string]cty.Value{
+			"gateway_type":        cty.StringVal(config.GatewayType),
+			"gateway_auth_method": cty.StringVal(config.GatewayAuthMethod),
+		}))
+	}
+}
+
+// generateBulkDevices sets the devices of the bulk devices resource of the
+// registry registryID and returns warnings about what it cannot manage.
+func generateBulkDevices(body *hclwrite.Body, registryID string, devices []*iot.Device) []string {
+	values := map[string]cty.Value{}
+	gateways := []string{}
+	for _, device := range devices {
+		attributes := deviceAttributes(device)
+		if len(attributes) == 0 {
+			values[device.Id] = cty.EmptyObjectVal
+		} else {
+			values[device.Id] = cty.ObjectVal(attributes)
+		}
+		if config := device.GatewayConfig; config != nil && config.GatewayType == "GATEWAY" {
+			gateways = append(gateways, device.Id)
+		}
+	}
+	// The devices differ in the attributes they set, so they are written as
+	// an object rather than a map.
+	body.SetAttributeValue("devices", cty.ObjectVal(values))
+
+	if len(gateways) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("the gateway configuration of %d devices of registry %s is not managed by clearblade_iot_devices: %s",
+		len(gateways), registryID, strings.Join(gateways, ", "))}
+}
+
+// deviceAttributes returns the attributes shared by the device and bulk
+// devices resources that device sets.
+func deviceAttributes(device *iot.Device) map[string]cty.Value {
+	attributes := map[string]cty.Value{}
+	if device.LogLevel != "" && device.LogLevel != "NONE" {
+		attributes["log_level"] = cty.StringVal(device.LogLevel)
+	}
+	if device.Blocked {
+		attributes["blocked"] = cty.True
+	}
+	if len(device.Metadata) > 0 {
+		metadata := map[string]cty.Value{}
+		for k, v := range device.Metadata {
+			metadata[k] = cty.StringVal(v)
+		}
+		attributes["metadata"] = cty.MapVal(metadata)
+	}
+
+	credentials := []cty.Value{}
+	for _, credential := range device.Credentials {
+		if credential.PublicKey == nil {
+			continue
+		}
+		attributes := map[string]cty.Value{
+			"public_key": cty.ObjectVal(map[string]cty.Value{
+				"format": cty.StringVal(credential.PublicKey.Format),
+				"key":    cty.StringVal(credential.PublicKey.Key),
+			}),
+		}
+		// Credentials without an expiration report the Unix epoch.
+		if expiry, err := time.Parse(time.RFC3339Nano, credential.ExpirationTime); err == nil && expiry.Unix() != 0 {
+			attributes["expiration_time"] = cty.StringVal(credential.ExpirationTime)
+		}
+		credentials = append(credentials, cty.ObjectVal(attributes))
+	}
+	if len(credentials) > 0 {
+		attributes["credentials"] = cty.TupleVal(credentials)
+	}
+	return attributes
+}
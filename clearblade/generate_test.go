@@ -0,0 +1,159 @@
+package clearblade
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+)
+
+// seedGenerateServer stores the registries and devices the generate tests
+// expect in server.
+func seedGenerateServer(t *testing.T, server *clearbladetest.Server) {
+	t.Helper()
+
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{
+		Id:       "bas-plant",
+		LogLevel: "INFO",
+		EventNotificationConfigs: []*iot.EventNotificationConfig{
+			{PubsubTopicName: "projects/test-project/topics/events"},
+		},
+	})
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "bas-office"})
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "lab"})
+
+	devices := map[string][]*iot.Device{
+		"bas-plant": {
+			// Metadata values are generated verbatim, even ones quoted by
+			// earlier versions of the provider.
+			{Id: "sensor-1", Metadata: map[string]string{"site": "north", "floor": `"2"`}},
+			{Id: "sensor-2", Blocked: true, LogLevel: "DEBUG"},
+			{Id: "gateway-1", GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY", GatewayAuthMethod: "ASSOCIATION_ONLY"}},
+		},
+		"lab": {
+			{Id: "probe-1"},
+		},
+	}
+	for registry, devices := range devices {
+		for _, device := range devices {
+			if err := server.PutDevice(testAccLocation+"/registries/"+registry, device); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func testGenerateConfiguration(t *testing.T, server *clearbladetest.Server, opts generateOptions) (map[string][]byte, generateSummary) {
+	t.Helper()
+	t.Setenv("CLEARBLADE_PROJECT", clearbladetest.Project)
+	t.Setenv("CLEARBLADE_REGION", testAccRegion)
+
+	client, err := server.NewService(context.Background())
+	if err != nil {
+		t.Fatalf("creating client: %s", err)
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = 2
+	}
+	files, summary, err := generateConfiguration(context.Background(), client, clearbladetest.Project, testAccRegion, opts)
+	if err != nil {
+		t.Fatalf("generateConfiguration: %s", err)
+	}
+
+	parser := hclparse.NewParser()
+	for _, name := range generateFiles {
+		if _, diags := parser.ParseHCL(files[name], name); diags.HasErrors() {
+			t.Fatalf("%s: %s\n%s", name, diags, files[name])
+		}
+	}
+	return files, summary
+}
+
+func TestGenerateConfiguration(t *testing.T) {
+	server := newTestAccServer(t)
+	seedGenerateServer(t, server)
+
+	files, summary := testGenerateConfiguration(t, server, generateOptions{Registries: regexp.MustCompile(`^bas-`)})
+	if summary.Registries != 2 || summary.Devices != 3 {
+		t.Errorf("generated %d registries and %d devices, want 2 and 3", summary.Registries, summary.Devices)
+	}
+	for _, want := range []struct{ file, content string }{
+		{"registries.tf", `resource "clearblade_iot_registry" "bas-office"`},
+		{"registries.tf", `log_level = "INFO"`},
+		{"registries.tf", `pubsub_topic_name  = "projects/test-project/topics/events"`},
+		{"devices.tf", `resource "clearblade_iot_device" "bas-plant_sensor-1"`},
+		{"devices.tf", `registry = clearblade_iot_registry.bas-plant.id`},
+		{"devices.tf", `floor = "\"2\""`},
+		{"devices.tf", `blocked   = true`},
+		{"devices.tf", `gateway_type        = "GATEWAY"`},
+		{"imports.tf", `to = clearblade_iot_registry.bas-plant`},
+		{"imports.tf", `id = "bas-plant/gateway-1"`},
+	} {
+		if !strings.Contains(string(files[want.file]), want.content) {
+			t.Errorf("%s does not contain %q:\n%s", want.file, want.content, files[want.file])
+		}
+	}
+	for _, name := range generateFiles {
+		if strings.Contains(string(files[name]), "lab") {
+			t.Errorf("%s contains the lab registry, which does not match the registry pattern:\n%s", name, files[name])
+		}
+	}
+
+	files, summary = testGenerateConfiguration(t, server, generateOptions{BulkDevices: true})
+	for _, want := range []struct{ file, content string }{
+		{"devices.tf", `resource "clearblade_iot_devices" "bas-plant"`},
+		{"devices.tf", `sensor-2 = {`},
+		{"imports.tf", `to = clearblade_iot_devices.lab`},
+	} {
+		if !strings.Contains(string(files[want.file]), want.content) {
+			t.Errorf("%s does not contain %q:\n%s", want.file, want.content, files[want.file])
+		}
+	}
+	if strings.Contains(string(files["imports.tf"]), "clearblade_iot_devices.bas-office") {
+		t.Errorf("imports.tf imports the devices of a registry without devices:\n%s", files["imports.tf"])
+	}
+	if len(summary.Warnings) != 1 || !strings.Contains(summary.Warnings[0], "gateway-1") {
+		t.Errorf("warnings %q, want one about gateway-1", summary.Warnings)
+	}
+}
+
+// TestAccGenerate_import imports the generated configuration and checks that
+// it matches the imported resources, so applying it changes nothing.
+func TestAccGenerate_import(t *testing.T) {
+	for _, bulk := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bulk=%t", bulk), func(t *testing.T) {
+			server := newTestAccServer(t)
+			seedGenerateServer(t, server)
+			files, _ := testGenerateConfiguration(t, server, generateOptions{BulkDevices: bulk})
+			server.ResetRequestCounts()
+
+			resource.Test(t, resource.TestCase{
+				ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+				Steps: []resource.TestStep{
+					{
+						Config: testAccProviderConfig(server) + string(files["registries.tf"]) + string(files["devices.tf"]) + string(files["imports.tf"]),
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttr("clearblade_iot_registry.bas-plant", "log_level", "INFO"),
+							func(*terraform.State) error {
+								for _, method := range []string{"registries.create", "registries.patch", "registries.devices.create", "registries.devices.patch"} {
+									if n := server.RequestCount(method); n > 0 {
+										return fmt.Errorf("importing made %d %s requests, want none", n, method)
+									}
+								}
+								return nil
+							},
+						),
+					},
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package clearblade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+)
+
+// The registry and device resources accept moved blocks from the Google
+// provider's Cloud IoT Core resources, which ClearBlade IoT Core replaces:
+//
+//	moved {
+//	  from = google_cloudiot_registry.plant
+//	  to   = clearblade_iot_registry.plant
+//	}
+//
+// Moving resources between providers needs Terraform 1.8 or later.
+
+// isGoogleSource reports whether req moves a resource of type typeName of the
+// Google provider or its beta.
+func isGoogleSource(req resource.MoveStateRequest, typeName string) bool {
+	if req.SourceTypeName != typeName {
+		return false
+	}
+	// The hostname is ignored, as it differs between registry mirrors.
+	parts := strings.Split(req.SourceProviderAddress, "/")
+	if len(parts) < 2 {
+		return false
+	}
+	return parts[len(parts)-2] == "hashicorp" && (parts[len(parts)-1] == "google" || parts[len(parts)-1] == "google-beta")
+}
+
+// decodeSourceState decodes the raw state of the moved resource into v.
+func decodeSourceState(req resource.MoveStateRequest, v any) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if req.SourceRawState == nil || len(req.SourceRawState.JSON) == 0 {
+		diags.AddError("Unable to Move Resource State", "The state of "+req.SourceTypeName+" is empty or in an unsupported format.")
+		return diags
+	}
+	if err := json.Unmarshal(req.SourceRawState.JSON, v); err != nil {
+		diags.AddError("Unable to Move Resource State", "Could not read the state of "+req.SourceTypeName+": "+err.Error())
+	}
+	return diags
+}
+
+// checkMovedLocation warns when the project or region of a moved resource
+// differ from the ones the provider is configured with, since the resource is
+// read from the configured ones from now on.
+func checkMovedLocation(project, region string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	configuredProject, configuredRegion := os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION")
+	if (project != "" && project != configuredProject) || (region != "" && region != configuredRegion) {
+		diags.AddWarning(
+			"Moved Resource Location Differs",
+			fmt.Sprintf("The moved resource was in project %q and region %q, but the provider is configured with project %q and region %q. "+
+				"It is read from the configured project and region from now on.", project, region, configuredProject, configuredRegion),
+		)
+	}
+	return diags
+}
+
+// parseRegistryPath returns the project, region and ID of the registry path
+// projects/PROJECT/locations/REGION/registries/ID, or the path as the ID if it
+// has another form.
+func parseRegistryPath(path string) (project, region, id string) {
+	parts := strings.Split(path, "/")
+	if len(parts) == 6 && parts[0] == "projects" && parts[2] == "locations" && parts[4] == "registries" {
+		return parts[1], parts[3], parts[5]
+	}
+	return "", "", path
+}
@@ -0,0 +1,153 @@
+package clearblade
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+const testGoogleRegistryState = `{
+  "id": "projects/test-project/locations/us-central1/registries/bas-plant",
+  "name": "bas-plant",
+  "project": "test-project",
+  "region": "us-central1",
+  "log_level": "INFO",
+  "event_notification_configs": [
+    {"pubsub_topic_name": "projects/test-project/topics/events", "subfolder_matches": ""}
+  ],
+  "state_notification_config": {"pubsub_topic_name": "projects/test-project/topics/states"},
+  "mqtt_config": {"mqtt_enabled_state": "MQTT_ENABLED"},
+  "http_config": {"http_enabled_state": "HTTP_DISABLED"},
+  "credentials": [],
+  "timeouts": null
+}`
+
+const testGoogleDeviceState = `{
+  "id": "projects/test-project/locations/us-central1/registries/bas-plant/devices/gateway-1",
+  "name": "gateway-1",
+  "registry": "projects/test-project/locations/us-central1/registries/bas-plant",
+  "num_id": "2817193",
+  "blocked": false,
+  "log_level": "DEBUG",
+  "metadata": {"site": "north"},
+  "credentials": [
+    {"expiration_time": "1970-01-01T00:00:00Z", "public_key": [{"format": "ES256_PEM", "key": "key-data"}]}
+  ],
+  "gateway_config": [
+    {"gateway_type": "GATEWAY", "gateway_auth_method": "ASSOCIATION_ONLY", "last_accessed_gateway_id": "", "last_accessed_gateway_time": ""}
+  ],
+  "last_heartbeat_time": "2023-01-01T00:00:00Z",
+  "timeouts": null
+}`
+
+// moveState runs the state movers of r for a source resource and returns the
+// target state, which is null if no mover handled the source.
+func moveState(t *testing.T, r resource.ResourceWithMoveState, s schema.Schema, providerAddress, typeName, state string) tftypes.Value {
+	t.Helper()
+	ctx := context.Background()
+
+	req := resource.MoveStateRequest{
+		SourceProviderAddress: providerAddress,
+		SourceTypeName:        typeName,
+		SourceRawState:        &tfprotov6.RawState{JSON: []byte(state)},
+	}
+	for _, mover := range r.MoveState(ctx) {
+		resp := resource.MoveStateResponse{TargetState: tfsdk.State{Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(ctx), nil)}}
+		mover.StateMover(ctx, req, &resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("moving %s: %v", typeName, resp.Diagnostics)
+		}
+		if !resp.TargetState.Raw.IsNull() {
+			return resp.TargetState.Raw
+		}
+	}
+	return tftypes.NewValue(s.Type().TerraformType(ctx), nil)
+}
+
+func TestMoveState(t *testing.T) {
+	h := newScaleHarness(t)
+	ctx := context.Background()
+	h.server.PutRegistry(testAccLocation, &iot.DeviceRegistry{
+		Id:       "bas-plant",
+		LogLevel: "INFO",
+		EventNotificationConfigs: []*iot.EventNotificationConfig{
+			{PubsubTopicName: "projects/test-project/topics/events"},
+		},
+		StateNotificationConfig: &iot.StateNotificationConfig{PubsubTopicName: "projects/test-project/topics/states"},
+		MqttConfig:              &iot.MqttConfig{MqttEnabledState: "MQTT_ENABLED"},
+		HttpConfig:              &iot.HttpConfig{HttpEnabledState: "HTTP_DISABLED"},
+	})
+	if err := h.server.PutDevice(testAccLocation+"/registries/bas-plant", &iot.Device{
+		Id:            "gateway-1",
+		LogLevel:      "DEBUG",
+		Metadata:      map[string]string{"site": "north"},
+		Credentials:   []*iot.DeviceCredential{{PublicKey: &iot.PublicKeyCredential{Format: "ES256_PEM", Key: "key-data"}}},
+		GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY", GatewayAuthMethod: "ASSOCIATION_ONLY"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := moveState(t, h.registry.(resource.ResourceWithMoveState), h.registrySchema, "registry.terraform.io/hashicorp/google", "google_cloudiot_registry", testGoogleRegistryState)
+	device := moveState(t, h.device.(resource.ResourceWithMoveState), h.deviceSchema, "registry.terraform.io/hashicorp/google-beta", "google_cloudiot_device", testGoogleDeviceState)
+
+	// The moved states are read like any other.
+	var err error
+	if registry, err = h.read(ctx, h.registry, h.registrySchema, registry); err != nil {
+		t.Fatalf("reading moved registry: %s", err)
+	}
+	if device, err = h.read(ctx, h.device, h.deviceSchema, device); err != nil {
+		t.Fatalf("reading moved device: %s", err)
+	}
+
+	for _, tc := range []struct {
+		state tftypes.Value
+		path  *tftypes.AttributePath
+		want  tftypes.Value
+	}{
+		{registry, tftypes.NewAttributePath().WithAttributeName("id"), tftypes.NewValue(tftypes.String, "bas-plant")},
+		{registry, tftypes.NewAttributePath().WithAttributeName("name"), tftypes.NewValue(tftypes.String, testAccLocation+"/registries/bas-plant")},
+		{registry, tftypes.NewAttributePath().WithAttributeName("log_level"), tftypes.NewValue(tftypes.String, "INFO")},
+		{registry, tftypes.NewAttributePath().WithAttributeName("event_notification_configs").WithElementKeyInt(0).WithAttributeName("pubsub_topic_name"), tftypes.NewValue(tftypes.String, "projects/test-project/topics/events")},
+		{registry, tftypes.NewAttributePath().WithAttributeName("state_notification_config").WithAttributeName("pubsub_topic_name"), tftypes.NewValue(tftypes.String, "projects/test-project/topics/states")},
+		{registry, tftypes.NewAttributePath().WithAttributeName("mqtt_config").WithAttributeName("mqtt_enabled_state"), tftypes.NewValue(tftypes.String, "MQTT_ENABLED")},
+		{device, tftypes.NewAttributePath().WithAttributeName("id"), tftypes.NewValue(tftypes.String, "gateway-1")},
+		{device, tftypes.NewAttributePath().WithAttributeName("registry"), tftypes.NewValue(tftypes.String, "bas-plant")},
+		{device, tftypes.NewAttributePath().WithAttributeName("blocked"), tftypes.NewValue(tftypes.Bool, nil)},
+		{device, tftypes.NewAttributePath().WithAttributeName("log_level"), tftypes.NewValue(tftypes.String, "DEBUG")},
+		{device, tftypes.NewAttributePath().WithAttributeName("metadata").WithElementKeyString("site"), tftypes.NewValue(tftypes.String, "north")},
+		{device, tftypes.NewAttributePath().WithAttributeName("credentials").WithElementKeyInt(0).WithAttributeName("public_key").WithAttributeName("key"), tftypes.NewValue(tftypes.String, "key-data")},
+		{device, tftypes.NewAttributePath().WithAttributeName("credentials").WithElementKeyInt(0).WithAttributeName("expiration_time"), tftypes.NewValue(tftypes.String, nil)},
+		{device, tftypes.NewAttributePath().WithAttributeName("gateway_config").WithAttributeName("gateway_auth_method"), tftypes.NewValue(tftypes.String, "ASSOCIATION_ONLY")},
+	} {
+		got, _, err := tftypes.WalkAttributePath(tc.state, tc.path)
+		if err != nil {
+			t.Errorf("%s: %s", tc.path, err)
+			continue
+		}
+		if !got.(tftypes.Value).Equal(tc.want) {
+			t.Errorf("%s = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestMoveStateOtherSources(t *testing.T) {
+	h := newScaleHarness(t)
+
+	for _, tc := range []struct {
+		providerAddress, typeName string
+	}{
+		{"registry.terraform.io/hashicorp/google", "google_pubsub_topic"},
+		{"registry.terraform.io/example/google", "google_cloudiot_registry"},
+	} {
+		state := moveState(t, h.registry.(resource.ResourceWithMoveState), h.registrySchema, tc.providerAddress, tc.typeName, testGoogleRegistryState)
+		if !state.IsNull() {
+			t.Errorf("moved %s of %s to a registry", tc.typeName, tc.providerAddress)
+		}
+	}
+}
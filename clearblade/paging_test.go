@@ -0,0 +1,188 @@
+package clearblade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/clearblade/go-iot"
+)
+
+// pagingServer is a fake ClearBlade IoT Core endpoint that serves a fixed
+// number of registries and devices, pageSize items at a time.
+type pagingServer struct {
+	*httptest.Server
+
+	registries int
+	devices    int
+	pageSize   int
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newPagingServer(t *testing.T, registries, devices, pageSize int) *pagingServer {
+	t.Helper()
+
+	s := &pagingServer{
+		registries: registries,
+		devices:    devices,
+		pageSize:   pageSize,
+		requests:   map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *pagingServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	s.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getRegistryCredentials"):
+		writeJSON(w, map[string]string{
+			"systemKey":           "registry-system-key",
+			"serviceAccountToken": "registry-token",
+			"url":                 s.URL,
+		})
+	case strings.HasSuffix(r.URL.Path, "/cloudiot"):
+		start, end, next := s.page(r, s.registries)
+		registries := []map[string]string{}
+		for i := start; i < end; i++ {
+			registries = append(registries, map[string]string{"id": fmt.Sprintf("registry-%d", i)})
+		}
+		writeJSON(w, map[string]any{"deviceRegistries": registries, "nextPageToken": next})
+	case strings.HasSuffix(r.URL.Path, "/cloudiot_devices"):
+		start, end, next := s.page(r, s.devices)
+		devices := []map[string]string{}
+		for i := start; i < end; i++ {
+			devices = append(devices, map[string]string{"id": fmt.Sprintf("device-%d", i), "numId": strconv.Itoa(i + 1)})
+		}
+		writeJSON(w, map[string]any{"devices": devices, "nextPageToken": next})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// page returns the bounds of the requested page and the token of the next one.
+func (s *pagingServer) page(r *http.Request, total int) (int, int, string) {
+	size := s.pageSize
+	if requested, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && requested > 0 && requested < size {
+		size = requested
+	}
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	end := start + size
+	if end >= total {
+		return start, total, ""
+	}
+	return start, end, strconv.Itoa(end)
+}
+
+func (s *pagingServer) requestCount(suffix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for path, n := range s.requests {
+		if strings.HasSuffix(path, suffix) {
+			count += n
+		}
+	}
+	return count
+}
+
+func (s *pagingServer) client(t *testing.T) *iot.Service {
+	t.Helper()
+
+	credentials, _ := json.Marshal(iot.ServiceAccountCredentials{
+		SystemKey: "project-system-key",
+		Token:     "project-token",
+		Url:       s.URL,
+		Project:   "test-project",
+	})
+	client, err := iot.NewService(context.Background(), iot.WithHTTPClient(s.Client()), iot.WithServiceAccountCredentials(string(credentials)))
+	if err != nil {
+		t.Fatalf("creating client: %s", err)
+	}
+	return client
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestListDevicesFollowsPageTokens(t *testing.T) {
+	server := newPagingServer(t, 0, 25, 10)
+	parent := "projects/test-project/locations/us-central1/registries/test-registry"
+
+	tests := []struct {
+		name       string
+		pageSize   int64
+		maxResults int64
+		want       int
+		wantPages  int
+	}{
+		{name: "all pages", want: 25, wantPages: 3},
+		{name: "smaller page size", pageSize: 5, want: 25, wantPages: 5},
+		{name: "max results inside first page", maxResults: 4, want: 4, wantPages: 1},
+		{name: "max results across pages", pageSize: 5, maxResults: 12, want: 12, wantPages: 3},
+		{name: "max results above total", maxResults: 100, want: 25, wantPages: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := server.requestCount("/cloudiot_devices")
+
+			devices, err := listDevices(context.Background(), server.client(t), parent, deviceListOptions{PageSize: tt.pageSize, MaxResults: tt.maxResults})
+			if err != nil {
+				t.Fatalf("listDevices: %s", err)
+			}
+			if len(devices) != tt.want {
+				t.Errorf("got %d devices, want %d", len(devices), tt.want)
+			}
+			for i, device := range devices {
+				if want := fmt.Sprintf("device-%d", i); device.Id != want {
+					t.Fatalf("device %d is %q, want %q", i, device.Id, want)
+				}
+			}
+			if pages := server.requestCount("/cloudiot_devices") - before; pages != tt.wantPages {
+				t.Errorf("requested %d pages, want %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestListRegistriesFollowsPageTokens(t *testing.T) {
+	server := newPagingServer(t, 7, 0, 3)
+	parent := "projects/test-project/locations/us-central1"
+
+	registries, err := listRegistries(context.Background(), server.client(t), parent, 0, 0)
+	if err != nil {
+		t.Fatalf("listRegistries: %s", err)
+	}
+	if len(registries) != 7 {
+		t.Errorf("got %d registries, want 7", len(registries))
+	}
+	if pages := server.requestCount("/cloudiot"); pages != 3 {
+		t.Errorf("requested %d pages, want 3", pages)
+	}
+
+	registries, err = listRegistries(context.Background(), server.client(t), parent, 2, 5)
+	if err != nil {
+		t.Fatalf("listRegistries: %s", err)
+	}
+	if len(registries) != 5 {
+		t.Errorf("got %d registries, want 5", len(registries))
+	}
+	if registries[4].Id != "registry-4" {
+		t.Errorf("last registry is %q, want registry-4", registries[4].Id)
+	}
+}
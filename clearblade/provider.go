@@ -2,11 +2,14 @@ package clearblade
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/http"
 	"os"
 	"time"
 
+	"terraform-provider-clearblade/clearblade/cassette"
+
 	"github.com/clearblade/go-iot"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
@@ -37,12 +40,34 @@ func New() provider.Provider {
 	return &clearbladeProvider{}
 }
 
+// Shutdown writes the cassettes the provider recorded to. Call it once the
+// provider server stopped.
+func Shutdown() error {
+	return cassette.CloseRecorders()
+}
+
 // clearbladeProviderModel maps provider schema data to a Go type.
 type clearbladeProviderModel struct {
-	Credentials     types.String `tfsdk:"credentials"`
-	CredentialsFile types.String `tfsdk:"credentials_file"`
-	Project         types.String `tfsdk:"project"`
-	Region          types.String `tfsdk:"region"`
+	Credentials        types.String `tfsdk:"credentials"`
+	CredentialsFile    types.String `tfsdk:"credentials_file"`
+	Project            types.String `tfsdk:"project"`
+	Region             types.String `tfsdk:"region"`
+	BatchRefresh       types.Bool   `tfsdk:"batch_refresh"`
+	TrackActivity      types.Bool   `tfsdk:"track_activity"`
+	DeletionProtection types.Bool   `tfsdk:"deletion_protection"`
+}
+
+// providerData is made available to data sources and resources by Configure.
+type providerData struct {
+	client *iot.Service
+	// deviceCache serves device reads from per-registry listings. It is nil
+	// unless batch_refresh is enabled.
+	deviceCache *deviceListCache
+	// trackActivity is the default of the track_activity resource attributes.
+	trackActivity bool
+	// deletionProtection is the default of the deletion_protection resource
+	// attributes.
+	deletionProtection bool
 }
 
 // clearbladeProvider is the provider implementation.
@@ -77,6 +102,21 @@ func (p *clearbladeProvider) Schema(_ context.Context, _ provider.SchemaRequest,
 			"region": schema.StringAttribute{
 				Optional: true,
 			},
+			"batch_refresh": schema.BoolAttribute{
+				Optional: true,
+				Description: "When true, the first read of a clearblade_iot_device in a registry lists every device of that registry once, " +
+					"and later device reads in the same registry are served from that listing. Speeds up refreshing many devices. Defaults to false.",
+			},
+			"track_activity": schema.BoolAttribute{
+				Optional: true,
+				Description: "Whether resources store device activity such as heartbeat, event and state times, the last device state and the last error in the Terraform state. " +
+					"Can be overridden by the track_activity attribute of a resource. Defaults to true.",
+			},
+			"deletion_protection": schema.BoolAttribute{
+				Optional: true,
+				Description: "Whether registries and devices whose deletion_protection attribute is not set are protected from being destroyed. " +
+					"Can be overridden by the deletion_protection attribute of a resource. Defaults to false.",
+			},
 		},
 	}
 }
@@ -155,10 +195,21 @@ func (p *clearbladeProvider) Configure(ctx context.Context, req provider.Configu
 		return
 	}
 
+	httpClient, err := p.newHTTPClient()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Create Clearblade IoT Core API Client",
+			"An unexpected error occurred when creating the HTTP client of the Clearblade IoT Core API. "+
+				"Check the CLEARBLADE_RECORD and CLEARBLADE_REPLAY environment variables.\n\n"+
+				"HTTP Client Error: "+err.Error(),
+		)
+		return
+	}
+
 	// Create a new Clearblade IoT Core client using the configuration values
 	client, err := iot.NewService(
 		ctx,
-		iot.WithHTTPClient(p.newHTTPClient()),
+		iot.WithHTTPClient(httpClient),
 		credentialsOption,
 	)
 	if err != nil {
@@ -173,8 +224,16 @@ func (p *clearbladeProvider) Configure(ctx context.Context, req provider.Configu
 
 	// Make the Clearblade IoT Core client available during DataSource and Resource
 	// type Configure methods.
-	resp.DataSourceData = client
-	resp.ResourceData = client
+	data := &providerData{
+		client:             client,
+		trackActivity:      config.TrackActivity.IsNull() || config.TrackActivity.ValueBool(),
+		deletionProtection: config.DeletionProtection.ValueBool(),
+	}
+	if config.BatchRefresh.ValueBool() {
+		data.deviceCache = newDeviceListCache(client)
+	}
+	resp.DataSourceData = data
+	resp.ResourceData = data
 
 	tflog.Info(ctx, "Configured Clearblade IoT Core client", map[string]any{"success": true})
 }
@@ -184,6 +243,11 @@ func (p *clearbladeProvider) DataSources(_ context.Context) []func() datasource.
 	return []func() datasource.DataSource{
 		NewDeviceRegistriesDataSource,
 		NewDevicesDataSource,
+		NewDeviceStatesDataSource,
+		NewDeviceDataSource,
+		NewDeviceRegistryDataSource,
+		NewDeviceSearchDataSource,
+		NewRegistrySummaryDataSource,
 	}
 }
 
@@ -192,18 +256,40 @@ func (p *clearbladeProvider) Resources(_ context.Context) []func() resource.Reso
 	return []func() resource.Resource{
 		NewDeviceResource,
 		NewDeviceRegistryResource,
+		NewDeviceCommandResource,
+		NewDevicesResource,
 	}
 }
 
-func (p *clearbladeProvider) newHTTPClient() *http.Client {
-	return &http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   2 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).Dial,
-			IdleConnTimeout: 60 * time.Second,
-		},
-		Timeout: 5 * time.Second,
+// newHTTPClient returns the client of the ClearBlade IoT Core API. Setting
+// CLEARBLADE_RECORD to a file records the API interactions to that cassette,
+// and setting CLEARBLADE_REPLAY to a cassette serves them from it instead of
+// the API. Recorded interactions are written by Shutdown.
+func (p *clearbladeProvider) newHTTPClient() (*http.Client, error) {
+	var transport http.RoundTripper = &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout:   2 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		IdleConnTimeout: 60 * time.Second,
 	}
+
+	record, replay := os.Getenv("CLEARBLADE_RECORD"), os.Getenv("CLEARBLADE_REPLAY")
+	switch {
+	case record != "" && replay != "":
+		return nil, errors.New("CLEARBLADE_RECORD and CLEARBLADE_REPLAY cannot both be set")
+	case record != "":
+		transport = cassette.NewRecorder(record, transport)
+	case replay != "":
+		replayer, err := cassette.NewReplayer(replay)
+		if err != nil {
+			return nil, err
+		}
+		transport = replayer
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   5 * time.Second,
+	}, nil
 }
@@ -0,0 +1,160 @@
+package clearblade
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terraform-provider-clearblade/clearblade/cassette"
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+const (
+	// testAccRegion is the region the acceptance tests configure.
+	testAccRegion = "us-central1"
+	// testAccLocation is the parent of the registries created by the
+	// acceptance tests.
+	testAccLocation = "projects/" + clearbladetest.Project + "/locations/" + testAccRegion
+)
+
+func init() {
+	// Retry the faults the fake injects without waiting as long as for the
+	// API.
+	apiRetryPolicy.delay = time.Millisecond
+	apiRetryPolicy.maxDelay = 10 * time.Millisecond
+}
+
+// testAccProtoV6ProviderFactories are used to instantiate the provider during
+// acceptance testing. The factory function is called for each Terraform CLI
+// command executed to create a provider server to which the CLI can reattach.
+var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"clearblade": providerserver.NewProtocol6WithError(New()),
+}
+
+// newTestAccServer starts a fake ClearBlade IoT Core API for an acceptance
+// test, so the tests run without an account or network access.
+func newTestAccServer(t *testing.T) *clearbladetest.Server {
+	t.Helper()
+
+	server := clearbladetest.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testAccProviderConfig configures the provider to use server.
+func testAccProviderConfig(server *clearbladetest.Server) string {
+	return testAccProviderConfigWith(server, "")
+}
+
+// testAccProviderConfigWith is testAccProviderConfig with additional provider
+// arguments.
+func testAccProviderConfigWith(server *clearbladetest.Server, arguments string) string {
+	return fmt.Sprintf(`
+provider "clearblade" {
+  credentials = %q
+  project     = %q
+  region      = %q
+%s}
+`, server.Credentials(), clearbladetest.Project, testAccRegion, arguments)
+}
+
+func TestAccProvider_recordReplay(t *testing.T) {
+	server := newTestAccServer(t)
+	testAccRecordReplay(t, server, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeviceConfig(server, `
+  log_level = "INFO"
+`),
+				Check: resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "INFO"),
+			},
+			{
+				Config: testAccDeviceConfig(server, `
+  log_level = "DEBUG"
+`),
+				Check: resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "DEBUG"),
+			},
+		},
+	})
+}
+
+// TestAccProvider_recordReplayCredentials replays a device public key and a
+// registry certificate, which must replay as they were recorded for the
+// checks to pass and the plan to be empty.
+func TestAccProvider_recordReplayCredentials(t *testing.T) {
+	server := newTestAccServer(t)
+	certificate := testCertificate(t, "test-ca")
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{
+		Id: "certificate-registry",
+		Credentials: []*iot.RegistryCredential{
+			{PublicKeyCertificate: &iot.PublicKeyCertificate{Format: "X509_CERTIFICATE_PEM", Certificate: certificate}},
+		},
+	})
+	config := testAccDeviceConfig(server, `
+  credentials = [
+    {
+      public_key = {
+        format = "ES256_PEM"
+        key    = <<-EOT
+`+testAccDeviceKey+`EOT
+      }
+    },
+  ]
+`) + `
+data "clearblade_iot_registry" "certificate" {
+  id = "certificate-registry"
+}
+`
+	testAccRecordReplay(t, server, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "credentials.0.public_key.key", testAccDeviceKey),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.certificate", "credentials.0.public_key_certificate.certificate", certificate),
+					resource.TestCheckResourceAttr("data.clearblade_iot_registry.certificate", "credentials.0.public_key_certificate.x509_details.subject", "CN=test-ca"),
+				),
+			},
+		},
+	})
+}
+
+// testAccRecordReplay runs testCase recording the API interactions to a
+// cassette, and again replaying them with server stopped.
+func testAccRecordReplay(t *testing.T, server *clearbladetest.Server, testCase resource.TestCase) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	t.Cleanup(func() { cassette.Forget(path) })
+
+	t.Setenv("CLEARBLADE_RECORD", path)
+	resource.Test(t, testCase)
+	if err := Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay the cassette with the fake stopped.
+	server.Close()
+	t.Setenv("CLEARBLADE_RECORD", "")
+	t.Setenv("CLEARBLADE_REPLAY", path)
+	resource.Test(t, testCase)
+
+	replayer, err := cassette.NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unmatched := replayer.Unmatched(); len(unmatched) > 0 {
+		t.Errorf("unmatched requests: %q", unmatched)
+	}
+	if n := replayer.Unused(); n > 0 {
+		t.Errorf("%d recorded interactions were not replayed", n)
+	}
+}
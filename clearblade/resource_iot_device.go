@@ -6,10 +6,13 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/clearblade/go-iot"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
@@ -26,6 +29,7 @@ var (
 	_ resource.Resource                = &deviceResource{}
 	_ resource.ResourceWithConfigure   = &deviceResource{}
 	_ resource.ResourceWithImportState = &deviceResource{}
+	_ resource.ResourceWithMoveState   = &deviceResource{}
 )
 
 func NewDeviceResource() resource.Resource {
@@ -34,15 +38,19 @@ func NewDeviceResource() resource.Resource {
 
 // deviceResource is the resource implementation.
 type deviceResource struct {
-	client *iot.Service
+	client      *iot.Service
+	deviceCache *deviceListCache
+	// trackActivity is the provider default of track_activity.
+	trackActivity bool
+	// deletionProtection is the provider default of deletion_protection.
+	deletionProtection bool
 }
 
 type deviceResourceModel struct {
-	ID          types.String `tfsdk:"id"`
-	Name        types.String `tfsdk:"name"`
-	NumID       types.String `tfsdk:"num_id"`
-	Credentials types.List   `tfsdk:"credentials"`
-	// Credentials        []DeviceCredentialsModel `tfsdk:"credentials"`
+	ID                 types.String `tfsdk:"id"`
+	Name               types.String `tfsdk:"name"`
+	NumID              types.String `tfsdk:"num_id"`
+	Credentials        types.List   `tfsdk:"credentials"`
 	LastHeartbeatTime  types.String `tfsdk:"last_heartbeat_time"`
 	LastEventTime      types.String `tfsdk:"last_event_time"`
 	LastStateTime      types.String `tfsdk:"last_state_time"`
@@ -57,10 +65,10 @@ type deviceResourceModel struct {
 	Metadata           types.Map    `tfsdk:"metadata"`
 	GatewayConfig      types.Object `tfsdk:"gateway_config"`
 	Registry           types.String `tfsdk:"registry"`
-}
-
-type DeviceCredentialsModel struct {
-	PublicKeyCertificate DevicePublicKeyCertificateModel `tfsdk:"public_key_certificate"`
+	TrackActivity      types.Bool   `tfsdk:"track_activity"`
+	DeletionProtection types.Bool   `tfsdk:"deletion_protection"`
+	DeletionPolicy     types.String `tfsdk:"deletion_policy"`
+	StripCredentials   types.Bool   `tfsdk:"strip_credentials_on_block"`
 }
 
 type DevicePublicKeyCertificateModel struct {
@@ -358,10 +366,64 @@ func (r *deviceResource) Schema(_ context.Context, _ resource.SchemaRequest, res
 				Description: "The name of the device registry where this device should be created.",
 				Required:    true,
 			},
+			"track_activity": schema.BoolAttribute{
+				Description: "Whether to store device activity in the state: the last_*_time attributes, last_error_status, state and the last accessed gateway in gateway_config. " +
+					"These change every time the device connects, so disabling this keeps refreshes of large fleets quiet. Defaults to the provider track_activity setting.",
+				Optional: true,
+			},
+			"deletion_protection": schema.BoolAttribute{
+				Description: "Whether Terraform is prevented from destroying the device. Set it to false and apply the configuration before destroying a protected device. " +
+					"Defaults to the provider deletion_protection setting.",
+				Optional: true,
+			},
+			"deletion_policy": schema.StringAttribute{
+				Description: `What destroying the device does: DELETE deletes it, BLOCK blocks it and keeps it in ClearBlade IoT Core, and ABANDON leaves it as it is. ` +
+					`With BLOCK and ABANDON the device is only removed from the Terraform state. Possible values: ["DELETE", "BLOCK", "ABANDON"]. Defaults to DELETE.`,
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf("DELETE", "BLOCK", "ABANDON"),
+				},
+			},
+			"strip_credentials_on_block": schema.BoolAttribute{
+				Description: "Whether destroying the device with the BLOCK deletion_policy also removes its credentials. Defaults to false.",
+				Optional:    true,
+			},
 		},
 	}
 }
 
+// tracksActivity reports whether device activity is stored for the device
+// model, falling back to the provider default.
+func (r *deviceResource) tracksActivity(model deviceResourceModel) bool {
+	if model.TrackActivity.IsNull() || model.TrackActivity.IsUnknown() {
+		return r.trackActivity
+	}
+	return model.TrackActivity.ValueBool()
+}
+
+// clearDeviceActivity nulls the attributes that change with device activity
+// rather than with configuration.
+func clearDeviceActivity(model *deviceResourceModel) {
+	model.LastHeartbeatTime = types.StringNull()
+	model.LastEventTime = types.StringNull()
+	model.LastStateTime = types.StringNull()
+	model.LastConfigAckTime = types.StringNull()
+	model.LastConfigSendTime = types.StringNull()
+	model.LastErrorTime = types.StringNull()
+	model.LastErrorStatus = types.ObjectNull(LastErrorStatusModelTypes)
+	model.State = types.ObjectNull(StateModelTypes)
+
+	if !model.GatewayConfig.IsNull() && !model.GatewayConfig.IsUnknown() {
+		attributes := model.GatewayConfig.Attributes()
+		model.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, map[string]attr.Value{
+			"gateway_type":               attributes["gateway_type"],
+			"gateway_auth_method":        attributes["gateway_auth_method"],
+			"last_accessed_gateway_id":   types.StringNull(),
+			"last_accessed_gateway_time": types.StringNull(),
+		})
+	}
+}
+
 // Create creates the resource and sets the initial Terraform state.
 func (r *deviceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	tflog.Debug(ctx, "Creating iot device resource")
@@ -376,47 +438,20 @@ func (r *deviceResource) Create(ctx context.Context, req resource.CreateRequest,
 	}
 
 	// Generate API request body from plan
-	credentials := []*iot.DeviceCredential{}
-	if len(plan.Credentials.Elements()) > 0 {
-		var credentialsModel []DeviceCredentialsModel
-		plan.Credentials.ElementsAs(ctx, &credentialsModel, false)
-
-		for _, v := range credentialsModel {
-			credentials = append(credentials, &iot.DeviceCredential{
-				ExpirationTime: v.PublicKeyCertificate.ExpirationTime.ValueString(),
-				PublicKey: &iot.PublicKeyCredential{
-					Format: v.PublicKeyCertificate.PublicKey.Format.ValueString(),
-					Key:    v.PublicKeyCertificate.PublicKey.Key.ValueString(),
-				},
-			})
-		}
-	}
-
-	var gatewayConfigModel GatewayConfigModel
-	plan.GatewayConfig.As(ctx, &gatewayConfigModel, basetypes.ObjectAsOptions{})
-
-	metadaAttribute := plan.Metadata.Elements()
-	convMetadata := make(map[string]string)
-	for k, v := range metadaAttribute {
-		convMetadata[k] = v.String()
+	body, diags := expandDevice(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// Create a new device resource on ClearBlade IoT Core
 	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), plan.Registry.ValueString())
-	device, err := r.client.Projects.Locations.Registries.Devices.Create(parent, &iot.Device{
-		Id:          plan.ID.ValueString(),
-		Credentials: credentials,
-		Blocked:     plan.Blocked.ValueBool(),
-		LogLevel:    plan.LogLevel.ValueString(),
-		Metadata:    convMetadata,
-		GatewayConfig: &iot.GatewayConfig{
-			GatewayAuthMethod:       gatewayConfigModel.GatewayAuthMethod.ValueString(),
-			GatewayType:             gatewayConfigModel.GatewayType.ValueString(),
-			LastAccessedGatewayId:   gatewayConfigModel.LastAccessedGatewayID.ValueString(),
-			LastAccessedGatewayTime: gatewayConfigModel.LastAccessedGatewayTime.ValueString(),
-		},
-	}).Do()
-
+	var device *iot.Device
+	err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+		var err error
+		device, err = r.client.Projects.Locations.Registries.Devices.Create(parent, body).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating a device",
@@ -426,153 +461,12 @@ func (r *deviceResource) Create(ctx context.Context, req resource.CreateRequest,
 	}
 
 	tflog.Debug(ctx, "device created")
+	r.invalidateDeviceCache(parent)
 
 	// Map response body to schema and populate Computed attribute values
-	plan.Name = types.StringValue(device.Name)
-	plan.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
-	plan.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
-	plan.LastErrorTime = types.StringValue(device.LastErrorTime)
-	plan.LastEventTime = types.StringValue(device.LastEventTime)
-	plan.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
-	plan.LastStateTime = types.StringValue(device.LastStateTime)
-	plan.NumID = types.StringValue(strconv.FormatUint(device.NumId, 10))
-	plan.Blocked = types.BoolValue(device.Blocked)
-	plan.LogLevel = types.StringValue(device.LogLevel)
-
-	// attributes := map[string]attr.Value{
-	// 	"update_time": types.StringValue(device.State.UpdateTime),
-	// 	"binary_data": types.StringValue(device.State.BinaryData),
-	// }
-	// plan.State = types.ObjectValueMust(StateModelTypes, attributes)
-
-	if plan.State.IsNull() {
-		attributes := map[string]attr.Value{
-			"update_time": types.StringNull(),
-			"binary_data": types.StringNull(),
-		}
-		plan.State = types.ObjectValueMust(StateModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"update_time": types.StringValue(device.State.UpdateTime),
-			"binary_data": types.StringValue(device.State.BinaryData),
-		}
-		plan.State = types.ObjectValueMust(StateModelTypes, attributes)
-	}
-
-	if plan.LastErrorStatus.IsNull() {
-		attributes := map[string]attr.Value{
-			"code":    types.Int64Null(),
-			"message": types.StringNull(),
-		}
-		plan.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"code":    types.Int64Value(device.LastErrorStatus.Code),
-			"message": types.StringValue(device.LastErrorStatus.Message),
-		}
-		plan.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, attributes)
-	}
-
-	if plan.GatewayConfig.IsNull() {
-		attributes := map[string]attr.Value{
-			"gateway_type":               types.StringNull(),
-			"gateway_auth_method":        types.StringNull(),
-			"last_accessed_gateway_id":   types.StringNull(),
-			"last_accessed_gateway_time": types.StringNull(),
-		}
-		plan.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"gateway_type":               types.StringValue(device.GatewayConfig.GatewayType),
-			"gateway_auth_method":        types.StringValue(device.GatewayConfig.GatewayAuthMethod),
-			"last_accessed_gateway_id":   types.StringValue(device.GatewayConfig.LastAccessedGatewayId),
-			"last_accessed_gateway_time": types.StringValue(device.GatewayConfig.LastAccessedGatewayTime),
-		}
-		plan.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, attributes)
-	}
-
-	if plan.Config.IsNull() {
-		attributes := map[string]attr.Value{
-			"version":           types.Int64Null(),
-			"cloud_update_time": types.StringNull(),
-			"device_ack_time":   types.StringNull(),
-			"binary_data":       types.StringNull(),
-		}
-		plan.Config = types.ObjectValueMust(ConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"version":           types.Int64Value(device.Config.Version),
-			"cloud_update_time": types.StringValue(device.Config.CloudUpdateTime),
-			"device_ack_time":   types.StringValue(device.Config.DeviceAckTime),
-			"binary_data":       types.StringValue(device.Config.BinaryData),
-		}
-		plan.Config = types.ObjectValueMust(ConfigModelTypes, attributes)
-	}
-
-	if plan.Credentials.IsNull() {
-		tflog.Debug(ctx, "value detected NULL - CREATE")
-		plan.Credentials = types.ListNull(plan.Credentials.ElementType(ctx))
-	}
-
-	if plan.Credentials.IsUnknown() {
-		var credentials []DeviceCredentialsModel
-
-		for _, credential := range device.Credentials {
-			m := DeviceCredentialsModel{
-				PublicKeyCertificate: DevicePublicKeyCertificateModel{
-					ExpirationTime: types.StringValue(credential.ExpirationTime),
-					PublicKey: PublicKeyModel{
-						Format: types.StringValue(credential.PublicKey.Format),
-						Key:    types.StringValue(credential.PublicKey.Key),
-					},
-				},
-			}
-			credentials = append(credentials, m)
-		}
-		plan.Credentials, _ = types.ListValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
-		// plan.Credentials, _ = types.SetValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
-
-	}
-
-	if !plan.Credentials.IsUnknown() && !plan.Credentials.IsNull() {
-		var credentials []DeviceCredentialsModel
-
-		for _, credential := range device.Credentials {
-			m := DeviceCredentialsModel{
-				PublicKeyCertificate: DevicePublicKeyCertificateModel{
-					ExpirationTime: types.StringValue(credential.ExpirationTime),
-					PublicKey: PublicKeyModel{
-						Format: types.StringValue(credential.PublicKey.Format),
-						Key:    types.StringValue(credential.PublicKey.Key),
-					},
-				},
-			}
-			credentials = append(credentials, m)
-		}
-		if credentials == nil {
-			tflog.Debug(ctx, "Known value detected NULL - Credentials - CREATE")
-		} else {
-			plan.Credentials, _ = types.ListValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
-			// plan.Credentials, _ = types.SetValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
-		}
-
-	}
-
-	if plan.Metadata.IsNull() {
-		plan.Metadata = types.MapNull(plan.Metadata.ElementType(ctx))
-	} else {
-		attributes := map[string]attr.Value{}
-		for k, v := range device.Metadata {
-			s, _ := strconv.Unquote(v)
-			ctx = tflog.SetField(ctx, "state attributes", s)
-			attributes[k] = types.StringValue(s)
-		}
-		// str := fmt.Sprintf("%+v", device.Metadata)
-		// str, err := json.Marshal(device.Metadata)
-		// if err != nil {
-		// 	panic(err)
-		// }
-		plan.Metadata, _ = types.MapValueFrom(ctx, plan.Metadata.ElementType(ctx), attributes)
+	resp.Diagnostics.Append(r.flattenDevice(ctx, device, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// Set state to fully populated data
@@ -581,7 +475,10 @@ func (r *deviceResource) Create(ctx context.Context, req resource.CreateRequest,
 	if resp.Diagnostics.HasError() {
 		return
 	}
-
+	// Private is nil when the resource is not served by the framework.
+	if resp.Private != nil {
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, createdPrivateKey, []byte("true"))...)
+	}
 }
 
 // Read refreshes the Terraform state with the latest data.
@@ -605,8 +502,24 @@ func (r *deviceResource) Read(ctx context.Context, req resource.ReadRequest, res
 		state.ID = types.StringValue(slice[1])
 	}
 
-	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString(), state.ID.ValueString())
-	device, err := r.client.Projects.Locations.Registries.Devices.Get(parent).Do()
+	registryParent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString())
+	created, diags := req.Private.GetKey(ctx, createdPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	retryable := isRetryableError
+	if created != nil {
+		retryable = isRetryableAfterCreateError
+	}
+	var device *iot.Device
+	err := apiRetryPolicy.do(ctx, retryable, func() error {
+		var err error
+		device, err = r.getDevice(ctx, registryParent, state.ID.ValueString())
+		return err
+	})
+	if isNotFoundError(err) {
+		tflog.Warn(ctx, "device not found, removing it from state", map[string]any{"device": registryParent + "/devices/" + state.ID.ValueString()})
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error reading ClearBlade IoT Core device detail",
@@ -616,112 +529,9 @@ func (r *deviceResource) Read(ctx context.Context, req resource.ReadRequest, res
 	}
 
 	// Overwrite items with refreshed state
-	state.Name = types.StringValue(device.Name)
-	state.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
-	state.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
-	state.LastErrorTime = types.StringValue(device.LastErrorTime)
-	state.LastEventTime = types.StringValue(device.LastEventTime)
-	state.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
-	state.LastStateTime = types.StringValue(device.LastStateTime)
-	state.NumID = types.StringValue(strconv.FormatUint(device.NumId, 10))
-	state.Blocked = types.BoolValue(device.Blocked)
-	state.LogLevel = types.StringValue(device.LogLevel)
-
-	if state.State.IsNull() {
-		attributes := map[string]attr.Value{
-			"update_time": types.StringNull(),
-			"binary_data": types.StringNull(),
-		}
-		state.State = types.ObjectValueMust(StateModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"update_time": types.StringValue(device.State.UpdateTime),
-			"binary_data": types.StringValue(device.State.BinaryData),
-		}
-		state.State = types.ObjectValueMust(StateModelTypes, attributes)
-	}
-
-	if state.LastErrorStatus.IsNull() {
-		attributes := map[string]attr.Value{
-			"code":    types.Int64Null(),
-			"message": types.StringNull(),
-		}
-		state.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"code":    types.Int64Value(device.LastErrorStatus.Code),
-			"message": types.StringValue(device.LastErrorStatus.Message),
-		}
-		state.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, attributes)
-	}
-
-	if state.GatewayConfig.IsNull() {
-		attributes := map[string]attr.Value{
-			"gateway_type":               types.StringNull(),
-			"gateway_auth_method":        types.StringNull(),
-			"last_accessed_gateway_id":   types.StringNull(),
-			"last_accessed_gateway_time": types.StringNull(),
-		}
-		state.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"gateway_type":               types.StringValue(device.GatewayConfig.GatewayType),
-			"gateway_auth_method":        types.StringValue(device.GatewayConfig.GatewayAuthMethod),
-			"last_accessed_gateway_id":   types.StringValue(device.GatewayConfig.LastAccessedGatewayId),
-			"last_accessed_gateway_time": types.StringValue(device.GatewayConfig.LastAccessedGatewayTime),
-		}
-		state.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, attributes)
-	}
-
-	if state.Config.IsNull() {
-		attributes := map[string]attr.Value{
-			"version":           types.Int64Null(),
-			"cloud_update_time": types.StringNull(),
-			"device_ack_time":   types.StringNull(),
-			"binary_data":       types.StringNull(),
-		}
-		state.Config = types.ObjectValueMust(ConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"version":           types.Int64Value(device.Config.Version),
-			"cloud_update_time": types.StringValue(device.Config.CloudUpdateTime),
-			"device_ack_time":   types.StringValue(device.Config.DeviceAckTime),
-			"binary_data":       types.StringValue(device.Config.BinaryData),
-		}
-		state.Config = types.ObjectValueMust(ConfigModelTypes, attributes)
-	}
-
-	attributes := map[string]attr.Value{}
-	for k, v := range device.Metadata {
-		s, _ := strconv.Unquote(v)
-		ctx = tflog.SetField(ctx, "state attributes ll", s)
-		tflog.Debug(ctx, "state attributes read")
-		attributes[k] = types.StringValue(s)
-	}
-	state.Metadata, _ = types.MapValueFrom(ctx, state.Metadata.ElementType(ctx), attributes)
-
-	if state.Credentials.IsNull() {
-		tflog.Debug(ctx, "value detected NULL - READ")
-		state.Credentials = types.ListNull(state.Credentials.ElementType(ctx))
-		// state.Credentials = types.SetNull(state.Credentials.ElementType(ctx))
-	} else {
-		tflog.Debug(ctx, "value detected KNOWN - READ")
-		var credentials []DeviceCredentialsModel
-
-		for _, credential := range device.Credentials {
-			m := DeviceCredentialsModel{
-				PublicKeyCertificate: DevicePublicKeyCertificateModel{
-					ExpirationTime: types.StringValue(credential.ExpirationTime),
-					PublicKey: PublicKeyModel{
-						Format: types.StringValue(credential.PublicKey.Format),
-						Key:    types.StringValue(credential.PublicKey.Key),
-					},
-				},
-			}
-			credentials = append(credentials, m)
-		}
-		state.Credentials, _ = types.ListValueFrom(ctx, state.Credentials.ElementType(ctx), credentials)
-		// plan.Credentials, _ = types.SetValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
+	resp.Diagnostics.Append(r.flattenDevice(ctx, device, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// Set refreshed state
@@ -730,7 +540,9 @@ func (r *deviceResource) Read(ctx context.Context, req resource.ReadRequest, res
 	if resp.Diagnostics.HasError() {
 		return
 	}
-
+	if resp.Private != nil {
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, createdPrivateKey, nil)...)
+	}
 }
 
 // Update updates the resource and sets the updated Terraform state on success.
@@ -746,198 +558,224 @@ func (r *deviceResource) Update(ctx context.Context, req resource.UpdateRequest,
 	}
 
 	// Generate API request body from plan
-	credentials := []*iot.DeviceCredential{}
-	if len(plan.Credentials.Elements()) > 0 {
-		var credentialsModel []DeviceCredentialsModel
-		plan.Credentials.ElementsAs(ctx, &credentialsModel, false)
-
-		for _, v := range credentialsModel {
-			credentials = append(credentials, &iot.DeviceCredential{
-				ExpirationTime: v.PublicKeyCertificate.ExpirationTime.ValueString(),
-				PublicKey: &iot.PublicKeyCredential{
-					Format: v.PublicKeyCertificate.PublicKey.Format.ValueString(),
-					Key:    v.PublicKeyCertificate.PublicKey.Key.ValueString(),
-				},
-			})
-		}
-	}
-
-	var gatewayConfigModel GatewayConfigModel
-	plan.GatewayConfig.As(ctx, &gatewayConfigModel, basetypes.ObjectAsOptions{})
-
-	metadaAttribute := plan.Metadata.Elements()
-	convMetadata := make(map[string]string)
-	for k, v := range metadaAttribute {
-		convMetadata[k] = v.String()
+	body, diags := expandDevice(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// Update existing device resource on ClearBlade IoT Core
 	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), plan.Registry.ValueString(), plan.ID.ValueString())
-	device, err := r.client.Projects.Locations.Registries.Devices.Patch(parent, &iot.Device{
-		Id:          plan.ID.ValueString(),
-		Credentials: credentials,
-		Blocked:     plan.Blocked.ValueBool(),
-		LogLevel:    plan.LogLevel.ValueString(),
-		Metadata:    convMetadata,
-		GatewayConfig: &iot.GatewayConfig{
-			GatewayAuthMethod:       gatewayConfigModel.GatewayAuthMethod.ValueString(),
-			GatewayType:             gatewayConfigModel.GatewayType.ValueString(),
-			LastAccessedGatewayId:   gatewayConfigModel.LastAccessedGatewayID.ValueString(),
-			LastAccessedGatewayTime: gatewayConfigModel.LastAccessedGatewayTime.ValueString(),
-		},
-	}).
-		// UpdateMask(`blocked,credentials,logLevel,metadata`).Do()
-	UpdateMask(`blocked,credentials,gatewayConfig.gatewayAuthMethod,logLevel,metadata`).Do()
-	// Could not create a new device, unexpected error: googleapi: Error 400: The field mask 'updateMask' must contain mutable fields. The following fields are mutable: ["blocked","credentials","gatewayConfig.gatewayAuthMethod","logLevel","metadata"]
-
+	var device *iot.Device
+	err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+		var err error
+		device, err = r.client.Projects.Locations.Registries.Devices.Patch(parent, body).
+			UpdateMask(`blocked,credentials,gatewayConfig.gatewayAuthMethod,logLevel,metadata`).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Error creating a device",
-			"Could not create a new device, unexpected error: "+err.Error(),
+			"Error updating a device",
+			"Could not update device "+plan.ID.ValueString()+", unexpected error: "+err.Error(),
 		)
 		return
 	}
 
 	tflog.Debug(ctx, "device updated")
+	r.invalidateDeviceCache(strings.TrimSuffix(parent, "/devices/"+plan.ID.ValueString()))
 
 	// Update device resource - Map response body to schema and populate Computed attribute values
-	plan.Name = types.StringValue(device.Name)
-	plan.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
-	plan.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
-	plan.LastErrorTime = types.StringValue(device.LastErrorTime)
-	plan.LastEventTime = types.StringValue(device.LastEventTime)
-	plan.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
-	plan.LastStateTime = types.StringValue(device.LastStateTime)
-	plan.NumID = types.StringValue(strconv.FormatUint(device.NumId, 10))
-	plan.Blocked = types.BoolValue(device.Blocked)
-	plan.LogLevel = types.StringValue(device.LogLevel)
-
-	if plan.State.IsNull() {
-		attributes := map[string]attr.Value{
-			"update_time": types.StringNull(),
-			"binary_data": types.StringNull(),
-		}
-		plan.State = types.ObjectValueMust(StateModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"update_time": types.StringValue(device.State.UpdateTime),
-			"binary_data": types.StringValue(device.State.BinaryData),
-		}
-		plan.State = types.ObjectValueMust(StateModelTypes, attributes)
+	resp.Diagnostics.Append(r.flattenDevice(ctx, device, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	if plan.LastErrorStatus.IsNull() {
-		attributes := map[string]attr.Value{
-			"code":    types.Int64Null(),
-			"message": types.StringNull(),
-		}
-		plan.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"code":    types.Int64Value(device.LastErrorStatus.Code),
-			"message": types.StringValue(device.LastErrorStatus.Message),
-		}
-		plan.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, attributes)
+	// Set state to fully populated data
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
+}
 
-	if plan.GatewayConfig.IsNull() {
-		attributes := map[string]attr.Value{
-			"gateway_type":               types.StringNull(),
-			"gateway_auth_method":        types.StringNull(),
-			"last_accessed_gateway_id":   types.StringNull(),
-			"last_accessed_gateway_time": types.StringNull(),
-		}
-		plan.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"gateway_type":               types.StringValue(device.GatewayConfig.GatewayType),
-			"gateway_auth_method":        types.StringValue(device.GatewayConfig.GatewayAuthMethod),
-			"last_accessed_gateway_id":   types.StringValue(device.GatewayConfig.LastAccessedGatewayId),
-			"last_accessed_gateway_time": types.StringValue(device.GatewayConfig.LastAccessedGatewayTime),
-		}
-		plan.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, attributes)
+// expandDevice builds the API request body of the device model.
+func expandDevice(ctx context.Context, model deviceResourceModel) (*iot.Device, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	credentials := []*iot.DeviceCredential{}
+	var credentialsModel []DevicePublicKeyCertificateModel
+	diags.Append(model.Credentials.ElementsAs(ctx, &credentialsModel, false)...)
+	for _, v := range credentialsModel {
+		credentials = append(credentials, &iot.DeviceCredential{
+			ExpirationTime: v.ExpirationTime.ValueString(),
+			PublicKey: &iot.PublicKeyCredential{
+				Format: v.PublicKey.Format.ValueString(),
+				Key:    v.PublicKey.Key.ValueString(),
+			},
+		})
 	}
 
-	if plan.Config.IsNull() {
-		attributes := map[string]attr.Value{
-			"version":           types.Int64Null(),
-			"cloud_update_time": types.StringNull(),
-			"device_ack_time":   types.StringNull(),
-			"binary_data":       types.StringNull(),
-		}
-		plan.Config = types.ObjectValueMust(ConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"version":           types.Int64Value(device.Config.Version),
-			"cloud_update_time": types.StringValue(device.Config.CloudUpdateTime),
-			"device_ack_time":   types.StringValue(device.Config.DeviceAckTime),
-			"binary_data":       types.StringValue(device.Config.BinaryData),
-		}
-		plan.Config = types.ObjectValueMust(ConfigModelTypes, attributes)
+	var gatewayConfigModel GatewayConfigModel
+	if !model.GatewayConfig.IsNull() && !model.GatewayConfig.IsUnknown() {
+		diags.Append(model.GatewayConfig.As(ctx, &gatewayConfigModel, basetypes.ObjectAsOptions{})...)
 	}
 
-	attributes := map[string]attr.Value{}
-	for k, v := range device.Metadata {
-		s, _ := strconv.Unquote(v)
-		attributes[k] = types.StringValue(s)
+	metadata := make(map[string]string)
+	for k, v := range model.Metadata.Elements() {
+		metadata[k] = v.(types.String).ValueString()
 	}
-	plan.Metadata = types.MapValueMust(plan.Metadata.ElementType(ctx), attributes)
 
-	if plan.Credentials.IsNull() {
-		tflog.Debug(ctx, "value detected NULL - CREATE")
-		plan.Credentials = types.ListNull(plan.Credentials.ElementType(ctx))
+	return &iot.Device{
+		Id:          model.ID.ValueString(),
+		Credentials: credentials,
+		Blocked:     model.Blocked.ValueBool(),
+		LogLevel:    model.LogLevel.ValueString(),
+		Metadata:    metadata,
+		GatewayConfig: &iot.GatewayConfig{
+			GatewayAuthMethod: gatewayConfigModel.GatewayAuthMethod.ValueString(),
+			GatewayType:       gatewayConfigModel.GatewayType.ValueString(),
+		},
+	}, diags
+}
+
+// flattenDevice maps the device returned by the API onto model. Optional
+// attributes that are null in model stay null while the API reports their
+// default value, so that unset attributes do not show a diff.
+func (r *deviceResource) flattenDevice(ctx context.Context, device *iot.Device, model *deviceResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	model.Name = types.StringValue(device.Name)
+	model.NumID = types.StringValue(strconv.FormatUint(device.NumId, 10))
+	model.LastConfigAckTime = types.StringValue(device.LastConfigAckTime)
+	model.LastConfigSendTime = types.StringValue(device.LastConfigSendTime)
+	model.LastErrorTime = types.StringValue(device.LastErrorTime)
+	model.LastEventTime = types.StringValue(device.LastEventTime)
+	model.LastHeartbeatTime = types.StringValue(device.LastHeartbeatTime)
+	model.LastStateTime = types.StringValue(device.LastStateTime)
+
+	if !model.Blocked.IsNull() || device.Blocked {
+		model.Blocked = types.BoolValue(device.Blocked)
+	}
+	if !model.LogLevel.IsNull() || (device.LogLevel != "" && device.LogLevel != "NONE") {
+		model.LogLevel = types.StringValue(device.LogLevel)
+	}
+	if !model.Metadata.IsNull() || len(device.Metadata) > 0 {
+		model.Metadata = flattenDeviceMetadata(device.Metadata, model.Metadata)
 	}
 
-	if plan.Credentials.IsUnknown() {
-		var credentials []DeviceCredentialsModel
+	credentials, d := flattenDeviceCredentials(ctx, model.Credentials, device.Credentials)
+	diags.Append(d...)
+	model.Credentials = credentials
 
-		for _, credential := range device.Credentials {
-			m := DeviceCredentialsModel{
-				PublicKeyCertificate: DevicePublicKeyCertificateModel{
-					ExpirationTime: types.StringValue(credential.ExpirationTime),
-					PublicKey: PublicKeyModel{
-						Format: types.StringValue(credential.PublicKey.Format),
-						Key:    types.StringValue(credential.PublicKey.Key),
-					},
-				},
-			}
-			credentials = append(credentials, m)
-		}
-		plan.Credentials, _ = types.ListValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
-		// plan.Credentials, _ = types.SetValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
+	state := &iot.DeviceState{}
+	if device.State != nil {
+		state = device.State
+	}
+	model.State = types.ObjectValueMust(StateModelTypes, map[string]attr.Value{
+		"update_time": types.StringValue(state.UpdateTime),
+		"binary_data": types.StringValue(state.BinaryData),
+	})
+
+	lastErrorStatus := &iot.Status{}
+	if device.LastErrorStatus != nil {
+		lastErrorStatus = device.LastErrorStatus
+	}
+	model.LastErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, map[string]attr.Value{
+		"code":    types.Int64Value(lastErrorStatus.Code),
+		"message": types.StringValue(lastErrorStatus.Message),
+	})
 
+	config := &iot.DeviceConfig{}
+	if device.Config != nil {
+		config = device.Config
 	}
+	model.Config = types.ObjectValueMust(ConfigModelTypes, map[string]attr.Value{
+		"version":           types.Int64Value(config.Version),
+		"cloud_update_time": types.StringValue(config.CloudUpdateTime),
+		"device_ack_time":   types.StringValue(config.DeviceAckTime),
+		"binary_data":       types.StringValue(config.BinaryData),
+	})
 
-	if !plan.Credentials.IsUnknown() && !plan.Credentials.IsNull() {
-		var credentials []DeviceCredentialsModel
+	model.GatewayConfig = flattenGatewayConfig(model.GatewayConfig, device.GatewayConfig)
 
-		for _, credential := range device.Credentials {
-			m := DeviceCredentialsModel{
-				PublicKeyCertificate: DevicePublicKeyCertificateModel{
-					ExpirationTime: types.StringValue(credential.ExpirationTime),
-					PublicKey: PublicKeyModel{
-						Format: types.StringValue(credential.PublicKey.Format),
-						Key:    types.StringValue(credential.PublicKey.Key),
-					},
-				},
-			}
-			credentials = append(credentials, m)
+	if !r.tracksActivity(*model) {
+		clearDeviceActivity(model)
+	}
+	return diags
+}
+
+// flattenGatewayConfig maps the device gateway config, keeping it null when it
+// was not configured and the device is not a gateway.
+func flattenGatewayConfig(prior types.Object, config *iot.GatewayConfig) types.Object {
+	if config == nil {
+		config = &iot.GatewayConfig{}
+	}
+
+	priorType, priorAuthMethod := types.StringNull(), types.StringNull()
+	if prior.IsNull() || prior.IsUnknown() {
+		if config.GatewayType != "GATEWAY" && config.GatewayAuthMethod == "" {
+			return types.ObjectNull(GatewayConfigModelTypes)
+		}
+	} else {
+		attributes := prior.Attributes()
+		if v, ok := attributes["gateway_type"].(types.String); ok {
+			priorType = v
 		}
-		if credentials == nil {
-			tflog.Debug(ctx, "Known value detected NULL - Credentials - UPDATE")
-		} else {
-			plan.Credentials, _ = types.ListValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
-			// plan.Credentials, _ = types.SetValueFrom(ctx, plan.Credentials.ElementType(ctx), credentials)
+		if v, ok := attributes["gateway_auth_method"].(types.String); ok {
+			priorAuthMethod = v
 		}
+	}
 
+	gatewayType := types.StringValue(config.GatewayType)
+	if priorType.IsNull() && (config.GatewayType == "" || config.GatewayType == "NON_GATEWAY") {
+		gatewayType = types.StringNull()
 	}
 
-	// Set state to fully populated data
-	diags = resp.State.Set(ctx, plan)
-	resp.Diagnostics.Append(diags...)
-	if resp.Diagnostics.HasError() {
-		return
+	return types.ObjectValueMust(GatewayConfigModelTypes, map[string]attr.Value{
+		"gateway_type":               gatewayType,
+		"gateway_auth_method":        flattenOptionalString(priorAuthMethod, config.GatewayAuthMethod),
+		"last_accessed_gateway_id":   types.StringValue(config.LastAccessedGatewayId),
+		"last_accessed_gateway_time": types.StringValue(config.LastAccessedGatewayTime),
+	})
+}
+
+// flattenDeviceCredentials maps the device credentials, keeping the list null
+// when it was not configured and the device has none.
+func flattenDeviceCredentials(ctx context.Context, prior types.List, deviceCredentials []*iot.DeviceCredential) (types.List, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if prior.IsNull() && len(deviceCredentials) == 0 {
+		return types.ListNull(types.ObjectType{AttrTypes: DevicePublicKeyCertificateModelTypes}), diags
+	}
+
+	var priorCredentials []DevicePublicKeyCertificateModel
+	if !prior.IsNull() && !prior.IsUnknown() {
+		diags.Append(prior.ElementsAs(ctx, &priorCredentials, false)...)
+	}
+
+	credentials := []DevicePublicKeyCertificateModel{}
+	for i, credential := range deviceCredentials {
+		if credential.PublicKey == nil {
+			continue
+		}
+		// Credentials without an expiration report the Unix epoch.
+		expiration := types.StringValue(credential.ExpirationTime)
+		if expiry, err := time.Parse(time.RFC3339Nano, credential.ExpirationTime); credential.ExpirationTime == "" || (err == nil && expiry.Unix() == 0) {
+			if i >= len(priorCredentials) || priorCredentials[i].ExpirationTime.IsNull() {
+				expiration = types.StringNull()
+			}
+		}
+		credentials = append(credentials, DevicePublicKeyCertificateModel{
+			ExpirationTime: expiration,
+			PublicKey: PublicKeyModel{
+				Format: types.StringValue(credential.PublicKey.Format),
+				Key:    types.StringValue(credential.PublicKey.Key),
+			},
+		})
 	}
+
+	list, d := types.ListValueFrom(ctx, types.ObjectType{AttrTypes: DevicePublicKeyCertificateModelTypes}, credentials)
+	diags.Append(d...)
+	return list, diags
 }
 
 // Delete deletes the resource and removes the Terraform state on success.
@@ -952,15 +790,76 @@ func (r *deviceResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	// Delete existing device resource on ClearBlade IoT Core
+	resp.Diagnostics.Append(checkDeletionProtection(state.DeletionProtection, r.deletionProtection, "Device", state.Registry.ValueString()+"/"+state.ID.ValueString())...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString(), state.ID.ValueString())
-	_, err := r.client.Projects.Locations.Registries.Devices.Delete(parent).Do()
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error Deleting Clearblade IoT Core device",
-			"Could not delete device, unexpected error: "+err.Error(),
-		)
+	switch state.DeletionPolicy.ValueString() {
+	case "ABANDON":
+		tflog.Info(ctx, "abandoning device, which is kept in ClearBlade IoT Core", map[string]any{"device": parent})
 		return
+	case "BLOCK":
+		// Block the device rather than deleting it, so that it is kept for
+		// audit but can no longer connect.
+		// The credentials are removed by masking them without sending any.
+		body := &iot.Device{Blocked: true}
+		mask := "blocked"
+		if state.StripCredentials.ValueBool() {
+			mask += ",credentials"
+		}
+		err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+			_, err := r.client.Projects.Locations.Registries.Devices.Patch(parent, body).UpdateMask(mask).Context(ctx).Do()
+			return err
+		})
+		if err != nil && !isNotFoundError(err) {
+			resp.Diagnostics.AddError(
+				"Error Blocking Clearblade IoT Core device",
+				"Could not block device, unexpected error: "+err.Error(),
+			)
+			return
+		}
+		tflog.Info(ctx, "blocked device, which is kept in ClearBlade IoT Core", map[string]any{"device": parent, "strip_credentials": state.StripCredentials.ValueBool()})
+	default:
+		// Delete existing device resource on ClearBlade IoT Core
+		err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+			_, err := r.client.Projects.Locations.Registries.Devices.Delete(parent).Context(ctx).Do()
+			return err
+		})
+		if err != nil && !isNotFoundError(err) {
+			resp.Diagnostics.AddError(
+				"Error Deleting Clearblade IoT Core device",
+				"Could not delete device, unexpected error: "+err.Error(),
+			)
+			return
+		}
+	}
+	r.invalidateDeviceCache(strings.TrimSuffix(parent, "/devices/"+state.ID.ValueString()))
+}
+
+// getDevice gets the device id of the registry registryParent. With
+// batch_refresh enabled the device is served from the registry listing, and
+// only devices missing from the listing are requested individually.
+func (r *deviceResource) getDevice(ctx context.Context, registryParent, id string) (*iot.Device, error) {
+	if r.deviceCache != nil {
+		device, found, err := r.deviceCache.get(ctx, registryParent, id)
+		switch {
+		case err != nil:
+			tflog.Warn(ctx, "could not list devices for batch refresh, reading the device individually", map[string]any{"error": err.Error()})
+		case found:
+			return device, nil
+		}
+	}
+
+	return r.client.Projects.Locations.Registries.Devices.Get(registryParent + "/devices/" + id).Context(ctx).Do()
+}
+
+// invalidateDeviceCache drops the cached listing of the registry
+// registryParent after one of its devices changed.
+func (r *deviceResource) invalidateDeviceCache(registryParent string) {
+	if r.deviceCache != nil {
+		r.deviceCache.invalidate(registryParent)
 	}
 }
 
@@ -969,6 +868,115 @@ func (r *deviceResource) ImportState(ctx context.Context, req resource.ImportSta
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
 
+// googleDeviceState is the state of the Google provider's
+// google_cloudiot_device resource.
+type googleDeviceState struct {
+	Name     string            `json:"name"`
+	Registry string            `json:"registry"`
+	NumID    string            `json:"num_id"`
+	Blocked  bool              `json:"blocked"`
+	LogLevel string            `json:"log_level"`
+	Metadata map[string]string `json:"metadata"`
+	// Credentials holds at most one public key per credential.
+	Credentials []struct {
+		ExpirationTime string `json:"expiration_time"`
+		PublicKey      []struct {
+			Format string `json:"format"`
+			Key    string `json:"key"`
+		} `json:"public_key"`
+	} `json:"credentials"`
+	GatewayConfig []struct {
+		GatewayType       string `json:"gateway_type"`
+		GatewayAuthMethod string `json:"gateway_auth_method"`
+	} `json:"gateway_config"`
+}
+
+// MoveState moves google_cloudiot_device resources of the Google provider to
+// this resource. The activity, config and state attributes are left to the
+// refresh that follows the move.
+func (r *deviceResource) MoveState(ctx context.Context) []resource.StateMover {
+	return []resource.StateMover{{
+		StateMover: func(ctx context.Context, req resource.MoveStateRequest, resp *resource.MoveStateResponse) {
+			if !isGoogleSource(req, "google_cloudiot_device") {
+				return
+			}
+
+			var source googleDeviceState
+			resp.Diagnostics.Append(decodeSourceState(req, &source)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			project, region, registry := parseRegistryPath(source.Registry)
+			resp.Diagnostics.Append(checkMovedLocation(project, region)...)
+
+			target := deviceResourceModel{
+				ID:                 types.StringValue(source.Name),
+				Name:               types.StringValue(fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), registry, source.Name)),
+				NumID:              types.StringValue(source.NumID),
+				Credentials:        types.ListNull(types.ObjectType{AttrTypes: DevicePublicKeyCertificateModelTypes}),
+				LastHeartbeatTime:  types.StringNull(),
+				LastEventTime:      types.StringNull(),
+				LastStateTime:      types.StringNull(),
+				LastConfigAckTime:  types.StringNull(),
+				LastConfigSendTime: types.StringNull(),
+				Blocked:            types.BoolNull(),
+				LastErrorTime:      types.StringNull(),
+				LastErrorStatus:    types.ObjectNull(LastErrorStatusModelTypes),
+				Config:             types.ObjectNull(ConfigModelTypes),
+				State:              types.ObjectNull(StateModelTypes),
+				LogLevel:           types.StringNull(),
+				Metadata:           types.MapNull(types.StringType),
+				GatewayConfig:      types.ObjectNull(GatewayConfigModelTypes),
+				Registry:           types.StringValue(registry),
+				TrackActivity:      types.BoolNull(),
+				DeletionProtection: types.BoolNull(),
+				DeletionPolicy:     types.StringNull(),
+				StripCredentials:   types.BoolNull(),
+			}
+			// Unset optional attributes stay null, as after a create.
+			if source.Blocked {
+				target.Blocked = types.BoolValue(true)
+			}
+			if source.LogLevel != "" && source.LogLevel != "NONE" {
+				target.LogLevel = types.StringValue(source.LogLevel)
+			}
+			if len(source.Metadata) > 0 {
+				var diags diag.Diagnostics
+				target.Metadata, diags = types.MapValueFrom(ctx, types.StringType, source.Metadata)
+				resp.Diagnostics.Append(diags...)
+			}
+			if len(source.Credentials) > 0 {
+				credentials := []*iot.DeviceCredential{}
+				for _, credential := range source.Credentials {
+					for _, key := range credential.PublicKey {
+						credentials = append(credentials, &iot.DeviceCredential{
+							ExpirationTime: credential.ExpirationTime,
+							PublicKey:      &iot.PublicKeyCredential{Format: key.Format, Key: key.Key},
+						})
+					}
+				}
+				var diags diag.Diagnostics
+				target.Credentials, diags = flattenDeviceCredentials(ctx, target.Credentials, credentials)
+				resp.Diagnostics.Append(diags...)
+			}
+			if len(source.GatewayConfig) > 0 && source.GatewayConfig[0].GatewayType == "GATEWAY" {
+				target.GatewayConfig = types.ObjectValueMust(GatewayConfigModelTypes, map[string]attr.Value{
+					"gateway_type":               types.StringValue(source.GatewayConfig[0].GatewayType),
+					"gateway_auth_method":        types.StringValue(source.GatewayConfig[0].GatewayAuthMethod),
+					"last_accessed_gateway_id":   types.StringNull(),
+					"last_accessed_gateway_time": types.StringNull(),
+				})
+			}
+			if resp.Diagnostics.HasError() {
+				return
+			}
+
+			tflog.Debug(ctx, "moving device state", map[string]any{"from": req.SourceTypeName, "device": source.Registry + "/devices/" + source.Name})
+			resp.Diagnostics.Append(resp.TargetState.Set(ctx, target)...)
+		},
+	}}
+}
+
 // Metadata returns the data source type name.
 func (r *deviceResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_iot_device"
@@ -981,14 +989,17 @@ func (r *deviceResource) Configure(ctx context.Context, req resource.ConfigureRe
 		return
 	}
 
-	client, ok := req.ProviderData.(*iot.Service)
+	data, ok := req.ProviderData.(*providerData)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *iot.Service, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *clearblade.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	r.client = client
+	r.client = data.client
+	r.deviceCache = data.deviceCache
+	r.trackActivity = data.trackActivity
+	r.deletionProtection = data.deletionProtection
 }
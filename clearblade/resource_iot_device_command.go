@@ -0,0 +1,277 @@
+package clearblade
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/objectplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"google.golang.org/api/googleapi"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource              = &deviceCommandResource{}
+	_ resource.ResourceWithConfigure = &deviceCommandResource{}
+)
+
+func NewDeviceCommandResource() resource.Resource {
+	return &deviceCommandResource{}
+}
+
+// deviceCommandResource is the resource implementation.
+type deviceCommandResource struct {
+	client *iot.Service
+}
+
+type deviceCommandResourceModel struct {
+	ID             types.String `tfsdk:"id"`
+	Registry       types.String `tfsdk:"registry"`
+	DeviceID       types.String `tfsdk:"device_id"`
+	BinaryData     types.String `tfsdk:"binary_data"`
+	DataText       types.String `tfsdk:"data_text"`
+	Subfolder      types.String `tfsdk:"subfolder"`
+	Triggers       types.Map    `tfsdk:"triggers"`
+	OnDisconnected types.String `tfsdk:"on_disconnected"`
+	SendTime       types.String `tfsdk:"send_time"`
+	ErrorStatus    types.Object `tfsdk:"error_status"`
+}
+
+// Schema defines the schema for the resource.
+func (r *deviceCommandResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Sends a command to a device once, when the resource is created. " +
+			"The command is only sent again when the resource is replaced, for example because `triggers` changed. " +
+			"Changing the payload alone does not re-send the command.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "An identifier for this command send.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"registry": schema.StringAttribute{
+				Description: "The name of the device registry the device belongs to.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"device_id": schema.StringAttribute{
+				Description: "The user-defined device identifier or the numeric ID of the device.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"binary_data": schema.StringAttribute{
+				Description: "The command data to send to the device, base64 encoded. Exactly one of binary_data or data_text must be set.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("data_text")),
+				},
+			},
+			"data_text": schema.StringAttribute{
+				Description: "The command data to send to the device as plain text. It is base64 encoded before being sent.",
+				Optional:    true,
+			},
+			"subfolder": schema.StringAttribute{
+				Description: "Optional subfolder for the command. If empty, the command will be delivered to the /devices/{device-id}/commands topic, otherwise it will be delivered to the /devices/{device-id}/commands/{subfolder} topic.",
+				Optional:    true,
+			},
+			"triggers": schema.MapAttribute{
+				ElementType: types.StringType,
+				Description: "Arbitrary map of values that, when changed, will cause the command to be sent again.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"on_disconnected": schema.StringAttribute{
+				MarkdownDescription: "How to report a failure because the device is not connected. " +
+					"`ERROR` fails the apply, `WARNING` records the failure in `error_status` and continues. Defaults to `ERROR`.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("ERROR"),
+				Validators: []validator.String{
+					stringvalidator.OneOf(
+						"ERROR",
+						"WARNING",
+					),
+				},
+			},
+			"send_time": schema.StringAttribute{
+				Computed:    true,
+				Description: "The time at which the command was sent.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"error_status": schema.SingleNestedAttribute{
+				Computed:    true,
+				Description: "The error returned when the command could not be delivered, if any.",
+				PlanModifiers: []planmodifier.Object{
+					objectplanmodifier.UseStateForUnknown(),
+				},
+				Attributes: map[string]schema.Attribute{
+					"code": schema.Int64Attribute{
+						Computed:    true,
+						Description: "The HTTP status code returned by ClearBlade IoT Core.",
+					},
+					"message": schema.StringAttribute{
+						Computed:    true,
+						Description: "The error message returned by ClearBlade IoT Core.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// Create sends the command and records the outcome in the Terraform state.
+func (r *deviceCommandResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	tflog.Debug(ctx, "Sending a command to an iot device")
+
+	var plan deviceCommandResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	binaryData := plan.BinaryData.ValueString()
+	if plan.BinaryData.IsNull() {
+		binaryData = base64.StdEncoding.EncodeToString([]byte(plan.DataText.ValueString()))
+	}
+
+	name := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), plan.Registry.ValueString(), plan.DeviceID.ValueString())
+	sendTime := time.Now().UTC()
+	_, err := r.client.Projects.Locations.Registries.Devices.SendCommandToDevice(name, &iot.SendCommandToDeviceRequest{
+		BinaryData: binaryData,
+		Subfolder:  plan.Subfolder.ValueString(),
+	}).Context(ctx).Do()
+
+	plan.ID = types.StringValue(fmt.Sprintf("%s/%s/%d", plan.Registry.ValueString(), plan.DeviceID.ValueString(), sendTime.UnixNano()))
+	plan.SendTime = types.StringValue(sendTime.Format(time.RFC3339))
+	plan.ErrorStatus = types.ObjectNull(LastErrorStatusModelTypes)
+
+	if err != nil {
+		if !isDeviceNotConnectedError(err) || plan.OnDisconnected.ValueString() != "WARNING" {
+			resp.Diagnostics.AddError(
+				"Error sending a command to a device",
+				"Could not send command to device "+plan.DeviceID.ValueString()+", unexpected error: "+err.Error(),
+			)
+			return
+		}
+
+		resp.Diagnostics.AddWarning(
+			"Device is not connected",
+			"The command was not delivered to device "+plan.DeviceID.ValueString()+" because it is not connected: "+err.Error(),
+		)
+
+		var gerr *googleapi.Error
+		errors.As(err, &gerr)
+		plan.ErrorStatus = types.ObjectValueMust(LastErrorStatusModelTypes, map[string]attr.Value{
+			"code":    types.Int64Value(int64(gerr.Code)),
+			"message": types.StringValue(gerr.Message),
+		})
+	}
+
+	tflog.Debug(ctx, "command sent")
+
+	// Set state to fully populated data
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Read keeps the recorded outcome; a sent command has nothing to refresh.
+func (r *deviceCommandResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	tflog.Debug(ctx, "Reading the device command resource")
+}
+
+// Update stores changes to attributes that do not re-send the command.
+func (r *deviceCommandResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	tflog.Debug(ctx, "Updating the device command resource")
+
+	var plan, state deviceCommandResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The command is not sent again, so its recorded outcome is kept.
+	plan.ErrorStatus = state.ErrorStatus
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Delete removes the resource from the Terraform state; a sent command cannot be recalled.
+func (r *deviceCommandResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	tflog.Debug(ctx, "Removing the device command resource from state")
+}
+
+// Metadata returns the resource type name.
+func (r *deviceCommandResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_device_command"
+}
+
+// Configure adds the provider configured client to the resource.
+func (r *deviceCommandResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*providerData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *clearblade.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	r.client = data.client
+}
+
+// isDeviceNotConnectedError reports whether err is the FAILED_PRECONDITION
+// error returned when a command is sent to a device that is not connected.
+func isDeviceNotConnectedError(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	if gerr.Code != http.StatusBadRequest && gerr.Code != http.StatusPreconditionFailed {
+		return false
+	}
+	return strings.Contains(strings.ToLower(gerr.Message+" "+gerr.Body), "not connected")
+}
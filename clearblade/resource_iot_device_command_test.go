@@ -0,0 +1,137 @@
+package clearblade
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"google.golang.org/api/googleapi"
+)
+
+func testAccDeviceCommandConfig(server *clearbladetest.Server, body string) string {
+	return testAccDeviceConfig(server, "") + `
+resource "clearblade_iot_device_command" "test" {
+  registry  = clearblade_iot_registry.test.id
+  device_id = clearblade_iot_device.test.id
+` + body + `
+}
+`
+}
+
+func TestAccDeviceCommandResource(t *testing.T) {
+	server := newTestAccServer(t)
+	device := testAccLocation + "/registries/test-registry/devices/test-device"
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeviceCommandConfig(server, `
+  data_text = "reboot"
+  subfolder = "control"
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("clearblade_iot_device_command.test", "send_time"),
+					resource.TestCheckNoResourceAttr("clearblade_iot_device_command.test", "error_status"),
+					testAccCheckCommands(server, device, "reboot"),
+				),
+			},
+			// Changing the payload alone does not send the command again.
+			{
+				Config: testAccDeviceCommandConfig(server, `
+  binary_data = "`+base64.StdEncoding.EncodeToString([]byte("shutdown"))+`"
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("clearblade_iot_device_command.test", "error_status"),
+					testAccCheckCommands(server, device, "reboot"),
+				),
+			},
+			// Changing the triggers does.
+			{
+				Config: testAccDeviceCommandConfig(server, `
+  binary_data = "`+base64.StdEncoding.EncodeToString([]byte("shutdown"))+`"
+
+  triggers = {
+    generation = "2"
+  }
+`),
+				Check: testAccCheckCommands(server, device, "reboot", "shutdown"),
+			},
+		},
+	})
+}
+
+func TestAccDeviceCommandResource_notConnected(t *testing.T) {
+	server := newTestAccServer(t)
+	server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.sendCommandToDevice", Fault: clearbladetest.NotConnected})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeviceCommandConfig(server, `
+  data_text = "reboot"
+`),
+				ExpectError: regexp.MustCompile(`Could not send command to device test-device`),
+			},
+			{
+				Config: testAccDeviceCommandConfig(server, `
+  data_text       = "reboot"
+  on_disconnected = "WARNING"
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device_command.test", "error_status.code", "400"),
+					resource.TestCheckResourceAttr("clearblade_iot_device_command.test", "error_status.message", "Device is not connected (injected fault)."),
+					resource.TestCheckResourceAttrSet("clearblade_iot_device_command.test", "send_time"),
+				),
+			},
+		},
+	})
+}
+
+func TestIsDeviceNotConnectedError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", &googleapi.Error{Code: http.StatusBadRequest, Message: "Device is not connected."}, true},
+		{"precondition failed", &googleapi.Error{Code: http.StatusPreconditionFailed, Body: `{"error": {"message": "Device Not Connected"}}`}, true},
+		{"wrapped", fmt.Errorf("sending: %w", &googleapi.Error{Code: http.StatusBadRequest, Message: "not connected"}), true},
+		{"other bad request", &googleapi.Error{Code: http.StatusBadRequest, Message: "binaryData is required."}, false},
+		{"other status", &googleapi.Error{Code: http.StatusNotFound, Message: "Device is not connected."}, false},
+		{"not an API error", errors.New("device is not connected"), false},
+	} {
+		if got := isDeviceNotConnectedError(tc.err); got != tc.want {
+			t.Errorf("%s: isDeviceNotConnectedError = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+// testAccCheckCommands checks the payloads of the commands sent to a device
+// of the fake, in the order they were sent.
+func testAccCheckCommands(server *clearbladetest.Server, name string, want ...string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		commands := server.Commands(name)
+		if len(commands) != len(want) {
+			return fmt.Errorf("device %s received %d commands, want %d", name, len(commands), len(want))
+		}
+		for i, command := range commands {
+			data, err := base64.StdEncoding.DecodeString(command.BinaryData)
+			if err != nil {
+				return fmt.Errorf("command %d: %s", i, err)
+			}
+			if string(data) != want[i] {
+				return fmt.Errorf("command %d is %q, want %q", i, data, want[i])
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,508 @@
+package clearblade
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+const testAccDeviceKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEtestpublickeydata
+-----END PUBLIC KEY-----
+`
+
+func testAccDeviceConfig(server *clearbladetest.Server, body string) string {
+	return testAccProviderConfig(server) + `
+resource "clearblade_iot_registry" "test" {
+  id = "test-registry"
+}
+
+resource "clearblade_iot_device" "test" {
+  id       = "test-device"
+  registry = clearblade_iot_registry.test.id
+` + body + `
+}
+`
+}
+
+func TestAccDeviceResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDeviceDestroy(server),
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: testAccDeviceConfig(server, `
+  log_level = "INFO"
+
+  metadata = {
+    site = "plant-1"
+  }
+
+  credentials = [
+    {
+      public_key = {
+        format = "ES256_PEM"
+        key    = <<-EOT
+`+testAccDeviceKey+`EOT
+      }
+    },
+  ]
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "name", testAccLocation+"/registries/test-registry/devices/test-device"),
+					resource.TestCheckResourceAttrSet("clearblade_iot_device.test", "num_id"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "INFO"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "metadata.site", "plant-1"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "credentials.#", "1"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "credentials.0.public_key.format", "ES256_PEM"),
+					testAccCheckDevice(server, "test-device", func(device deviceSnapshot) error {
+						if device.metadata["site"] != "plant-1" || device.credentials != 1 {
+							return fmt.Errorf("stored device has metadata %v and %d credentials", device.metadata, device.credentials)
+						}
+						return nil
+					}),
+				),
+			},
+			// ImportState testing
+			{
+				ResourceName:      "clearblade_iot_device.test",
+				ImportState:       true,
+				ImportStateId:     "test-registry/test-device",
+				ImportStateVerify: true,
+			},
+			// Update and Read testing
+			{
+				Config: testAccDeviceConfig(server, `
+  log_level = "DEBUG"
+  blocked   = true
+
+  metadata = {
+    site  = "plant-2"
+    owner = "ops"
+  }
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "DEBUG"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "blocked", "true"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "metadata.%", "2"),
+					resource.TestCheckNoResourceAttr("clearblade_iot_device.test", "credentials"),
+					testAccCheckDevice(server, "test-device", func(device deviceSnapshot) error {
+						if !device.blocked || device.credentials != 0 {
+							return fmt.Errorf("stored device is blocked=%t with %d credentials", device.blocked, device.credentials)
+						}
+						return nil
+					}),
+				),
+			},
+			// Delete testing automatically occurs in TestCase
+		},
+	})
+}
+
+func TestAccDeviceResource_gateway(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDeviceDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeviceConfig(server, `
+  gateway_config = {
+    gateway_type        = "GATEWAY"
+    gateway_auth_method = "ASSOCIATION_ONLY"
+  }
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "gateway_config.gateway_type", "GATEWAY"),
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "gateway_config.gateway_auth_method", "ASSOCIATION_ONLY"),
+				),
+			},
+			{
+				Config: testAccDeviceConfig(server, `
+  gateway_config = {
+    gateway_type        = "GATEWAY"
+    gateway_auth_method = "DEVICE_AUTH_TOKEN_ONLY"
+  }
+`),
+				Check: resource.TestCheckResourceAttr("clearblade_iot_device.test", "gateway_config.gateway_auth_method", "DEVICE_AUTH_TOKEN_ONLY"),
+			},
+		},
+	})
+}
+
+func TestAccDeviceResource_disappears(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDeviceDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeviceConfig(server, ""),
+				Check: func(*terraform.State) error {
+					server.DeleteDevice(testAccLocation + "/registries/test-registry/devices/test-device")
+					return nil
+				},
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccDeviceResource_trackActivity(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeviceConfig(server, `
+  track_activity = false
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("clearblade_iot_device.test", "last_heartbeat_time"),
+					resource.TestCheckNoResourceAttr("clearblade_iot_device.test", "state"),
+				),
+			},
+		},
+	})
+}
+
+// TestAccDeviceResource_deletionProtection covers the provider default of
+// deletion_protection, which protects devices that do not set it.
+func TestAccDeviceResource_deletionProtection(t *testing.T) {
+	server := newTestAccServer(t)
+	config := func(body string) string {
+		return testAccProviderConfigWith(server, "  deletion_protection = true\n") + `
+resource "clearblade_iot_registry" "test" {
+  id                  = "test-registry"
+  deletion_protection = false
+}
+
+resource "clearblade_iot_device" "test" {
+  id       = "test-device"
+  registry = clearblade_iot_registry.test.id
+` + body + `
+}
+`
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDeviceDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: config(""),
+				Check:  resource.TestCheckNoResourceAttr("clearblade_iot_device.test", "deletion_protection"),
+			},
+			{
+				Config:      config(""),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(`protected by the\s+deletion_protection setting of the provider`),
+			},
+			{
+				Config: config(`
+  deletion_protection = false
+`),
+				Check: testAccCheckDeviceExists(server, "test-device"),
+			},
+		},
+	})
+}
+
+// TestAccDeviceResource_deletionPolicy destroys devices whose deletion
+// policy keeps them in ClearBlade IoT Core. The registry is not managed, so
+// that it does not need to be empty when the test ends.
+func TestAccDeviceResource_deletionPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		body  string
+		check func(deviceSnapshot) error
+	}{
+		{
+			name: "block",
+			body: `
+  deletion_policy = "BLOCK"
+`,
+			check: func(device deviceSnapshot) error {
+				if !device.blocked || device.credentials != 1 {
+					return fmt.Errorf("kept device is blocked=%t with %d credentials, want blocked with 1", device.blocked, device.credentials)
+				}
+				return nil
+			},
+		},
+		{
+			name: "block and strip credentials",
+			body: `
+  deletion_policy            = "BLOCK"
+  strip_credentials_on_block = true
+`,
+			check: func(device deviceSnapshot) error {
+				if !device.blocked || device.credentials != 0 || device.metadata["site"] != "plant-1" {
+					return fmt.Errorf("kept device is blocked=%t with %d credentials and metadata %v, want blocked without credentials", device.blocked, device.credentials, device.metadata)
+				}
+				return nil
+			},
+		},
+		{
+			name: "abandon",
+			body: `
+  deletion_policy = "ABANDON"
+`,
+			check: func(device deviceSnapshot) error {
+				if device.blocked || device.credentials != 1 {
+					return fmt.Errorf("kept device is blocked=%t with %d credentials, want it unchanged", device.blocked, device.credentials)
+				}
+				return nil
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestAccServer(t)
+			server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+
+			resource.Test(t, resource.TestCase{
+				ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+				CheckDestroy:             testAccCheckDevice(server, "test-device", tc.check),
+				Steps: []resource.TestStep{
+					{
+						Config: testAccProviderConfig(server) + `
+resource "clearblade_iot_device" "test" {
+  id       = "test-device"
+  registry = "test-registry"
+
+  metadata = {
+    site = "plant-1"
+  }
+
+  credentials = [
+    {
+      public_key = {
+        format = "ES256_PEM"
+        key    = <<-EOT
+` + testAccDeviceKey + `EOT
+      }
+    },
+  ]
+` + tc.body + `
+}
+`,
+						Check: resource.TestCheckResourceAttr("clearblade_iot_device.test", "credentials.#", "1"),
+					},
+				},
+			})
+		})
+	}
+}
+
+// deviceSnapshot holds the stored device fields the tests check.
+type deviceSnapshot struct {
+	blocked     bool
+	metadata    map[string]string
+	credentials int
+}
+
+func testAccCheckDevice(server *clearbladetest.Server, id string, check func(deviceSnapshot) error) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		device, ok := server.Device(testAccLocation + "/registries/test-registry/devices/" + id)
+		if !ok {
+			return fmt.Errorf("device %s not found", id)
+		}
+		return check(deviceSnapshot{blocked: device.Blocked, metadata: device.Metadata, credentials: len(device.Credentials)})
+	}
+}
+
+func testAccCheckDeviceExists(server *clearbladetest.Server, id string) resource.TestCheckFunc {
+	return testAccCheckDevice(server, id, func(deviceSnapshot) error { return nil })
+}
+
+func testAccCheckDeviceDestroy(server *clearbladetest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "clearblade_iot_device" {
+				continue
+			}
+			name := testAccLocation + "/registries/" + rs.Primary.Attributes["registry"] + "/devices/" + rs.Primary.Attributes["id"]
+			if _, ok := server.Device(name); ok {
+				return fmt.Errorf("device %s still exists", name)
+			}
+		}
+		return nil
+	}
+}
+
+func TestAccDeviceResource_faults(t *testing.T) {
+	server := newTestAccServer(t)
+	config := testAccDeviceConfig(server, `
+  log_level = "INFO"
+`)
+	updated := testAccDeviceConfig(server, `
+  log_level = "DEBUG"
+`)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDeviceDestroy(server),
+		Steps: []resource.TestStep{
+			// An API that stays unavailable fails the create once the
+			// retries are spent, without storing the device in state.
+			{
+				PreConfig: func() {
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.create", Fault: clearbladetest.Unavailable})
+				},
+				Config:      config,
+				ExpectError: regexp.MustCompile(`Error\s+503`),
+			},
+			// Throttled and unavailable creates are retried until the device
+			// is created.
+			{
+				PreConfig: func() {
+					if got := server.RequestCount("registries.devices.create"); got != apiRetryPolicy.attempts {
+						t.Errorf("the failed create made %d requests, want %d", got, apiRetryPolicy.attempts)
+					}
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.create", Count: 1, Fault: clearbladetest.TooManyRequests})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.create", Count: 1, Fault: clearbladetest.Unavailable})
+				},
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "INFO"),
+					testAccCheckRequestCount(server, "registries.devices.create", 3),
+				),
+			},
+			// An update over a reset connection fails and keeps the prior
+			// state.
+			{
+				PreConfig: func() {
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.patch", Count: 1, Fault: clearbladetest.ConnectionReset})
+				},
+				Config:      updated,
+				ExpectError: regexp.MustCompile(`reset|EOF`),
+			},
+			// Throttled and unavailable updates are retried.
+			{
+				PreConfig: func() {
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.patch", Count: 1, Fault: clearbladetest.TooManyRequests})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.patch", Count: 1, Fault: clearbladetest.Unavailable})
+				},
+				Config: updated,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "DEBUG"),
+					testAccCheckRequestCount(server, "registries.devices.patch", 3),
+				),
+			},
+			// Slow and throttled reads of the device are retried and still
+			// refresh it.
+			{
+				PreConfig: func() {
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.get", Path: "/devices/test-device$", Count: 2, Fault: clearbladetest.TooManyRequests})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.get", Fault: clearbladetest.Slow, Delay: 100 * time.Millisecond})
+				},
+				Config: updated,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_device.test", "log_level", "DEBUG"),
+					func(*terraform.State) error {
+						// Both throttled reads and the retry that succeeded.
+						if got := server.RequestCount("registries.devices.get"); got < 3 {
+							return fmt.Errorf("%d device reads, want at least 3", got)
+						}
+						return nil
+					},
+				),
+			},
+			// A delete that stays throttled fails once the retries are spent
+			// and leaves the device in state.
+			{
+				PreConfig: func() {
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.delete", Fault: clearbladetest.TooManyRequests})
+				},
+				Config:      updated,
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(`Error\s+429`),
+			},
+			// The final destroy retries an unavailable delete.
+			{
+				PreConfig: func() {
+					if got := server.RequestCount("registries.devices.delete"); got != apiRetryPolicy.attempts {
+						t.Errorf("the failed delete made %d requests, want %d", got, apiRetryPolicy.attempts)
+					}
+					server.ClearFaults()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.delete", Count: 1, Fault: clearbladetest.Unavailable})
+				},
+				Config:   updated,
+				PlanOnly: true,
+			},
+		},
+	})
+
+	if got := server.FaultCount(); got != 1 {
+		t.Errorf("%d delete faults were injected, want 1", got)
+	}
+}
+
+// TestAccDeviceResource_notFoundAfterCreate covers the API's eventual
+// consistency, where a device may not be found right after it was created,
+// and reads that fail transiently.
+func TestAccDeviceResource_notFoundAfterCreate(t *testing.T) {
+	for name, fault := range map[string]clearbladetest.Fault{
+		"not found":         clearbladetest.NotFound,
+		"too many requests": clearbladetest.TooManyRequests,
+		"unavailable":       clearbladetest.Unavailable,
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := newTestAccServer(t)
+
+			resource.Test(t, resource.TestCase{
+				ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+				CheckDestroy:             testAccCheckDeviceDestroy(server),
+				Steps: []resource.TestStep{
+					// The refresh after the create retries the reads that fail
+					// and keeps the device, so that there is nothing to change.
+					{
+						PreConfig: func() {
+							server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.get", Count: 2, Fault: fault})
+						},
+						Config: testAccDeviceConfig(server, ""),
+						Check:  testAccCheckDeviceExists(server, "test-device"),
+					},
+					{
+						Config: testAccDeviceConfig(server, ""),
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttrSet("clearblade_iot_device.test", "num_id"),
+							testAccCheckFaultCount(server, 2),
+						),
+					},
+				},
+			})
+		})
+	}
+}
+
+// testAccCheckFaultCount checks the number of faults the fake injected since
+// its rules were last cleared.
+func testAccCheckFaultCount(server *clearbladetest.Server, want int) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if got := server.FaultCount(); got != want {
+			return fmt.Errorf("%d faults were injected, want %d", got, want)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,627 @@
+package clearblade
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource                = &devicesResource{}
+	_ resource.ResourceWithConfigure   = &devicesResource{}
+	_ resource.ResourceWithImportState = &devicesResource{}
+)
+
+// bulkDeviceFieldMask limits the refresh listing to the managed fields.
+const bulkDeviceFieldMask = "blocked,credentials,log_level,metadata"
+
+func NewDevicesResource() resource.Resource {
+	return &devicesResource{}
+}
+
+// devicesResource manages many devices of one registry as a single resource.
+type devicesResource struct {
+	client *iot.Service
+}
+
+type devicesResourceModel struct {
+	ID          types.String `tfsdk:"id"`
+	Registry    types.String `tfsdk:"registry"`
+	Concurrency types.Int64  `tfsdk:"concurrency"`
+	Devices     types.Map    `tfsdk:"devices"`
+}
+
+// bulkDeviceModel maps a single device of the devices map.
+type bulkDeviceModel struct {
+	NumID       types.String `tfsdk:"num_id"`
+	Credentials types.List   `tfsdk:"credentials"`
+	Metadata    types.Map    `tfsdk:"metadata"`
+	LogLevel    types.String `tfsdk:"log_level"`
+	Blocked     types.Bool   `tfsdk:"blocked"`
+}
+
+var bulkDeviceModelTypes = map[string]attr.Type{
+	"num_id":      types.StringType,
+	"credentials": types.ListType{ElemType: types.ObjectType{AttrTypes: DevicePublicKeyCertificateModelTypes}},
+	"metadata":    types.MapType{ElemType: types.StringType},
+	"log_level":   types.StringType,
+	"blocked":     types.BoolType,
+}
+
+// Schema defines the schema for the resource.
+func (r *devicesResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Manages many devices of one device registry as a single resource. " +
+			"The devices are refreshed with a single paginated listing of the registry and created, updated and deleted in parallel. " +
+			"Devices of the registry that are not in `devices` are left alone.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The name of the device registry.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"registry": schema.StringAttribute{
+				Description: "The name of the device registry the devices belong to.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"concurrency": schema.Int64Attribute{
+				Description: fmt.Sprintf("The maximum number of devices created, updated or deleted in parallel. Defaults to %d.", defaultConcurrency),
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 64),
+				},
+			},
+			"devices": schema.MapNestedAttribute{
+				Description: "The devices to manage, keyed by the user-defined device identifier.",
+				Required:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"num_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "A server-defined unique numeric ID for the device.",
+							PlanModifiers: []planmodifier.String{
+								stringplanmodifier.UseStateForUnknown(),
+							},
+						},
+						"credentials": schema.ListNestedAttribute{
+							Optional:    true,
+							Description: "The credentials used to authenticate this device.",
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"expiration_time": schema.StringAttribute{
+										Optional:    true,
+										Description: "The time at which this credential becomes invalid.",
+									},
+									"public_key": schema.SingleNestedAttribute{
+										Required:            true,
+										MarkdownDescription: "A public key used to verify the signature of JSON Web Tokens (JWTs).",
+										Attributes: map[string]schema.Attribute{
+											"format": schema.StringAttribute{
+												Required: true,
+												Validators: []validator.String{
+													stringvalidator.OneOf(
+														"RSA_PEM",
+														"RSA_X509_PEM",
+														"ES256_PEM",
+														"ES256_X509_PEM",
+													),
+												},
+												Description: `The format of the key. Possible values: ["RSA_PEM", "RSA_X509_PEM", "ES256_PEM", "ES256_X509_PEM"]`,
+											},
+											"key": schema.StringAttribute{
+												Required:    true,
+												Description: "The key data.",
+											},
+										},
+									},
+								},
+							},
+						},
+						"metadata": schema.MapAttribute{
+							ElementType: types.StringType,
+							Optional:    true,
+							Description: "The metadata key-value pairs assigned to the device.",
+						},
+						"log_level": schema.StringAttribute{
+							Optional: true,
+							Validators: []validator.String{
+								stringvalidator.OneOf(
+									"NONE",
+									"ERROR",
+									"INFO",
+									"DEBUG",
+								),
+							},
+							Description: `The logging verbosity for device activity. Possible values: ["NONE", "ERROR", "INFO", "DEBUG"]`,
+						},
+						"blocked": schema.BoolAttribute{
+							Optional:    true,
+							Description: "If a device is blocked, connections or requests from this device will fail.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// bulkDeviceOpKind is the change applied to a single device.
+type bulkDeviceOpKind int
+
+const (
+	bulkDeviceCreate bulkDeviceOpKind = iota
+	bulkDevicePatch
+	bulkDeviceDelete
+)
+
+func (k bulkDeviceOpKind) String() string {
+	switch k {
+	case bulkDeviceCreate:
+		return "create"
+	case bulkDevicePatch:
+		return "update"
+	default:
+		return "delete"
+	}
+}
+
+// bulkDeviceOp is a change to a single device and, once applied, its outcome.
+type bulkDeviceOp struct {
+	kind  bulkDeviceOpKind
+	id    string
+	model bulkDeviceModel
+
+	numID string
+	err   error
+}
+
+// Create creates the devices and sets the initial Terraform state. Devices
+// that could not be created are reported as warnings rather than errors, so
+// that the resource is not tainted and replaced, which would delete the
+// devices that were created. They are kept in the state without a num_id, as
+// Terraform requires the planned devices, and the refresh drops them since
+// they do not exist, so the next plan creates only them.
+func (r *devicesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	tflog.Debug(ctx, "Creating iot devices resource")
+
+	var plan devicesResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	planned := map[string]bulkDeviceModel{}
+	resp.Diagnostics.Append(plan.Devices.ElementsAs(ctx, &planned, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ops := []*bulkDeviceOp{}
+	for _, id := range sortedDeviceIDs(planned) {
+		ops = append(ops, &bulkDeviceOp{kind: bulkDeviceCreate, id: id, model: planned[id]})
+	}
+
+	applied := map[string]bulkDeviceModel{}
+	resp.Diagnostics.Append(r.apply(ctx, plan, ops, applied, diag.SeverityWarning)...)
+	for _, op := range ops {
+		if op.err != nil {
+			op.model.NumID = types.StringNull()
+			applied[op.id] = op.model
+		}
+	}
+
+	plan.ID = plan.Registry
+	devices, diags := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: bulkDeviceModelTypes}, applied)
+	resp.Diagnostics.Append(diags...)
+	plan.Devices = devices
+
+	// Set state to the devices that were created, even when some failed
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+// Read refreshes the Terraform state from a single paginated listing of the
+// registry.
+func (r *devicesResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	tflog.Debug(ctx, "Reading the devices resource")
+
+	var state devicesResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Imported resources have no devices yet and adopt every device of the
+	// registry.
+	importing := state.Devices.IsNull()
+	prior := map[string]bulkDeviceModel{}
+	if !importing {
+		resp.Diagnostics.Append(state.Devices.ElementsAs(ctx, &prior, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.Registry.ValueString())
+	devices, err := listDevices(ctx, r.client, parent, deviceListOptions{FieldMask: bulkDeviceFieldMask})
+	if isNotFoundError(err) {
+		tflog.Warn(ctx, "device registry not found, removing the devices from state", map[string]any{"registry": parent})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading ClearBlade IoT Core devices",
+			"Could not list the devices of registry "+state.Registry.ValueString()+": "+err.Error(),
+		)
+		return
+	}
+
+	refreshed := map[string]bulkDeviceModel{}
+	for _, device := range devices {
+		model, managed := prior[device.Id]
+		if !managed && !importing {
+			continue
+		}
+		var priorModel *bulkDeviceModel
+		if managed {
+			priorModel = &model
+		}
+		flattened, diags := flattenBulkDevice(ctx, device, priorModel)
+		resp.Diagnostics.Append(diags...)
+		refreshed[device.Id] = flattened
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if removed := len(prior) - len(refreshed); !importing && removed > 0 {
+		tflog.Info(ctx, "devices no longer exist and were removed from state", map[string]any{"count": removed})
+	}
+
+	devicesValue, diags := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: bulkDeviceModelTypes}, refreshed)
+	resp.Diagnostics.Append(diags...)
+	state.Devices = devicesValue
+	state.ID = state.Registry
+
+	// Set refreshed state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// Update creates, updates and deletes devices to match the plan. Changes that
+// fail are reported and the previous state of those devices is kept.
+func (r *devicesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	tflog.Debug(ctx, "Updating iot devices resource")
+
+	var plan, state devicesResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	planned := map[string]bulkDeviceModel{}
+	current := map[string]bulkDeviceModel{}
+	resp.Diagnostics.Append(plan.Devices.ElementsAs(ctx, &planned, false)...)
+	resp.Diagnostics.Append(state.Devices.ElementsAs(ctx, &current, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ops := []*bulkDeviceOp{}
+	for _, id := range sortedDeviceIDs(current) {
+		if _, ok := planned[id]; !ok {
+			ops = append(ops, &bulkDeviceOp{kind: bulkDeviceDelete, id: id, model: current[id]})
+		}
+	}
+	for _, id := range sortedDeviceIDs(planned) {
+		model := planned[id]
+		existing, ok := current[id]
+		switch {
+		// Devices whose creation failed have no num_id until a refresh
+		// drops them.
+		case !ok, existing.NumID.IsNull():
+			ops = append(ops, &bulkDeviceOp{kind: bulkDeviceCreate, id: id, model: model})
+		case !bulkDeviceEqual(existing, model):
+			model.NumID = existing.NumID
+			ops = append(ops, &bulkDeviceOp{kind: bulkDevicePatch, id: id, model: model})
+		}
+	}
+
+	// Start from the current state so that failed changes keep it.
+	applied := map[string]bulkDeviceModel{}
+	for id, model := range current {
+		applied[id] = model
+	}
+	resp.Diagnostics.Append(r.apply(ctx, plan, ops, applied, diag.SeverityError)...)
+
+	plan.ID = plan.Registry
+	devices, diags := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: bulkDeviceModelTypes}, applied)
+	resp.Diagnostics.Append(diags...)
+	plan.Devices = devices
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+// Delete deletes every managed device. Devices that could not be deleted are
+// reported and kept in the state.
+func (r *devicesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	tflog.Debug(ctx, "Deleting iot devices resource")
+
+	var state devicesResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	current := map[string]bulkDeviceModel{}
+	resp.Diagnostics.Append(state.Devices.ElementsAs(ctx, &current, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ops := []*bulkDeviceOp{}
+	for _, id := range sortedDeviceIDs(current) {
+		ops = append(ops, &bulkDeviceOp{kind: bulkDeviceDelete, id: id, model: current[id]})
+	}
+
+	remaining := map[string]bulkDeviceModel{}
+	for id, model := range current {
+		remaining[id] = model
+	}
+	resp.Diagnostics.Append(r.apply(ctx, state, ops, remaining, diag.SeverityError)...)
+	if !resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Keep the devices that could not be deleted
+	devices, diags := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: bulkDeviceModelTypes}, remaining)
+	resp.Diagnostics.Append(diags...)
+	state.Devices = devices
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+// apply runs ops with a bounded worker pool and records every successful
+// change in devices. Failed changes are reported with the severity failures.
+func (r *devicesResource) apply(ctx context.Context, model devicesResourceModel, ops []*bulkDeviceOp, devices map[string]bulkDeviceModel, failures diag.Severity) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if len(ops) == 0 {
+		return diags
+	}
+
+	registry := model.Registry.ValueString()
+	region := os.Getenv("CLEARBLADE_REGION")
+	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), region, registry)
+	if err := primeRegistryCredentials(r.client, region, registry); err != nil {
+		diags.AddError(
+			"Error applying ClearBlade IoT Core device changes",
+			"Could not get the credentials of registry "+registry+": "+err.Error(),
+		)
+		return diags
+	}
+
+	concurrency := defaultConcurrency
+	if !model.Concurrency.IsNull() {
+		concurrency = int(model.Concurrency.ValueInt64())
+	}
+
+	progress := newProgressLogger("applied device changes", len(ops))
+	runBounded(ctx, len(ops), concurrency, func(ctx context.Context, i int) error {
+		op := ops[i]
+		op.err = r.applyOne(ctx, parent, op)
+		progress.done(ctx)
+		return op.err
+	})
+
+	for _, op := range ops {
+		if op.err != nil {
+			continue
+		}
+
+		switch op.kind {
+		case bulkDeviceCreate:
+			op.model.NumID = types.StringValue(op.numID)
+			devices[op.id] = op.model
+		case bulkDevicePatch:
+			devices[op.id] = op.model
+		case bulkDeviceDelete:
+			delete(devices, op.id)
+		}
+	}
+	diags.Append(bulkDeviceFailures(ops, failures)...)
+	return diags
+}
+
+// bulkDeviceFailures reports the failed ops with the given severity: the first
+// maxReportedFailures each on their device, and a summary of all of them.
+func bulkDeviceFailures(ops []*bulkDeviceOp, severity diag.Severity) diag.Diagnostics {
+	var diags diag.Diagnostics
+	failed := 0
+	for _, op := range ops {
+		if op.err == nil {
+			continue
+		}
+		failed++
+		if failed > maxReportedFailures {
+			continue
+		}
+		summary := "Error applying ClearBlade IoT Core device change"
+		detail := fmt.Sprintf("Could not %s device %s: %s", op.kind, op.id, op.err)
+		if severity == diag.SeverityWarning {
+			diags.AddAttributeWarning(path.Root("devices").AtMapKey(op.id), summary, detail)
+		} else {
+			diags.AddAttributeError(path.Root("devices").AtMapKey(op.id), summary, detail)
+		}
+	}
+	if failed == 0 {
+		return diags
+	}
+
+	detail := fmt.Sprintf("%d of %d device changes failed. The devices that were changed successfully have been saved to the state, "+
+		"and the next plan applies the failed changes again.", failed, len(ops))
+	if failed > maxReportedFailures {
+		detail += fmt.Sprintf(" Only the first %d failures are reported.", maxReportedFailures)
+	}
+	if severity == diag.SeverityWarning {
+		diags.AddWarning("Some device changes failed", detail)
+	} else {
+		diags.AddError("Some device changes failed", detail)
+	}
+	return diags
+}
+
+// applyOne makes the API call of a single op.
+func (r *devicesResource) applyOne(ctx context.Context, parent string, op *bulkDeviceOp) error {
+	name := parent + "/devices/" + op.id
+	switch op.kind {
+	case bulkDeviceCreate:
+		device, err := r.client.Projects.Locations.Registries.Devices.Create(parent, expandBulkDevice(ctx, op.id, op.model)).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		op.numID = strconv.FormatUint(device.NumId, 10)
+		return nil
+	case bulkDevicePatch:
+		device := expandBulkDevice(ctx, op.id, op.model)
+		_, err := r.client.Projects.Locations.Registries.Devices.Patch(name, device).UpdateMask("blocked,credentials,logLevel,metadata").Context(ctx).Do()
+		return err
+	default:
+		_, err := r.client.Projects.Locations.Registries.Devices.Delete(name).Context(ctx).Do()
+		return err
+	}
+}
+
+// expandBulkDevice builds the API request body of a device.
+func expandBulkDevice(ctx context.Context, id string, model bulkDeviceModel) *iot.Device {
+	credentials := []*iot.DeviceCredential{}
+	var credentialsModel []DevicePublicKeyCertificateModel
+	model.Credentials.ElementsAs(ctx, &credentialsModel, false)
+	for _, v := range credentialsModel {
+		credentials = append(credentials, &iot.DeviceCredential{
+			ExpirationTime: v.ExpirationTime.ValueString(),
+			PublicKey: &iot.PublicKeyCredential{
+				Format: v.PublicKey.Format.ValueString(),
+				Key:    v.PublicKey.Key.ValueString(),
+			},
+		})
+	}
+
+	metadata := map[string]string{}
+	for k, v := range model.Metadata.Elements() {
+		metadata[k] = v.(types.String).ValueString()
+	}
+
+	return &iot.Device{
+		Id:          id,
+		Credentials: credentials,
+		Blocked:     model.Blocked.ValueBool(),
+		LogLevel:    model.LogLevel.ValueString(),
+		Metadata:    metadata,
+	}
+}
+
+// flattenBulkDevice maps a listed device to its model. Attributes that are
+// null in prior stay null while the API reports their default value, so that
+// unset attributes do not show a diff.
+func flattenBulkDevice(ctx context.Context, device *iot.Device, prior *bulkDeviceModel) (bulkDeviceModel, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if prior == nil {
+		prior = &bulkDeviceModel{}
+	}
+
+	model := bulkDeviceModel{
+		NumID:       types.StringValue(strconv.FormatUint(device.NumId, 10)),
+		Blocked:     types.BoolNull(),
+		LogLevel:    types.StringNull(),
+		Metadata:    types.MapNull(types.StringType),
+		Credentials: types.ListNull(types.ObjectType{AttrTypes: DevicePublicKeyCertificateModelTypes}),
+	}
+
+	if !prior.Blocked.IsNull() || device.Blocked {
+		model.Blocked = types.BoolValue(device.Blocked)
+	}
+	if !prior.LogLevel.IsNull() || (device.LogLevel != "" && device.LogLevel != "NONE") {
+		model.LogLevel = types.StringValue(device.LogLevel)
+	}
+	if !prior.Metadata.IsNull() || len(device.Metadata) > 0 {
+		model.Metadata = flattenDeviceMetadata(device.Metadata, prior.Metadata)
+	}
+
+	credentials, d := flattenDeviceCredentials(ctx, prior.Credentials, device.Credentials)
+	diags.Append(d...)
+	model.Credentials = credentials
+
+	return model, diags
+}
+
+// bulkDeviceEqual reports whether two devices have the same configuration,
+// ignoring computed attributes.
+func bulkDeviceEqual(a, b bulkDeviceModel) bool {
+	return a.Credentials.Equal(b.Credentials) &&
+		a.Metadata.Equal(b.Metadata) &&
+		a.LogLevel.Equal(b.LogLevel) &&
+		a.Blocked.Equal(b.Blocked)
+}
+
+// sortedDeviceIDs returns the IDs of devices in ascending order.
+func sortedDeviceIDs(devices map[string]bulkDeviceModel) []string {
+	ids := make([]string, 0, len(devices))
+	for id := range devices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ImportState imports every device of a registry, using the registry name as
+// the import ID.
+func (r *devicesResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	tflog.Debug(ctx, "devices import event")
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("registry"), req.ID)...)
+}
+
+// Metadata returns the resource type name.
+func (r *devicesResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_iot_devices"
+}
+
+// Configure adds the provider configured client to the resource.
+func (r *devicesResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured
+	if req.ProviderData == nil {
+		return
+	}
+
+	data, ok := req.ProviderData.(*providerData)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *clearblade.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	r.client = data.client
+}
@@ -0,0 +1,368 @@
+package clearblade
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func testAccDevicesConfig(server *clearbladetest.Server, devices string) string {
+	return testAccProviderConfig(server) + `
+resource "clearblade_iot_devices" "test" {
+  registry    = "test-registry"
+  concurrency = 2
+
+  devices = {
+` + devices + `
+  }
+}
+`
+}
+
+func TestAccDevicesResource(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	// A device of the registry that the resource does not manage.
+	if err := server.PutDevice(testAccLocation+"/registries/test-registry", &iot.Device{Id: "unmanaged"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDevicesDestroy(server, "device-a", "device-b", "device-c"),
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: testAccDevicesConfig(server, `
+    device-a = {
+      metadata = {
+        site = "plant-1"
+      }
+    }
+    device-b = {
+      log_level = "INFO"
+    }
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "id", "test-registry"),
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.%", "2"),
+					resource.TestCheckResourceAttrSet("clearblade_iot_devices.test", "devices.device-a.num_id"),
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.device-a.metadata.site", "plant-1"),
+					testAccCheckDevice(server, "device-a", func(d deviceSnapshot) error {
+						if d.metadata["site"] != "plant-1" {
+							return fmt.Errorf("metadata %q, want site plant-1", d.metadata)
+						}
+						return nil
+					}),
+					testAccCheckDeviceExists(server, "device-b"),
+				),
+			},
+			// Update testing: device-a is changed, device-b deleted and
+			// device-c created.
+			{
+				Config: testAccDevicesConfig(server, `
+    device-a = {
+      blocked = true
+      metadata = {
+        site = "plant-2"
+      }
+    }
+    device-c = {
+      credentials = [
+        {
+          public_key = {
+            format = "ES256_PEM"
+            key    = <<-EOT
+`+testAccDeviceKey+`EOT
+          }
+        },
+      ]
+    }
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.%", "2"),
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.device-a.blocked", "true"),
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.device-c.credentials.#", "1"),
+					testAccCheckDevice(server, "device-a", func(d deviceSnapshot) error {
+						if !d.blocked || d.metadata["site"] != "plant-2" {
+							return fmt.Errorf("device-a is blocked %t with metadata %q, want blocked at plant-2", d.blocked, d.metadata)
+						}
+						return nil
+					}),
+					testAccCheckDevicesDestroy(server, "device-b"),
+					testAccCheckDevice(server, "device-c", func(d deviceSnapshot) error {
+						if d.credentials != 1 {
+							return fmt.Errorf("device-c has %d credentials, want 1", d.credentials)
+						}
+						return nil
+					}),
+					testAccCheckDeviceExists(server, "unmanaged"),
+				),
+			},
+			// ImportState testing: importing adopts every device of the
+			// registry.
+			{
+				ResourceName:      "clearblade_iot_devices.test",
+				ImportState:       true,
+				ImportStateId:     "test-registry",
+				ImportStateVerify: true,
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if got := states[0].Attributes["devices.%"]; got != "3" {
+						return fmt.Errorf("imported %s devices, want 3", got)
+					}
+					return nil
+				},
+				ImportStateVerifyIgnore: []string{"concurrency", "devices.%", "devices.unmanaged"},
+			},
+		},
+	})
+}
+
+func TestAccDevicesResource_partialFailure(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDevicesDestroy(server, "device-a", "device-b", "device-c", "device-d"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDevicesConfig(server, `
+    device-a = {}
+    device-b = {}
+    device-c = {}
+`),
+			},
+			// Updating device-b and deleting device-c fail, the other
+			// changes are applied.
+			{
+				PreConfig: func() {
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.patch", Path: "/devices/device-b$", Count: 1, Fault: clearbladetest.Unavailable})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.delete", Path: "/devices/device-c$", Count: 1, Fault: clearbladetest.Unavailable})
+				},
+				Config: testAccDevicesConfig(server, `
+    device-a = {
+      blocked = true
+    }
+    device-b = {
+      blocked = true
+    }
+    device-d = {}
+`),
+				// Each failed device is reported on its own, in any order.
+				ExpectError: regexp.MustCompile(`(?s)2 of 4 device changes failed.*(Could not update device device-b.*Could not delete device device-c|Could not delete device device-c.*Could not update device device-b)`),
+			},
+			// Only the failed changes are planned again.
+			{
+				PreConfig: func() {
+					server.ResetRequestCounts()
+				},
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("clearblade_iot_devices.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Config: testAccDevicesConfig(server, `
+    device-a = {
+      blocked = true
+    }
+    device-b = {
+      blocked = true
+    }
+    device-d = {}
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.%", "3"),
+					testAccCheckRequestCount(server, "registries.devices.create", 0),
+					testAccCheckRequestCount(server, "registries.devices.patch", 1),
+					testAccCheckRequestCount(server, "registries.devices.delete", 1),
+					testAccCheckDevice(server, "device-b", func(d deviceSnapshot) error {
+						if !d.blocked {
+							return fmt.Errorf("device-b is not blocked")
+						}
+						return nil
+					}),
+					testAccCheckDevicesDestroy(server, "device-c"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDevicesResource_createPartialFailure(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	// One of the creates fails.
+	server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.create", Count: 1, Fault: clearbladetest.Unavailable})
+	config := testAccDevicesConfig(server, `
+    device-a = {}
+    device-b = {}
+    device-c = {}
+`)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckDevicesDestroy(server, "device-a", "device-b", "device-c"),
+		Steps: []resource.TestStep{
+			// The failed device is a warning, so that the resource is not
+			// tainted, and refreshing drops it from the state.
+			{
+				Config:             config,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				RefreshState:       true,
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.%", "2"),
+					testAccCheckDevicesInState(server, "device-a", "device-b", "device-c"),
+				),
+			},
+			// The next apply creates only the failed device.
+			{
+				PreConfig: func() {
+					server.ResetRequestCounts()
+				},
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("clearblade_iot_devices.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_devices.test", "devices.%", "3"),
+					testAccCheckRequestCount(server, "registries.devices.create", 1),
+					testAccCheckRequestCount(server, "registries.devices.delete", 0),
+					testAccCheckDevicesInState(server, "device-a", "device-b", "device-c"),
+				),
+			},
+		},
+	})
+}
+
+// testAccCheckDevicesInState checks that each of the devices of test-registry
+// is in the state of clearblade_iot_devices.test, with a num_id, exactly when
+// it exists.
+func testAccCheckDevicesInState(server *clearbladetest.Server, ids ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["clearblade_iot_devices.test"]
+		if !ok {
+			return fmt.Errorf("clearblade_iot_devices.test is not in state")
+		}
+		for _, id := range ids {
+			_, exists := server.Device(testAccLocation + "/registries/test-registry/devices/" + id)
+			_, managed := rs.Primary.Attributes["devices."+id+".num_id"]
+			if exists != managed {
+				return fmt.Errorf("device %s exists %t, in state %t", id, exists, managed)
+			}
+		}
+		return nil
+	}
+}
+
+func TestBulkDeviceFailures(t *testing.T) {
+	ops := []*bulkDeviceOp{}
+	for i := 0; i < 30; i++ {
+		op := &bulkDeviceOp{kind: bulkDeviceCreate, id: fmt.Sprintf("device-%02d", i)}
+		if i%5 != 0 {
+			op.err = errors.New("unavailable")
+		}
+		ops = append(ops, op)
+	}
+
+	for _, severity := range []diag.Severity{diag.SeverityWarning, diag.SeverityError} {
+		diags := bulkDeviceFailures(ops, severity)
+		if len(diags) != maxReportedFailures+1 {
+			t.Fatalf("%s: %d diagnostics, want %d", severity, len(diags), maxReportedFailures+1)
+		}
+		for _, d := range diags {
+			if d.Severity() != severity {
+				t.Errorf("%s: diagnostic %q has severity %s", severity, d.Summary(), d.Severity())
+			}
+		}
+		if !strings.Contains(diags[0].Detail(), "device-01") {
+			t.Errorf("%s: first diagnostic %q, want device-01", severity, diags[0].Detail())
+		}
+		summary := diags[len(diags)-1].Detail()
+		if !strings.Contains(summary, "24 of 30 device changes failed") || !strings.Contains(summary, "first 10") {
+			t.Errorf("%s: summary %q", severity, summary)
+		}
+	}
+
+	if diags := bulkDeviceFailures(ops[:1], diag.SeverityError); len(diags) != 0 {
+		t.Errorf("diagnostics %v without failures", diags)
+	}
+}
+
+func TestAccDevicesResource_registryDeleted(t *testing.T) {
+	server := newTestAccServer(t)
+	server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+	config := testAccDevicesConfig(server, `
+    device-a = {}
+`)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			// The resource is removed from state, and planned to be created
+			// again, once its registry is gone.
+			{
+				PreConfig: func() {
+					server.DeleteRegistry(testAccLocation + "/registries/test-registry")
+				},
+				RefreshState:       true,
+				ExpectNonEmptyPlan: true,
+				Check: func(s *terraform.State) error {
+					if _, ok := s.RootModule().Resources["clearblade_iot_devices.test"]; ok {
+						return fmt.Errorf("clearblade_iot_devices.test is still in state")
+					}
+					return nil
+				},
+			},
+			{
+				PreConfig: func() {
+					server.PutRegistry(testAccLocation, &iot.DeviceRegistry{Id: "test-registry"})
+				},
+				Config: config,
+				Check:  testAccCheckDeviceExists(server, "device-a"),
+			},
+		},
+	})
+}
+
+// testAccCheckDevicesDestroy checks that the devices of test-registry do not
+// exist.
+func testAccCheckDevicesDestroy(server *clearbladetest.Server, ids ...string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		for _, id := range ids {
+			if _, ok := server.Device(testAccLocation + "/registries/test-registry/devices/" + id); ok {
+				return fmt.Errorf("device %s still exists", id)
+			}
+		}
+		return nil
+	}
+}
+
+// testAccCheckRequestCount checks the number of requests the fake served for
+// method since its counts were last reset.
+func testAccCheckRequestCount(server *clearbladetest.Server, method string, want int) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if got := server.RequestCount(method); got != want {
+			return fmt.Errorf("%d %s requests, want %d", got, method, want)
+		}
+		return nil
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/clearblade/go-iot"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
@@ -28,6 +30,7 @@ var (
 	_ resource.Resource                = &deviceRegistryResource{}
 	_ resource.ResourceWithConfigure   = &deviceRegistryResource{}
 	_ resource.ResourceWithImportState = &deviceRegistryResource{}
+	_ resource.ResourceWithMoveState   = &deviceRegistryResource{}
 )
 
 type deviceRegistryResourceModel struct {
@@ -40,6 +43,8 @@ type deviceRegistryResourceModel struct {
 	HttpConfig               types.Object                    `tfsdk:"http_config"`
 	LogLevel                 types.String                    `tfsdk:"log_level"`
 	Credentials              types.List                      `tfsdk:"credentials"`
+	DeletionProtection       types.Bool                      `tfsdk:"deletion_protection"`
+	ForceDestroy             types.Bool                      `tfsdk:"force_destroy"`
 	// Credentials              types.Set                       `tfsdk:"credentials"`
 	// Credentials              []CredentialsModel              `tfsdk:"credentials"`
 	// Region                   types.String                    `tfsdk:"region"`
@@ -102,6 +107,8 @@ func NewDeviceRegistryResource() resource.Resource {
 // deviceRegistryResource is the resource implementation.
 type deviceRegistryResource struct {
 	client *iot.Service
+	// deletionProtection is the provider default of deletion_protection.
+	deletionProtection bool
 }
 
 // Schema defines the schema for the resource.
@@ -110,6 +117,7 @@ func (r *deviceRegistryResource) Schema(ctx context.Context, _ resource.SchemaRe
 		Attributes: map[string]schema.Attribute{
 			"timeouts": timeouts.Attributes(ctx, timeouts.Opts{
 				Create: true,
+				Delete: true,
 			}),
 			"id": schema.StringAttribute{
 				MarkdownDescription: "The identifier of this device registry. For example, myRegistry.",
@@ -191,6 +199,16 @@ func (r *deviceRegistryResource) Schema(ctx context.Context, _ resource.SchemaRe
 					),
 				},
 			},
+			"deletion_protection": schema.BoolAttribute{
+				Description: "Whether Terraform is prevented from destroying the registry, which would disconnect all of its devices. " +
+					"Set it to false and apply the configuration before destroying a protected registry. Defaults to the provider deletion_protection setting.",
+				Optional: true,
+			},
+			"force_destroy": schema.BoolAttribute{
+				Description: "Whether destroying the registry first deletes its devices, after unbinding them from their gateways. " +
+					"Otherwise destroying a registry that has devices fails. Set it to true and apply the configuration before destroying the registry.",
+				Optional: true,
+			},
 			"credentials": schema.ListNestedAttribute{
 				// "credentials": schema.SetNestedAttribute{
 				Optional:            true,
@@ -331,7 +349,12 @@ func (r *deviceRegistryResource) Create(ctx context.Context, req resource.Create
 
 	// Create a new device registry resource on ClearBlade IoT Core
 	parent := fmt.Sprintf("projects/%s/locations/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"))
-	registry, err := r.client.Projects.Locations.Registries.Create(parent, &createRequestPayload).Do()
+	var registry *iot.DeviceRegistry
+	err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+		var err error
+		registry, err = r.client.Projects.Locations.Registries.Create(parent, &createRequestPayload).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating a device registry",
@@ -344,29 +367,10 @@ func (r *deviceRegistryResource) Create(ctx context.Context, req resource.Create
 
 	// Map response body to schema and populate Computed attribute values
 	plan.Name = types.StringValue(registry.Name)
-	plan.LogLevel = types.StringValue(registry.LogLevel)
+	plan.LogLevel = flattenOptionalString(plan.LogLevel, registry.LogLevel)
+	plan.EventNotificationConfigs = flattenEventNotificationConfigs(plan.EventNotificationConfigs, registry.EventNotificationConfigs)
 
-	plan.EventNotificationConfigs = []EventNotificationConfigsModel{}
-	for _, eventNotificationConfig := range registry.EventNotificationConfigs {
-		plan.EventNotificationConfigs = append(plan.EventNotificationConfigs, EventNotificationConfigsModel{
-			PubsubTopicName:  types.StringValue(eventNotificationConfig.PubsubTopicName),
-			SubfolderMatches: types.StringValue(eventNotificationConfig.SubfolderMatches),
-		})
-	}
-
-	if plan.StateNotificationConfig.IsNull() {
-		attributes := map[string]attr.Value{
-			"pubsub_topic_name": types.StringNull(),
-		}
-		plan.StateNotificationConfig = types.ObjectValueMust(StateNotificationConfigModelTypes, attributes)
-	} else {
-		ctx = tflog.SetField(ctx, "processing pubsub topic", registry.StateNotificationConfig.PubsubTopicName)
-		tflog.Info(ctx, "processing pubsub")
-		attributes := map[string]attr.Value{
-			"pubsub_topic_name": types.StringValue(registry.StateNotificationConfig.PubsubTopicName),
-		}
-		plan.StateNotificationConfig = types.ObjectValueMust(StateNotificationConfigModelTypes, attributes)
-	}
+	plan.StateNotificationConfig = flattenStateNotificationConfig(plan.StateNotificationConfig, registry.StateNotificationConfig)
 
 	if plan.MqttConfig.IsNull() {
 		attributes := map[string]attr.Value{
@@ -472,6 +476,10 @@ func (r *deviceRegistryResource) Create(ctx context.Context, req resource.Create
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	// Private is nil when the resource is not served by the framework.
+	if resp.Private != nil {
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, createdPrivateKey, []byte("true"))...)
+	}
 }
 
 // Read resource information and refreshes the Terraform state with the latest data.
@@ -488,7 +496,23 @@ func (r *deviceRegistryResource) Read(ctx context.Context, req resource.ReadRequ
 
 	// Get refreshed registry value from ClearBlade IoT Core
 	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.ID.ValueString())
-	registry, err := r.client.Projects.Locations.Registries.Get(parent).Do()
+	created, diags := req.Private.GetKey(ctx, createdPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	retryable := isRetryableError
+	if created != nil {
+		retryable = isRetryableAfterCreateError
+	}
+	var registry *iot.DeviceRegistry
+	err := apiRetryPolicy.do(ctx, retryable, func() error {
+		var err error
+		registry, err = r.client.Projects.Locations.Registries.Get(parent).Context(ctx).Do()
+		return err
+	})
+	if isNotFoundError(err) {
+		tflog.Warn(ctx, "device registry not found, removing it from state", map[string]any{"registry": parent})
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error Reading ClearBlade IoT Core Registry",
@@ -500,23 +524,14 @@ func (r *deviceRegistryResource) Read(ctx context.Context, req resource.ReadRequ
 	// Overwrite items with refreshed state
 	state.Name = types.StringValue(registry.Name)
 
-	state.LogLevel = types.StringValue(registry.LogLevel)
-
-	state.EventNotificationConfigs = []EventNotificationConfigsModel{}
-	for _, eventNotificationConfig := range registry.EventNotificationConfigs {
-		state.EventNotificationConfigs = append(state.EventNotificationConfigs, EventNotificationConfigsModel{
-			PubsubTopicName:  types.StringValue(eventNotificationConfig.PubsubTopicName),
-			SubfolderMatches: types.StringValue(eventNotificationConfig.SubfolderMatches),
-		})
-	}
+	state.LogLevel = flattenOptionalString(state.LogLevel, registry.LogLevel)
+	state.EventNotificationConfigs = flattenEventNotificationConfigs(state.EventNotificationConfigs, registry.EventNotificationConfigs)
 
 	state.MqttConfig = types.ObjectValueMust(MqttConfigModelTypes, map[string]attr.Value{
 		"mqtt_enabled_state": types.StringValue(registry.MqttConfig.MqttEnabledState),
 	})
 
-	state.StateNotificationConfig = types.ObjectValueMust(StateNotificationConfigModelTypes, map[string]attr.Value{
-		"pubsub_topic_name": types.StringValue(registry.StateNotificationConfig.PubsubTopicName),
-	})
+	state.StateNotificationConfig = flattenStateNotificationConfig(state.StateNotificationConfig, registry.StateNotificationConfig)
 
 	state.HttpConfig = types.ObjectValueMust(HttpConfigModelTypes, map[string]attr.Value{
 		"http_enabled_state": types.StringValue(registry.HttpConfig.HttpEnabledState),
@@ -557,7 +572,9 @@ func (r *deviceRegistryResource) Read(ctx context.Context, req resource.ReadRequ
 	if resp.Diagnostics.HasError() {
 		return
 	}
-
+	if resp.Private != nil {
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, createdPrivateKey, nil)...)
+	}
 }
 
 // Update updates the resource and sets the updated Terraform state on success.
@@ -637,9 +654,12 @@ func (r *deviceRegistryResource) Update(ctx context.Context, req resource.Update
 	// Update an existing registry
 	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), plan.ID.ValueString())
 
-	_, err := r.client.Projects.Locations.Registries.
-		Patch(parent, &updateRequestPayload).
-		UpdateMask(`httpConfig.http_enabled_state,logLevel,mqttConfig.mqtt_enabled_state,stateNotificationConfig.pubsub_topic_name,credentials,eventNotificationConfigs`).Do()
+	err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+		_, err := r.client.Projects.Locations.Registries.
+			Patch(parent, &updateRequestPayload).
+			UpdateMask(`httpConfig.http_enabled_state,logLevel,mqttConfig.mqtt_enabled_state,stateNotificationConfig.pubsub_topic_name,credentials,eventNotificationConfigs`).Context(ctx).Do()
+		return err
+	})
 	// ["eventNotificationConfigs","stateNotificationConfig.pubsub_topic_name","mqttConfig.mqtt_enabled_state","httpConfig.http_enabled_state","logLevel","credentials"]
 
 	if err != nil {
@@ -653,7 +673,12 @@ func (r *deviceRegistryResource) Update(ctx context.Context, req resource.Update
 	tflog.Debug(ctx, "device registry updated")
 
 	// Fetch updated registry value from ClearBlade IoT Core
-	registry, err := r.client.Projects.Locations.Registries.Get(parent).Do()
+	var registry *iot.DeviceRegistry
+	err = apiRetryPolicy.do(ctx, isRetryableError, func() error {
+		var err error
+		registry, err = r.client.Projects.Locations.Registries.Get(parent).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error Reading ClearBlade IoT Core Registry",
@@ -664,19 +689,9 @@ func (r *deviceRegistryResource) Update(ctx context.Context, req resource.Update
 
 	// Update registry resource - Map response body to schema and populate Computed attribute values
 	plan.Name = types.StringValue(registry.Name)
-	plan.LogLevel = types.StringValue(registry.LogLevel)
+	plan.LogLevel = flattenOptionalString(plan.LogLevel, registry.LogLevel)
 
-	if plan.StateNotificationConfig.IsNull() {
-		attributes := map[string]attr.Value{
-			"pubsub_topic_name": types.StringNull(),
-		}
-		plan.StateNotificationConfig = types.ObjectValueMust(StateNotificationConfigModelTypes, attributes)
-	} else {
-		attributes := map[string]attr.Value{
-			"pubsub_topic_name": types.StringValue(registry.StateNotificationConfig.PubsubTopicName),
-		}
-		plan.StateNotificationConfig = types.ObjectValueMust(StateNotificationConfigModelTypes, attributes)
-	}
+	plan.StateNotificationConfig = flattenStateNotificationConfig(plan.StateNotificationConfig, registry.StateNotificationConfig)
 
 	if plan.MqttConfig.IsNull() {
 		attributes := map[string]attr.Value{
@@ -766,10 +781,33 @@ func (r *deviceRegistryResource) Delete(ctx context.Context, req resource.Delete
 		return
 	}
 
-	// Delete existing registry on ClearBlade IoT Core
+	resp.Diagnostics.Append(checkDeletionProtection(state.DeletionProtection, r.deletionProtection, "Registry", state.ID.ValueString())...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	deleteTimeout, diags := state.Timeouts.Delete(ctx, 60*time.Minute)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+
 	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), state.ID.ValueString())
-	_, err := r.client.Projects.Locations.Registries.Delete(parent).Do()
-	if err != nil {
+	if state.ForceDestroy.ValueBool() {
+		resp.Diagnostics.Append(r.deleteDevices(ctx, parent, state.ID.ValueString())...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Delete existing registry on ClearBlade IoT Core
+	err := apiRetryPolicy.do(ctx, isRetryableError, func() error {
+		_, err := r.client.Projects.Locations.Registries.Delete(parent).Context(ctx).Do()
+		return err
+	})
+	if err != nil && !isNotFoundError(err) {
 		resp.Diagnostics.AddError(
 			"Error Deleting ClearBlade IoT Core Registry",
 			"Could not delete Registry, unexpected error: "+err.Error(),
@@ -778,12 +816,231 @@ func (r *deviceRegistryResource) Delete(ctx context.Context, req resource.Delete
 	}
 }
 
+// deleteDevices deletes every device of the registry parent for
+// force_destroy. Devices are unbound from their gateways first, as gateways
+// with bound devices cannot be deleted.
+func (r *deviceRegistryResource) deleteDevices(ctx context.Context, parent, registryID string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if err := primeRegistryCredentials(r.client, os.Getenv("CLEARBLADE_REGION"), registryID); err != nil {
+		diags.AddError(
+			"Error Deleting ClearBlade IoT Core Registry Devices",
+			"Could not get the credentials of registry "+registryID+": "+err.Error(),
+		)
+		return diags
+	}
+
+	devices, err := listDevices(ctx, r.client, parent, deviceListOptions{FieldMask: "gateway_config"})
+	if err != nil && !isNotFoundError(err) {
+		diags.AddError(
+			"Error Deleting ClearBlade IoT Core Registry Devices",
+			"Could not list the devices of registry "+registryID+": "+err.Error(),
+		)
+		return diags
+	}
+	gateways := []string{}
+	for _, device := range devices {
+		if device.GatewayConfig != nil && device.GatewayConfig.GatewayType == "GATEWAY" {
+			gateways = append(gateways, device.Id)
+		}
+	}
+	tflog.Info(ctx, "force destroying registry", map[string]any{"registry": registryID, "devices": len(devices), "gateways": len(gateways)})
+
+	// Unbind the devices of each gateway.
+	bound := make([][]*iot.Device, len(gateways))
+	errs := runBounded(ctx, len(gateways), defaultConcurrency, func(ctx context.Context, i int) (err error) {
+		bound[i], err = listDevices(ctx, r.client, parent, deviceListOptions{AssociationsGatewayID: gateways[i]})
+		return err
+	})
+	if diags.Append(forceDestroyErrors("list the devices bound to gateway", gateways, errs)...); diags.HasError() {
+		return diags
+	}
+	type binding struct{ gateway, device string }
+	bindings := []binding{}
+	for i, devices := range bound {
+		for _, device := range devices {
+			bindings = append(bindings, binding{gateway: gateways[i], device: device.Id})
+		}
+	}
+	progress := newProgressLogger("unbound devices from gateways", len(bindings))
+	errs = runBounded(ctx, len(bindings), defaultConcurrency, func(ctx context.Context, i int) error {
+		_, err := r.client.Projects.Locations.Registries.UnbindDeviceFromGateway(parent, &iot.UnbindDeviceFromGatewayRequest{
+			GatewayId: bindings[i].gateway,
+			DeviceId:  bindings[i].device,
+		}).Context(ctx).Do()
+		if isNotFoundError(err) {
+			err = nil
+		}
+		progress.done(ctx)
+		return err
+	})
+	names := make([]string, len(bindings))
+	for i, b := range bindings {
+		names[i] = b.device + " from gateway " + b.gateway
+	}
+	if diags.Append(forceDestroyErrors("unbind device", names, errs)...); diags.HasError() {
+		return diags
+	}
+
+	// Delete the devices.
+	progress = newProgressLogger("deleted devices", len(devices))
+	errs = runBounded(ctx, len(devices), defaultConcurrency, func(ctx context.Context, i int) error {
+		_, err := r.client.Projects.Locations.Registries.Devices.Delete(parent + "/devices/" + devices[i].Id).Context(ctx).Do()
+		if isNotFoundError(err) {
+			err = nil
+		}
+		progress.done(ctx)
+		return err
+	})
+	names = make([]string, len(devices))
+	for i, device := range devices {
+		names[i] = device.Id
+	}
+	diags.Append(forceDestroyErrors("delete device", names, errs)...)
+	return diags
+}
+
+// maxReportedFailures is the number of failures of a batch of device changes
+// that are reported in detail. The first failures are enough to diagnose the
+// problem.
+const maxReportedFailures = 10
+
+// forceDestroyErrors reports the failed steps of a force destroy, each the
+// action on one of names, as a single error diagnostic.
+func forceDestroyErrors(action string, names []string, errs []error) diag.Diagnostics {
+	var diags diag.Diagnostics
+	failed := []string{}
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("Could not %s %s: %s", action, names[i], err))
+		}
+	}
+	if len(failed) == 0 {
+		return diags
+	}
+	detail := strings.Join(failed[:min(len(failed), maxReportedFailures)], "\n")
+	if len(failed) > maxReportedFailures {
+		detail += fmt.Sprintf("\n... and %d more", len(failed)-maxReportedFailures)
+	}
+	diags.AddError(
+		"Error Deleting ClearBlade IoT Core Registry Devices",
+		fmt.Sprintf("%d of %d attempts to %s failed. The registry was not deleted.\n\n%s", len(failed), len(errs), action, detail),
+	)
+	return diags
+}
+
 func (r *deviceRegistryResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
 	// Retrieve import ID and save to id attribute
 	tflog.Debug(ctx, "registry import event")
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
 
+// googleRegistryState is the state of the Google provider's
+// google_cloudiot_registry resource.
+type googleRegistryState struct {
+	Name                     string `json:"name"`
+	Project                  string `json:"project"`
+	Region                   string `json:"region"`
+	LogLevel                 string `json:"log_level"`
+	EventNotificationConfigs []struct {
+		PubsubTopicName  string `json:"pubsub_topic_name"`
+		SubfolderMatches string `json:"subfolder_matches"`
+	} `json:"event_notification_configs"`
+	StateNotificationConfig map[string]string `json:"state_notification_config"`
+	MqttConfig              map[string]string `json:"mqtt_config"`
+	HttpConfig              map[string]string `json:"http_config"`
+	Credentials             []struct {
+		PublicKeyCertificate map[string]string `json:"public_key_certificate"`
+	} `json:"credentials"`
+}
+
+// MoveState moves google_cloudiot_registry resources of the Google provider
+// to this resource.
+func (r *deviceRegistryResource) MoveState(ctx context.Context) []resource.StateMover {
+	return []resource.StateMover{{
+		StateMover: func(ctx context.Context, req resource.MoveStateRequest, resp *resource.MoveStateResponse) {
+			if !isGoogleSource(req, "google_cloudiot_registry") {
+				return
+			}
+
+			var source googleRegistryState
+			resp.Diagnostics.Append(decodeSourceState(req, &source)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+			resp.Diagnostics.Append(checkMovedLocation(source.Project, source.Region)...)
+
+			var current resource.SchemaResponse
+			r.Schema(ctx, resource.SchemaRequest{}, &current)
+			credentialsType := current.Schema.Attributes["credentials"].GetType().(types.ListType).ElemType
+
+			target := deviceRegistryResourceModel{
+				Timeouts:                timeouts.Value{Object: types.ObjectNull(current.Schema.Attributes["timeouts"].GetType().(attr.TypeWithAttributeTypes).AttributeTypes())},
+				ID:                      types.StringValue(source.Name),
+				Name:                    types.StringValue(fmt.Sprintf("projects/%s/locations/%s/registries/%s", os.Getenv("CLEARBLADE_PROJECT"), os.Getenv("CLEARBLADE_REGION"), source.Name)),
+				StateNotificationConfig: types.ObjectNull(StateNotificationConfigModelTypes),
+				MqttConfig:              types.ObjectNull(MqttConfigModelTypes),
+				HttpConfig:              types.ObjectNull(HttpConfigModelTypes),
+				LogLevel:                types.StringNull(),
+				Credentials:             types.ListNull(credentialsType),
+				DeletionProtection:      types.BoolNull(),
+				ForceDestroy:            types.BoolNull(),
+			}
+			if source.LogLevel != "" {
+				target.LogLevel = types.StringValue(source.LogLevel)
+			}
+			for _, config := range source.EventNotificationConfigs {
+				target.EventNotificationConfigs = append(target.EventNotificationConfigs, EventNotificationConfigsModel{
+					PubsubTopicName:  types.StringValue(config.PubsubTopicName),
+					SubfolderMatches: types.StringValue(config.SubfolderMatches),
+				})
+			}
+			if topic := source.StateNotificationConfig["pubsub_topic_name"]; topic != "" {
+				target.StateNotificationConfig = types.ObjectValueMust(StateNotificationConfigModelTypes, map[string]attr.Value{
+					"pubsub_topic_name": types.StringValue(topic),
+				})
+			}
+			if state := source.MqttConfig["mqtt_enabled_state"]; state != "" {
+				target.MqttConfig = types.ObjectValueMust(MqttConfigModelTypes, map[string]attr.Value{
+					"mqtt_enabled_state": types.StringValue(state),
+				})
+			}
+			if state := source.HttpConfig["http_enabled_state"]; state != "" {
+				target.HttpConfig = types.ObjectValueMust(HttpConfigModelTypes, map[string]attr.Value{
+					"http_enabled_state": types.StringValue(state),
+				})
+			}
+			if len(source.Credentials) > 0 {
+				credentials := []CredentialsModel{}
+				for _, credential := range source.Credentials {
+					credentials = append(credentials, CredentialsModel{
+						PublicKeyCertificate: PublicKeyCertificateModel{
+							Format:      types.StringValue(credential.PublicKeyCertificate["format"]),
+							Certificate: types.StringValue(credential.PublicKeyCertificate["certificate"]),
+							X509Details: X509CertificateDetailsModel{
+								Issuer:             types.StringNull(),
+								Subject:            types.StringNull(),
+								StartTime:          types.StringNull(),
+								ExpiryTime:         types.StringNull(),
+								SignatureAlgorithm: types.StringNull(),
+								PublicKeyType:      types.StringNull(),
+							},
+						},
+					})
+				}
+				var diags diag.Diagnostics
+				target.Credentials, diags = types.ListValueFrom(ctx, credentialsType, credentials)
+				resp.Diagnostics.Append(diags...)
+			}
+			if resp.Diagnostics.HasError() {
+				return
+			}
+
+			tflog.Debug(ctx, "moving registry state", map[string]any{"from": req.SourceTypeName, "registry": source.Name})
+			resp.Diagnostics.Append(resp.TargetState.Set(ctx, target)...)
+		},
+	}}
+}
+
 // Metadata returns the data source type name.
 func (r *deviceRegistryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_iot_registry"
@@ -796,14 +1053,61 @@ func (r *deviceRegistryResource) Configure(ctx context.Context, req resource.Con
 		return
 	}
 
-	client, ok := req.ProviderData.(*iot.Service)
+	data, ok := req.ProviderData.(*providerData)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *iot.Service, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *clearblade.providerData, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	r.client = client
+	r.client = data.client
+	r.deletionProtection = data.deletionProtection
+}
+
+// flattenOptionalString keeps an optional attribute null when it was not
+// configured and the API returns its empty default.
+func flattenOptionalString(prior types.String, v string) types.String {
+	if prior.IsNull() && v == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(v)
+}
+
+// flattenEventNotificationConfigs maps the registry event notification
+// configs, keeping the attribute null when it was not configured and the
+// registry has none.
+func flattenEventNotificationConfigs(prior []EventNotificationConfigsModel, configs []*iot.EventNotificationConfig) []EventNotificationConfigsModel {
+	if prior == nil && len(configs) == 0 {
+		return nil
+	}
+
+	flattened := []EventNotificationConfigsModel{}
+	for _, config := range configs {
+		flattened = append(flattened, EventNotificationConfigsModel{
+			PubsubTopicName:  types.StringValue(config.PubsubTopicName),
+			SubfolderMatches: types.StringValue(config.SubfolderMatches),
+		})
+	}
+	return flattened
+}
+
+// flattenStateNotificationConfig maps the registry state notification config,
+// keeping the topic null when it was not configured and is not set.
+func flattenStateNotificationConfig(prior types.Object, config *iot.StateNotificationConfig) types.Object {
+	priorTopic := types.StringNull()
+	if !prior.IsNull() && !prior.IsUnknown() {
+		if topic, ok := prior.Attributes()["pubsub_topic_name"].(types.String); ok {
+			priorTopic = topic
+		}
+	}
+
+	topic := ""
+	if config != nil {
+		topic = config.PubsubTopicName
+	}
+	return types.ObjectValueMust(StateNotificationConfigModelTypes, map[string]attr.Value{
+		"pubsub_topic_name": flattenOptionalString(priorTopic, topic),
+	})
 }
@@ -0,0 +1,443 @@
+package clearblade
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+
+	"github.com/clearblade/go-iot"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestAccRegistryResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckRegistryDestroy(server),
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: testAccProviderConfig(server) + `
+resource "clearblade_iot_registry" "test" {
+  id        = "test-registry"
+  log_level = "INFO"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "id", "test-registry"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "name", testAccLocation+"/registries/test-registry"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "log_level", "INFO"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "mqtt_config.mqtt_enabled_state", "MQTT_ENABLED"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "http_config.http_enabled_state", "HTTP_ENABLED"),
+				),
+			},
+			// ImportState testing
+			{
+				ResourceName:            "clearblade_iot_registry.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"timeouts"},
+			},
+			// Update and Read testing
+			{
+				Config: testAccProviderConfig(server) + `
+resource "clearblade_iot_registry" "test" {
+  id        = "test-registry"
+  log_level = "DEBUG"
+
+  mqtt_config = {
+    mqtt_enabled_state = "MQTT_DISABLED"
+  }
+
+  state_notification_config = {
+    pubsub_topic_name = "projects/test-project/topics/states"
+  }
+
+  event_notification_configs = [
+    {
+      pubsub_topic_name  = "projects/test-project/topics/events"
+      sub_folder_matches = "telemetry"
+    },
+  ]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "log_level", "DEBUG"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "mqtt_config.mqtt_enabled_state", "MQTT_DISABLED"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "state_notification_config.pubsub_topic_name", "projects/test-project/topics/states"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "event_notification_configs.#", "1"),
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "event_notification_configs.0.sub_folder_matches", "telemetry"),
+					testAccCheckRegistryLogLevel(server, "test-registry", "DEBUG"),
+				),
+			},
+			// Delete testing automatically occurs in TestCase
+		},
+	})
+}
+
+func TestAccRegistryResource_disappears(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckRegistryDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+resource "clearblade_iot_registry" "test" {
+  id = "test-registry"
+}
+`,
+				Check: func(*terraform.State) error {
+					server.DeleteRegistry(testAccLocation + "/registries/test-registry")
+					return nil
+				},
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccRegistryResource_deletionProtection(t *testing.T) {
+	server := newTestAccServer(t)
+	config := func(protection bool) string {
+		return testAccProviderConfig(server) + fmt.Sprintf(`
+resource "clearblade_iot_registry" "test" {
+  id                  = "test-registry"
+  log_level           = "INFO"
+  deletion_protection = %t
+}
+`, protection)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckRegistryDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: config(true),
+				Check:  resource.TestCheckResourceAttr("clearblade_iot_registry.test", "deletion_protection", "true"),
+			},
+			{
+				Config:      config(true),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(`The registry "test-registry" has\s+deletion_protection set to true`),
+			},
+			// Lifting the protection takes an apply, after which the final
+			// destroy deletes the registry.
+			{
+				Config: config(false),
+				Check:  testAccCheckRegistryLogLevel(server, "test-registry", "INFO"),
+			},
+		},
+	})
+}
+
+func TestAccRegistryResource_forceDestroy(t *testing.T) {
+	server := newTestAccServer(t)
+	t.Setenv("CLEARBLADE_PROJECT", clearbladetest.Project)
+	t.Setenv("CLEARBLADE_REGION", testAccRegion)
+	registryName := testAccLocation + "/registries/test-registry"
+	config := func(forceDestroy bool) string {
+		return testAccProviderConfig(server) + fmt.Sprintf(`
+resource "clearblade_iot_registry" "test" {
+  id            = "test-registry"
+  force_destroy = %t
+}
+`, forceDestroy)
+	}
+
+	// seedDevices adds more devices than fit a page to the registry, some
+	// of them bound to gateways.
+	seedDevices := func(*terraform.State) error {
+		client, err := server.NewService(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, gateway := range []string{"gateway-1", "gateway-2"} {
+			if err := server.PutDevice(registryName, &iot.Device{Id: gateway, GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY"}}); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < clearbladetest.DefaultPageSize+20; i++ {
+			id := fmt.Sprintf("sensor-%03d", i)
+			if err := server.PutDevice(registryName, &iot.Device{Id: id}); err != nil {
+				return err
+			}
+			if i%10 == 0 {
+				gateway := fmt.Sprintf("gateway-%d", i%20/10+1)
+				if _, err := client.Projects.Locations.Registries.BindDeviceToGateway(registryName, &iot.BindDeviceToGatewayRequest{GatewayId: gateway, DeviceId: id}).Do(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			testAccCheckRegistryDestroy(server),
+			func(*terraform.State) error {
+				if n := server.RequestCount("registries.unbindDeviceFromGateway"); n != 12 {
+					return fmt.Errorf("force destroy made %d unbind requests, want 12", n)
+				}
+				return nil
+			},
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: config(false),
+				Check:  seedDevices,
+			},
+			// Without force_destroy, a registry with devices cannot be
+			// destroyed.
+			{
+				Config:      config(false),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(`is not empty`),
+			},
+			// Enabling it takes an apply, after which the final destroy
+			// deletes the devices and then the registry.
+			{
+				Config: config(true),
+				Check:  resource.TestCheckResourceAttr("clearblade_iot_registry.test", "force_destroy", "true"),
+			},
+		},
+	})
+}
+
+func TestAccRegistryResource_forceDestroyTimeout(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+resource "clearblade_iot_registry" "test" {
+  id            = "test-registry"
+  force_destroy = true
+
+  timeouts = {
+    delete = "1s"
+  }
+}
+`,
+				Check: func(*terraform.State) error {
+					for i := 0; i < 20; i++ {
+						if err := server.PutDevice(testAccLocation+"/registries/test-registry", &iot.Device{Id: fmt.Sprintf("sensor-%02d", i)}); err != nil {
+							return err
+						}
+					}
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.devices.delete", Fault: clearbladetest.Slow, Delay: 2 * time.Second})
+					return nil
+				},
+			},
+			// The device deletions outlast the delete timeout, so the
+			// registry is not deleted.
+			{
+				Config:      testAccProviderConfig(server),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(`context deadline exceeded`),
+			},
+			{
+				PreConfig: server.ClearFaults,
+				Config:    testAccProviderConfig(server),
+				Destroy:   true,
+			},
+		},
+	})
+}
+
+func testAccCheckRegistryLogLevel(server *clearbladetest.Server, id, logLevel string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		registry, ok := server.Registry(testAccLocation + "/registries/" + id)
+		if !ok {
+			return fmt.Errorf("registry %s not found", id)
+		}
+		if registry.LogLevel != logLevel {
+			return fmt.Errorf("registry %s has log level %q, want %q", id, registry.LogLevel, logLevel)
+		}
+		return nil
+	}
+}
+
+func testAccCheckRegistryDestroy(server *clearbladetest.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "clearblade_iot_registry" {
+				continue
+			}
+			if _, ok := server.Registry(testAccLocation + "/registries/" + rs.Primary.Attributes["id"]); ok {
+				return fmt.Errorf("registry %s still exists", rs.Primary.Attributes["id"])
+			}
+		}
+		return nil
+	}
+}
+
+func TestAccRegistryResource_faults(t *testing.T) {
+	server := newTestAccServer(t)
+	config := func(logLevel string) string {
+		return testAccProviderConfig(server) + fmt.Sprintf(`
+resource "clearblade_iot_registry" "test" {
+  id        = "test-registry"
+  log_level = %q
+}
+`, logLevel)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckRegistryDestroy(server),
+		Steps: []resource.TestStep{
+			// A create that stays throttled fails once the retries are spent,
+			// without storing the registry in state.
+			{
+				PreConfig: func() {
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.create", Fault: clearbladetest.TooManyRequests})
+				},
+				Config:      config("INFO"),
+				ExpectError: regexp.MustCompile(`Error\s+429`),
+			},
+			// Throttled and unavailable creates are retried until the
+			// registry is created.
+			{
+				PreConfig: func() {
+					if got := server.RequestCount("registries.create"); got != apiRetryPolicy.attempts {
+						t.Errorf("the failed create made %d requests, want %d", got, apiRetryPolicy.attempts)
+					}
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.create", Count: 1, Fault: clearbladetest.TooManyRequests})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.create", Count: 1, Fault: clearbladetest.Unavailable})
+				},
+				Config: config("INFO"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccCheckRegistryLogLevel(server, "test-registry", "INFO"),
+					testAccCheckRequestCount(server, "registries.create", 3),
+				),
+			},
+			// An update that stays unavailable fails once the retries are
+			// spent and keeps the prior state.
+			{
+				PreConfig: func() {
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.patch", Fault: clearbladetest.Unavailable})
+				},
+				Config:      config("DEBUG"),
+				ExpectError: regexp.MustCompile(`Error\s+503`),
+			},
+			// Throttled and unavailable updates are retried.
+			{
+				PreConfig: func() {
+					if got := server.RequestCount("registries.patch"); got != apiRetryPolicy.attempts {
+						t.Errorf("the failed update made %d requests, want %d", got, apiRetryPolicy.attempts)
+					}
+					server.ClearFaults()
+					server.ResetRequestCounts()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.patch", Count: 1, Fault: clearbladetest.TooManyRequests})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.patch", Count: 1, Fault: clearbladetest.Unavailable})
+				},
+				Config: config("DEBUG"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "log_level", "DEBUG"),
+					testAccCheckRegistryLogLevel(server, "test-registry", "DEBUG"),
+					testAccCheckRequestCount(server, "registries.patch", 3),
+				),
+			},
+			// Slow and throttled reads are retried and still refresh the
+			// registry.
+			{
+				PreConfig: func() {
+					server.ClearFaults()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.get", Count: 2, Fault: clearbladetest.TooManyRequests})
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.get", Fault: clearbladetest.Slow, Delay: 200 * time.Millisecond})
+				},
+				Config: config("DEBUG"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("clearblade_iot_registry.test", "log_level", "DEBUG"),
+					func(*terraform.State) error {
+						if got := server.FaultCount(); got < 3 {
+							return fmt.Errorf("%d faults were injected, want both throttled reads and a slow one", got)
+						}
+						return nil
+					},
+				),
+			},
+			// A delete over a reset connection fails and leaves the registry
+			// in state, so that the final destroy deletes it.
+			{
+				PreConfig: func() {
+					server.ClearFaults()
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.delete", Count: 1, Fault: clearbladetest.ConnectionReset})
+				},
+				Config:      config("DEBUG"),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(`reset|EOF`),
+			},
+			// The final destroy retries a throttled delete.
+			{
+				PreConfig: func() {
+					server.AddFault(clearbladetest.FaultRule{Method: "registries.delete", Count: 1, Fault: clearbladetest.TooManyRequests})
+				},
+				Config:   config("DEBUG"),
+				PlanOnly: true,
+			},
+		},
+	})
+
+	if got := server.FaultCount(); got != 2 {
+		t.Errorf("%d delete faults were injected, want the reset and the throttled delete", got)
+	}
+}
+
+// TestAccRegistryResource_notFoundAfterCreate covers a registry that is not
+// found, or whose reads fail transiently, right after it was created.
+func TestAccRegistryResource_notFoundAfterCreate(t *testing.T) {
+	for name, fault := range map[string]clearbladetest.Fault{
+		"not found":         clearbladetest.NotFound,
+		"too many requests": clearbladetest.TooManyRequests,
+		"unavailable":       clearbladetest.Unavailable,
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := newTestAccServer(t)
+			config := testAccProviderConfig(server) + `
+resource "clearblade_iot_registry" "test" {
+  id = "test-registry"
+}
+`
+
+			resource.Test(t, resource.TestCase{
+				ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+				CheckDestroy:             testAccCheckRegistryDestroy(server),
+				Steps: []resource.TestStep{
+					// The refresh after the create retries the reads that fail
+					// and keeps the registry, so that there is nothing to
+					// change.
+					{
+						PreConfig: func() {
+							server.AddFault(clearbladetest.FaultRule{Method: "registries.get", Count: 2, Fault: fault})
+						},
+						Config: config,
+					},
+					{
+						Config: config,
+						Check: resource.ComposeAggregateTestCheckFunc(
+							resource.TestCheckResourceAttrSet("clearblade_iot_registry.test", "name"),
+							testAccCheckFaultCount(server, 2),
+						),
+					},
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Package resourcename derives Terraform resource names from ClearBlade IoT
+// Core IDs, for the configurations generated by the provider and cb-tfgen.
+package resourcename
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var invalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Names hands out unique Terraform resource names.
+type Names map[string]bool
+
+// Name returns a unique name of a resource of type typ derived from id.
+// Characters that are not valid in a name are replaced with underscores, and
+// a name that does not start with a letter or an underscore is prefixed with
+// one.
+func (n Names) Name(typ, id string) string {
+	base := invalidChars.ReplaceAllString(id, "_")
+	if base == "" || !isLetterOrUnderscore(base[0]) {
+		base = "_" + base
+	}
+	name := base
+	for i := 2; n[typ+"."+name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	n[typ+"."+name] = true
+	return name
+}
+
+func isLetterOrUnderscore(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,20 @@
+package resourcename
+
+import "testing"
+
+func TestName(t *testing.T) {
+	names := Names{}
+	for _, tc := range []struct{ typ, id, want string }{
+		{"clearblade_iot_registry", "plant-1", "plant-1"},
+		{"clearblade_iot_registry", "plant.1", "plant_1"},
+		{"clearblade_iot_registry", "plant_1", "plant_1_2"},
+		{"clearblade_iot_registry", "plant+1", "plant_1_3"},
+		{"clearblade_iot_device", "plant.1", "plant_1"},
+		{"clearblade_iot_device", "1-sensor", "_1-sensor"},
+		{"clearblade_iot_device", "", "_"},
+	} {
+		if got := names.Name(tc.typ, tc.id); got != tc.want {
+			t.Errorf("Name(%q, %q) = %q, want %q", tc.typ, tc.id, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package clearblade
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"google.golang.org/api/googleapi"
+)
+
+// createdPrivateKey marks, in the private state of a registry or device, that
+// the resource was created and has not been read yet. Until then a read that
+// does not find it is retried, since the API may not find a resource for a
+// short time after it was created.
+const createdPrivateKey = "created"
+
+// retryPolicy bounds the retries of a request that failed transiently.
+type retryPolicy struct {
+	// attempts is the maximum number of times a request is made.
+	attempts int
+	// delay is the wait before the first retry. It doubles for every further
+	// retry, up to maxDelay.
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+// apiRetryPolicy is the policy of the registry and device create, read,
+// update and delete requests.
+var apiRetryPolicy = retryPolicy{attempts: 5, delay: time.Second, maxDelay: 16 * time.Second}
+
+// isRetryableError reports whether err is a 429 RESOURCE_EXHAUSTED or 503
+// UNAVAILABLE error, which the API returns for requests that may succeed
+// when they are made again.
+func isRetryableError(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	return gerr.Code == http.StatusTooManyRequests || gerr.Code == http.StatusServiceUnavailable
+}
+
+// isRetryableAfterCreateError reports whether err is retryable for a resource
+// that was just created, which may not be found yet.
+func isRetryableAfterCreateError(err error) bool {
+	return isRetryableError(err) || isNotFoundError(err)
+}
+
+// do calls fn until it succeeds, fails with an error retryable does not
+// accept, the attempts are spent or ctx is done. It returns the last error of
+// fn.
+func (p retryPolicy) do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	delay := p.delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.attempts || !retryable(err) {
+			return err
+		}
+
+		tflog.Warn(ctx, "request failed, retrying", map[string]any{"attempt": attempt, "delay": delay.String(), "error": err.Error()})
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay = min(2*delay, p.maxDelay)
+	}
+}
@@ -0,0 +1,55 @@
+package clearblade
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := retryPolicy{attempts: 3, delay: time.Millisecond, maxDelay: 2 * time.Millisecond}
+	throttled := &googleapi.Error{Code: http.StatusTooManyRequests}
+	notFound := &googleapi.Error{Code: http.StatusNotFound}
+
+	for _, tc := range []struct {
+		name      string
+		retryable func(error) bool
+		errs      []error
+		calls     int
+		want      error
+	}{
+		{"success", isRetryableError, []error{nil}, 1, nil},
+		{"retried", isRetryableError, []error{throttled, &googleapi.Error{Code: http.StatusServiceUnavailable}, nil}, 3, nil},
+		{"attempts spent", isRetryableError, []error{throttled, throttled, throttled, nil}, 3, throttled},
+		{"not retryable", isRetryableError, []error{notFound, nil}, 1, notFound},
+		{"not found after create", isRetryableAfterCreateError, []error{notFound, nil}, 2, nil},
+		{"other error", isRetryableAfterCreateError, []error{errors.New("connection reset"), nil}, 1, errors.New("connection reset")},
+	} {
+		calls := 0
+		err := policy.do(context.Background(), tc.retryable, func() error {
+			calls++
+			return tc.errs[calls-1]
+		})
+		if calls != tc.calls || (err == nil) != (tc.want == nil) || (err != nil && err.Error() != tc.want.Error()) {
+			t.Errorf("%s: %d calls returned %v, want %d calls returning %v", tc.name, calls, err, tc.calls, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyCanceled(t *testing.T) {
+	policy := retryPolicy{attempts: 3, delay: time.Hour, maxDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := policy.do(ctx, isRetryableError, func() error {
+		calls++
+		cancel()
+		return &googleapi.Error{Code: http.StatusServiceUnavailable}
+	})
+	if calls != 1 || !isRetryableError(err) {
+		t.Errorf("%d calls returned %v, want the error of the only call", calls, err)
+	}
+}
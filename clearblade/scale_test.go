@@ -0,0 +1,467 @@
+package clearblade
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"terraform-provider-clearblade/clearblade/clearbladetest"
+)
+
+// The scale benchmark creates, reads, updates and deletes -scale.registries
+// registries with -scale.devices devices each against the fake API, making at
+// most -scale.concurrency calls to the provider at a time, like terraform
+// apply -parallelism. For example, for 10k devices:
+//
+//	go test ./clearblade -run '^$' -bench Scale -benchtime 1x -scale.registries 10 -scale.devices 1000
+var (
+	scaleRegistries  = flag.Int("scale.registries", 10, "number of registries of the scale benchmark")
+	scaleDevices     = flag.Int("scale.devices", 100, "number of devices per registry of the scale benchmark")
+	scaleConcurrency = flag.Int("scale.concurrency", 10, "number of concurrent operations of the scale benchmark")
+)
+
+// scaleOptions describe a scale run.
+type scaleOptions struct {
+	Registries  int
+	Devices     int
+	Concurrency int
+}
+
+// scalePhase is the outcome of running one operation on every registry or
+// device of a scale run.
+type scalePhase struct {
+	Name       string
+	Operations int
+	// Calls is the number of API requests made for each method.
+	Calls     map[string]int
+	Latencies []time.Duration
+	Duration  time.Duration
+}
+
+// totalCalls returns the number of API requests made in the phase.
+func (p scalePhase) totalCalls() int {
+	total := 0
+	for _, n := range p.Calls {
+		total += n
+	}
+	return total
+}
+
+// callsPerOperation returns the API requests made per operation for each
+// method.
+func (p scalePhase) callsPerOperation() map[string]float64 {
+	calls := map[string]float64{}
+	for method, n := range p.Calls {
+		calls[method] = float64(n) / float64(p.Operations)
+	}
+	return calls
+}
+
+// latency returns the q quantile of the operation latencies.
+func (p scalePhase) latency(q float64) time.Duration {
+	if len(p.Latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), p.Latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+// scaleResult is the outcome of a scale run.
+type scaleResult struct {
+	Phases []scalePhase
+	// PeakHeap is the largest heap size seen during the run, in bytes.
+	PeakHeap uint64
+}
+
+// String formats r as a table with a row per phase.
+func (r scaleResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s %6s %7s %10s %10s %10s %10s %10s  %s\n", "phase", "ops", "calls", "calls/op", "p50", "p95", "p99", "total", "calls by method")
+	for _, p := range r.Phases {
+		methods := []string{}
+		for method, n := range p.Calls {
+			methods = append(methods, fmt.Sprintf("%s=%d", method, n))
+		}
+		sort.Strings(methods)
+		fmt.Fprintf(&b, "%-16s %6d %7d %10.2f %10s %10s %10s %10s  %s\n",
+			p.Name, p.Operations, p.totalCalls(), float64(p.totalCalls())/float64(p.Operations),
+			p.latency(0.5).Round(time.Microsecond), p.latency(0.95).Round(time.Microsecond), p.latency(0.99).Round(time.Microsecond),
+			p.Duration.Round(time.Millisecond), strings.Join(methods, " "))
+	}
+	fmt.Fprintf(&b, "peak heap %.1f MiB\n", float64(r.PeakHeap)/(1<<20))
+	return b.String()
+}
+
+// scaleHarness drives the registry and device resources directly, the way
+// Terraform does through the framework, against the fake API.
+type scaleHarness struct {
+	server   *clearbladetest.Server
+	registry resource.Resource
+	device   resource.Resource
+
+	registrySchema schema.Schema
+	deviceSchema   schema.Schema
+}
+
+func newScaleHarness(t testing.TB) *scaleHarness {
+	t.Helper()
+	ctx := context.Background()
+
+	server := clearbladetest.NewServer()
+	t.Cleanup(server.Close)
+	// The resources build resource names from the environment the provider
+	// configuration sets.
+	t.Setenv("CLEARBLADE_PROJECT", clearbladetest.Project)
+	t.Setenv("CLEARBLADE_REGION", testAccRegion)
+
+	client, err := server.NewService(ctx)
+	if err != nil {
+		t.Fatalf("creating client: %s", err)
+	}
+	data := &providerData{client: client}
+
+	h := &scaleHarness{
+		server:   server,
+		registry: NewDeviceRegistryResource(),
+		device:   NewDeviceResource(),
+	}
+	for _, r := range []struct {
+		resource resource.Resource
+		schema   *schema.Schema
+	}{
+		{h.registry, &h.registrySchema},
+		{h.device, &h.deviceSchema},
+	} {
+		var configureResp resource.ConfigureResponse
+		r.resource.(resource.ResourceWithConfigure).Configure(ctx, resource.ConfigureRequest{ProviderData: data}, &configureResp)
+		if configureResp.Diagnostics.HasError() {
+			t.Fatalf("configuring resource: %v", configureResp.Diagnostics)
+		}
+		var schemaResp resource.SchemaResponse
+		r.resource.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+		*r.schema = schemaResp.Schema
+	}
+	return h
+}
+
+// plan returns the plan of a resource of schema s whose configuration sets
+// config and whose prior state is prior, or a new resource if prior is null.
+// Like Terraform, it marks the computed attributes that are not configured as
+// unknown, unless a plan modifier keeps their prior value.
+func (h *scaleHarness) plan(ctx context.Context, s schema.Schema, prior tftypes.Value, config map[string]tftypes.Value) tfsdk.Plan {
+	typ := s.Type().TerraformType(ctx).(tftypes.Object)
+
+	priorValues := map[string]tftypes.Value{}
+	if !prior.IsNull() {
+		if err := prior.As(&priorValues); err != nil {
+			panic(err)
+		}
+	}
+
+	values := map[string]tftypes.Value{}
+	for name, attrType := range typ.AttributeTypes {
+		attribute := s.Attributes[name]
+		v, configured := config[name]
+		switch {
+		case configured:
+		case attribute != nil && attribute.IsComputed() && (prior.IsNull() || !keepsPriorValue(attribute)):
+			v = tftypes.NewValue(attrType, tftypes.UnknownValue)
+		case !prior.IsNull() && attribute != nil && attribute.IsComputed():
+			v = priorValues[name]
+		default:
+			v = tftypes.NewValue(attrType, nil)
+		}
+		values[name] = v
+	}
+	return tfsdk.Plan{Schema: s, Raw: tftypes.NewValue(typ, values)}
+}
+
+// keepsPriorValue reports whether a plan modifier of attribute keeps its prior
+// value. The resources only use string plan modifiers.
+func keepsPriorValue(attribute schema.Attribute) bool {
+	a, ok := attribute.(schema.StringAttribute)
+	return ok && len(a.PlanModifiers) > 0
+}
+
+func (h *scaleHarness) create(ctx context.Context, r resource.Resource, s schema.Schema, config map[string]tftypes.Value) (tftypes.Value, error) {
+	req := resource.CreateRequest{Plan: h.plan(ctx, s, tftypes.NewValue(s.Type().TerraformType(ctx), nil), config)}
+	resp := resource.CreateResponse{State: tfsdk.State{Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(ctx), nil)}}
+	r.Create(ctx, req, &resp)
+	return resp.State.Raw, diagnosticsError(resp.Diagnostics)
+}
+
+func (h *scaleHarness) read(ctx context.Context, r resource.Resource, s schema.Schema, state tftypes.Value) (tftypes.Value, error) {
+	req := resource.ReadRequest{State: tfsdk.State{Schema: s, Raw: state}}
+	resp := resource.ReadResponse{State: tfsdk.State{Schema: s, Raw: state.Copy()}}
+	r.Read(ctx, req, &resp)
+	return resp.State.Raw, diagnosticsError(resp.Diagnostics)
+}
+
+func (h *scaleHarness) update(ctx context.Context, r resource.Resource, s schema.Schema, state tftypes.Value, config map[string]tftypes.Value) (tftypes.Value, error) {
+	req := resource.UpdateRequest{
+		Plan:  h.plan(ctx, s, state, config),
+		State: tfsdk.State{Schema: s, Raw: state},
+	}
+	resp := resource.UpdateResponse{State: tfsdk.State{Schema: s, Raw: state.Copy()}}
+	r.Update(ctx, req, &resp)
+	return resp.State.Raw, diagnosticsError(resp.Diagnostics)
+}
+
+func (h *scaleHarness) delete(ctx context.Context, r resource.Resource, s schema.Schema, state tftypes.Value) error {
+	req := resource.DeleteRequest{State: tfsdk.State{Schema: s, Raw: state}}
+	resp := resource.DeleteResponse{State: tfsdk.State{Schema: s, Raw: state.Copy()}}
+	r.Delete(ctx, req, &resp)
+	return diagnosticsError(resp.Diagnostics)
+}
+
+// diagnosticsError returns the errors of diags as one error, or nil.
+func diagnosticsError(diags diag.Diagnostics) error {
+	if !diags.HasError() {
+		return nil
+	}
+	messages := []string{}
+	for _, d := range diags.Errors() {
+		messages = append(messages, d.Summary()+": "+d.Detail())
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// phase calls op for every index in [0, n), at most concurrency at a time,
+// and records its latencies and the API requests it makes.
+func (h *scaleHarness) phase(ctx context.Context, name string, n, concurrency int, op func(ctx context.Context, i int) error) (scalePhase, error) {
+	h.server.ResetRequestCounts()
+
+	var mu sync.Mutex
+	latencies := make([]time.Duration, 0, n)
+	start := time.Now()
+	errs := runBounded(ctx, n, concurrency, func(ctx context.Context, i int) error {
+		opStart := time.Now()
+		err := op(ctx, i)
+		mu.Lock()
+		latencies = append(latencies, time.Since(opStart))
+		mu.Unlock()
+		return err
+	})
+	p := scalePhase{
+		Name:       name,
+		Operations: n,
+		Calls:      h.server.RequestCounts(),
+		Latencies:  latencies,
+		Duration:   time.Since(start),
+	}
+	for i, err := range errs {
+		if err != nil {
+			return p, fmt.Errorf("%s %d: %w", name, i, err)
+		}
+	}
+	return p, nil
+}
+
+// run creates, reads, updates and deletes the registries and devices of opts.
+func (h *scaleHarness) run(ctx context.Context, opts scaleOptions) (scaleResult, error) {
+	var result scaleResult
+	stopSampling := samplePeakHeap(&result.PeakHeap)
+	defer stopSampling()
+
+	registryIDs := make([]string, opts.Registries)
+	for i := range registryIDs {
+		registryIDs[i] = fmt.Sprintf("scale-registry-%d", i)
+	}
+	registries := make([]tftypes.Value, opts.Registries)
+	devices := make([]tftypes.Value, opts.Registries*opts.Devices)
+	deviceRegistry := func(i int) string { return registryIDs[i/opts.Devices] }
+	deviceID := func(i int) string { return fmt.Sprintf("device-%d", i%opts.Devices) }
+
+	phases := []struct {
+		name string
+		n    int
+		op   func(ctx context.Context, i int) error
+	}{
+		{"registry create", opts.Registries, func(ctx context.Context, i int) (err error) {
+			registries[i], err = h.create(ctx, h.registry, h.registrySchema, map[string]tftypes.Value{
+				"id": tftypes.NewValue(tftypes.String, registryIDs[i]),
+			})
+			return err
+		}},
+		{"device create", len(devices), func(ctx context.Context, i int) (err error) {
+			devices[i], err = h.create(ctx, h.device, h.deviceSchema, map[string]tftypes.Value{
+				"id":       tftypes.NewValue(tftypes.String, deviceID(i)),
+				"registry": tftypes.NewValue(tftypes.String, deviceRegistry(i)),
+				"metadata": scaleMetadata("1"),
+			})
+			return err
+		}},
+		{"registry read", opts.Registries, func(ctx context.Context, i int) (err error) {
+			registries[i], err = h.read(ctx, h.registry, h.registrySchema, registries[i])
+			return err
+		}},
+		{"device read", len(devices), func(ctx context.Context, i int) (err error) {
+			devices[i], err = h.read(ctx, h.device, h.deviceSchema, devices[i])
+			return err
+		}},
+		{"registry update", opts.Registries, func(ctx context.Context, i int) (err error) {
+			registries[i], err = h.update(ctx, h.registry, h.registrySchema, registries[i], map[string]tftypes.Value{
+				"id":        tftypes.NewValue(tftypes.String, registryIDs[i]),
+				"log_level": tftypes.NewValue(tftypes.String, "INFO"),
+			})
+			return err
+		}},
+		{"device update", len(devices), func(ctx context.Context, i int) (err error) {
+			devices[i], err = h.update(ctx, h.device, h.deviceSchema, devices[i], map[string]tftypes.Value{
+				"id":       tftypes.NewValue(tftypes.String, deviceID(i)),
+				"registry": tftypes.NewValue(tftypes.String, deviceRegistry(i)),
+				"metadata": scaleMetadata("2"),
+			})
+			return err
+		}},
+		{"device delete", len(devices), func(ctx context.Context, i int) error {
+			return h.delete(ctx, h.device, h.deviceSchema, devices[i])
+		}},
+		{"registry delete", opts.Registries, func(ctx context.Context, i int) error {
+			return h.delete(ctx, h.registry, h.registrySchema, registries[i])
+		}},
+	}
+	for _, p := range phases {
+		if p.n == 0 {
+			continue
+		}
+		phase, err := h.phase(ctx, p.name, p.n, opts.Concurrency, p.op)
+		result.Phases = append(result.Phases, phase)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func scaleMetadata(generation string) tftypes.Value {
+	return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+		"generation": tftypes.NewValue(tftypes.String, generation),
+	})
+}
+
+// samplePeakHeap records the largest heap size in peak until the returned
+// function is called.
+func samplePeakHeap(peak *uint64) (stop func()) {
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	sample := func() {
+		metrics.Read(samples)
+		if v := samples[0].Value.Uint64(); v > *peak {
+			*peak = v
+		}
+	}
+
+	runtime.GC()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		sample()
+	}
+}
+
+// TestScaleCallBudget checks the API requests each operation makes, so that
+// extra requests, such as a Get after every device Patch, are caught.
+func TestScaleCallBudget(t *testing.T) {
+	h := newScaleHarness(t)
+	result, err := h.run(context.Background(), scaleOptions{Registries: 2, Devices: 3, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("%s\n%s", err, result)
+	}
+
+	want := map[string]map[string]float64{
+		"registry create": {"registries.create": 1},
+		"device create":   {"registries.devices.create": 1},
+		"registry read":   {"registries.get": 1},
+		"device read":     {"registries.devices.get": 1},
+		// A registry update reads the registry back after patching it.
+		"registry update": {"registries.get": 1, "registries.patch": 1},
+		"device update":   {"registries.devices.patch": 1},
+		"device delete":   {"registries.devices.delete": 1},
+		"registry delete": {"registries.delete": 1},
+	}
+	for _, p := range result.Phases {
+		got := p.callsPerOperation()
+		// The client exchanges credentials once per registry, so the exchange
+		// is not part of the budget of an operation.
+		delete(got, "getRegistryCredentials")
+		if fmt.Sprint(got) != fmt.Sprint(want[p.Name]) {
+			t.Errorf("%s: API requests per operation %v, want %v", p.Name, got, want[p.Name])
+		}
+	}
+	if t.Failed() {
+		t.Logf("\n%s", result)
+	}
+}
+
+// BenchmarkScale reports the API requests, latencies and peak heap of the
+// create, read, update and delete operations of the scale run set by the
+// -scale flags.
+func BenchmarkScale(b *testing.B) {
+	opts := scaleOptions{Registries: *scaleRegistries, Devices: *scaleDevices, Concurrency: *scaleConcurrency}
+
+	var last scaleResult
+	var peakHeap uint64
+	calls := map[string]int{}
+	latencies := map[string][]time.Duration{}
+	operations := map[string]int{}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		h := newScaleHarness(b)
+		b.StartTimer()
+
+		result, err := h.run(context.Background(), opts)
+		if err != nil {
+			b.Fatalf("%s\n%s", err, result)
+		}
+		for _, p := range result.Phases {
+			calls[p.Name] += p.totalCalls()
+			operations[p.Name] += p.Operations
+			latencies[p.Name] = append(latencies[p.Name], p.Latencies...)
+		}
+		if result.PeakHeap > peakHeap {
+			peakHeap = result.PeakHeap
+		}
+		last = result
+	}
+
+	for _, p := range last.Phases {
+		unit := strings.ReplaceAll(p.Name, " ", "-")
+		all := scalePhase{Latencies: latencies[p.Name]}
+		b.ReportMetric(float64(calls[p.Name])/float64(operations[p.Name]), "calls/"+unit)
+		b.ReportMetric(float64(all.latency(0.5).Microseconds()), "p50-µs/"+unit)
+		b.ReportMetric(float64(all.latency(0.99).Microseconds()), "p99-µs/"+unit)
+	}
+	b.ReportMetric(float64(peakHeap)/(1<<20), "peak-heap-MiB")
+	b.Logf("%d registries with %d devices each, concurrency %d\n%s", opts.Registries, opts.Devices, opts.Concurrency, last)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"terraform-provider-clearblade/clearblade/resourcename"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// providerVariables are the input variables of the provider block.
+var providerVariables = []struct{ name, description string }{
+	{"project", "The ClearBlade IoT Core project."},
+	{"region", "The region of the registries."},
+	{"credentials_file", "The path of the service account credentials file."},
+}
+
+// outputFiles are the files generate may return.
+var outputFiles = []string{"providers.tf", "registries.tf", "devices.tf", "bindings.tf"}
+
+// generate returns the contents of the configuration files of registries,
+// keyed by file name. vars sets the defaults of input variables.
+func generate(registries []*registry, vars map[string]string) (map[string][]byte, error) {
+	files := map[string][]byte{
+		"providers.tf": generateProviders(vars),
+	}
+
+	names := resourcename.Names{}
+	registriesFile := hclwrite.NewEmptyFile()
+	devicesFile := hclwrite.NewEmptyFile()
+	bindings := map[string]cty.Value{}
+	devices := 0
+
+	for i, r := range registries {
+		registryName := names.Name("clearblade_iot_registry", r.ID)
+		if i > 0 {
+			registriesFile.Body().AppendNewline()
+		}
+		block := registriesFile.Body().AppendNewBlock("resource", []string{"clearblade_iot_registry", registryName}).Body()
+		block.SetAttributeValue("id", cty.StringVal(r.ID))
+		if r.LogLevel != "" {
+			block.SetAttributeValue("log_level", cty.StringVal(r.LogLevel))
+		}
+
+		registryBindings := map[string][]cty.Value{}
+		for _, d := range r.Devices {
+			if devices > 0 {
+				devicesFile.Body().AppendNewline()
+			}
+			devices++
+			appendDevice(devicesFile.Body(), names.Name("clearblade_iot_device", r.ID+"_"+d.ID), registryName, d)
+
+			if d.GatewayID != "" {
+				registryBindings[d.GatewayID] = append(registryBindings[d.GatewayID], cty.StringVal(d.ID))
+			}
+		}
+		if len(registryBindings) > 0 {
+			gateways := map[string]cty.Value{}
+			for gateway, devices := range registryBindings {
+				gateways[gateway] = cty.ListVal(devices)
+			}
+			bindings[r.ID] = cty.MapVal(gateways)
+		}
+	}
+
+	files["registries.tf"] = registriesFile.Bytes()
+	if devices > 0 {
+		files["devices.tf"] = devicesFile.Bytes()
+	}
+	if len(bindings) > 0 {
+		files["bindings.tf"] = generateBindings(bindings)
+	}
+	return files, nil
+}
+
+// generateProviders returns the provider configuration and its input
+// variables.
+func generateProviders(vars map[string]string) []byte {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+
+	terraform := body.AppendNewBlock("terraform", nil).Body()
+	required := terraform.AppendNewBlock("required_providers", nil).Body()
+	required.SetAttributeValue("clearblade", cty.ObjectVal(map[string]cty.Value{
+		"source": cty.StringVal("ClearBlade/clearblade"),
+	}))
+
+	body.AppendNewline()
+	provider := body.AppendNewBlock("provider", []string{"clearblade"}).Body()
+	for _, v := range providerVariables {
+		provider.SetAttributeTraversal(v.name, hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: v.name}})
+	}
+
+	declared := map[string]bool{}
+	for _, v := range providerVariables {
+		declared[v.name] = true
+		appendVariable(body, v.name, v.description, vars)
+	}
+	extra := []string{}
+	for name := range vars {
+		if !declared[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		appendVariable(body, name, "", vars)
+	}
+	return f.Bytes()
+}
+
+func appendVariable(body *hclwrite.Body, name, description string, vars map[string]string) {
+	body.AppendNewline()
+	variable := body.AppendNewBlock("variable", []string{name}).Body()
+	variable.SetAttributeTraversal("type", hcl.Traversal{hcl.TraverseRoot{Name: "string"}})
+	if description != "" {
+		variable.SetAttributeValue("description", cty.StringVal(description))
+	}
+	if v, ok := vars[name]; ok {
+		variable.SetAttributeValue("default", cty.StringVal(v))
+	}
+}
+
+// appendDevice appends the resource block of a device of the registry
+// resource registryName.
+func appendDevice(body *hclwrite.Body, name, registryName string, d *device) {
+	block := body.AppendNewBlock("resource", []string{"clearblade_iot_device", name}).Body()
+	block.SetAttributeValue("id", cty.StringVal(d.ID))
+	block.SetAttributeTraversal("registry", hcl.Traversal{
+		hcl.TraverseRoot{Name: "clearblade_iot_registry"},
+		hcl.TraverseAttr{Name: registryName},
+		hcl.TraverseAttr{Name: "id"},
+	})
+	if d.LogLevel != "" {
+		block.SetAttributeValue("log_level", cty.StringVal(d.LogLevel))
+	}
+	if d.Blocked {
+		block.SetAttributeValue("blocked", cty.True)
+	}
+	if len(d.Metadata) > 0 {
+		metadata := map[string]cty.Value{}
+		for k, v := range d.Metadata {
+			metadata[k] = cty.StringVal(v)
+		}
+		block.SetAttributeValue("metadata", cty.MapVal(metadata))
+	}
+	if d.PublicKey != "" {
+		block.SetAttributeValue("credentials", cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"public_key": cty.ObjectVal(map[string]cty.Value{
+					"format": cty.StringVal(d.PublicKeyFormat),
+					"key":    cty.StringVal(d.PublicKey),
+				}),
+			}),
+		}))
+	}
+	if d.Gateway {
+		block.SetAttributeValue("gateway_config", cty.ObjectVal(map[string]cty.Value{
+			"gateway_type":        cty.StringVal("GATEWAY"),
+			"gateway_auth_method": cty.StringVal("ASSOCIATION_ONLY"),
+		}))
+	}
+}
+
+// generateBindings returns the gateway bindings, keyed by registry and then
+// gateway ID. The provider does not manage bindings, so they are written to a
+// local value for the tooling that binds the devices.
+func generateBindings(bindings map[string]cty.Value) []byte {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	body.AppendUnstructuredTokens(hclwrite.Tokens{{
+		Type:  hclsyntax.TokenComment,
+		Bytes: []byte("# Devices bound to each gateway, keyed by registry and gateway ID.\n"),
+	}})
+	locals := body.AppendNewBlock("locals", nil).Body()
+	locals.SetAttributeValue("gateway_bindings", cty.MapVal(bindings))
+	return f.Bytes()
+}
+
+// writeFiles writes files to dir, creating it if needed. Unless force is set,
+// it does not overwrite any of outputFiles.
+func writeFiles(dir string, files map[string][]byte, force bool) error {
+	if !force {
+		for _, name := range outputFiles {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return fmt.Errorf("%s already exists; use -force to overwrite it", filepath.Join(dir, name))
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), hclwrite.Format(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
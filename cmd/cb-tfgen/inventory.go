@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// registry is a registry to generate and its devices.
+type registry struct {
+	ID       string    `json:"id"`
+	LogLevel string    `json:"log_level,omitempty"`
+	Devices  []*device `json:"devices,omitempty"`
+}
+
+// device is a device to generate.
+type device struct {
+	ID       string            `json:"id"`
+	LogLevel string            `json:"log_level,omitempty"`
+	Blocked  bool              `json:"blocked,omitempty"`
+	Gateway  bool              `json:"gateway,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// GatewayID is the ID of the gateway the device is bound to.
+	GatewayID       string `json:"gateway_id,omitempty"`
+	PublicKeyFormat string `json:"public_key_format,omitempty"`
+	PublicKey       string `json:"public_key,omitempty"`
+}
+
+// syntheticOptions describe synthetic registries and devices.
+type syntheticOptions struct {
+	Registries        int
+	Devices           int
+	Gateways          int
+	DevicesPerGateway int
+	RegistryName      string
+	DeviceName        string
+	GatewayName       string
+	RegistryLogLevel  string
+	DeviceLogLevel    string
+	Metadata          map[string]string
+}
+
+// synthesize returns the registries described by opts.
+func synthesize(opts syntheticOptions) ([]*registry, error) {
+	switch {
+	case opts.Registries < 0 || opts.Devices < 0 || opts.Gateways < 0 || opts.DevicesPerGateway < 0:
+		return nil, errors.New("counts cannot be negative")
+	case opts.Gateways > opts.Devices:
+		return nil, fmt.Errorf("%d gateways do not fit in %d devices per registry", opts.Gateways, opts.Devices)
+	case opts.Gateways*opts.DevicesPerGateway > opts.Devices-opts.Gateways:
+		return nil, fmt.Errorf("binding %d devices to each of %d gateways needs more than the %d other devices per registry",
+			opts.DevicesPerGateway, opts.Gateways, opts.Devices-opts.Gateways)
+	}
+
+	registries := []*registry{}
+	for i := 1; i <= opts.Registries; i++ {
+		r := &registry{
+			ID:       expandName(opts.RegistryName, "", i),
+			LogLevel: opts.RegistryLogLevel,
+		}
+
+		gateways := []*device{}
+		for j := 1; j <= opts.Gateways; j++ {
+			gateways = append(gateways, &device{
+				ID:       expandName(opts.GatewayName, r.ID, j),
+				LogLevel: opts.DeviceLogLevel,
+				Gateway:  true,
+				Metadata: opts.Metadata,
+			})
+		}
+		r.Devices = append(r.Devices, gateways...)
+
+		for j := 1; j <= opts.Devices-opts.Gateways; j++ {
+			d := &device{
+				ID:       expandName(opts.DeviceName, r.ID, j),
+				LogLevel: opts.DeviceLogLevel,
+				Metadata: opts.Metadata,
+			}
+			if opts.DevicesPerGateway > 0 {
+				if gateway := (j - 1) / opts.DevicesPerGateway; gateway < len(gateways) {
+					d.GatewayID = gateways[gateway].ID
+				}
+			}
+			r.Devices = append(r.Devices, d)
+		}
+		registries = append(registries, r)
+	}
+	return registries, nil
+}
+
+// expandName replaces the {registry} and {n} placeholders of pattern.
+func expandName(pattern, registryID string, n int) string {
+	return strings.NewReplacer("{registry}", registryID, "{n}", strconv.Itoa(n)).Replace(pattern)
+}
+
+// readInventory reads the registries and devices of a JSON or CSV inventory,
+// chosen by the file extension.
+//
+// A JSON inventory is a list of registries, each with its list of devices.
+// A CSV inventory has a header row and a row per device. Its registry and id
+// columns are required; the log_level, blocked, gateway, gateway_id,
+// public_key_format and public_key columns are optional, and columns named
+// metadata.<key> set device metadata.
+func readInventory(path string) ([]*registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var registries []*registry
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		registries, err = readJSONInventory(f)
+	case ".csv":
+		registries, err = readCSVInventory(f)
+	default:
+		return nil, fmt.Errorf("inventory %s: unsupported extension %q, want .json or .csv", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inventory %s: %w", path, err)
+	}
+	if err := validateInventory(registries); err != nil {
+		return nil, fmt.Errorf("inventory %s: %w", path, err)
+	}
+	return registries, nil
+}
+
+func readJSONInventory(r io.Reader) ([]*registry, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var registries []*registry
+	if err := decoder.Decode(&registries); err != nil {
+		return nil, err
+	}
+	return registries, nil
+}
+
+func readCSVInventory(r io.Reader) ([]*registry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	header := rows[0]
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"registry", "id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+	for name := range columns {
+		switch name {
+		case "registry", "id", "log_level", "blocked", "gateway", "gateway_id", "public_key_format", "public_key":
+		default:
+			if !strings.HasPrefix(name, "metadata.") {
+				return nil, fmt.Errorf("unknown column %q", name)
+			}
+		}
+	}
+
+	byID := map[string]*registry{}
+	registries := []*registry{}
+	for i, row := range rows[1:] {
+		line := i + 2
+		value := func(column string) string {
+			if index, ok := columns[column]; ok {
+				return strings.TrimSpace(row[index])
+			}
+			return ""
+		}
+		parseBool := func(column string) (bool, error) {
+			v := value(column)
+			if v == "" {
+				return false, nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return false, fmt.Errorf("line %d: invalid %s %q", line, column, v)
+			}
+			return b, nil
+		}
+
+		d := &device{
+			ID:              value("id"),
+			LogLevel:        value("log_level"),
+			GatewayID:       value("gateway_id"),
+			PublicKeyFormat: value("public_key_format"),
+			PublicKey:       value("public_key"),
+		}
+		if d.Blocked, err = parseBool("blocked"); err != nil {
+			return nil, err
+		}
+		if d.Gateway, err = parseBool("gateway"); err != nil {
+			return nil, err
+		}
+		for name, index := range columns {
+			if key, ok := strings.CutPrefix(name, "metadata."); ok && row[index] != "" {
+				if d.Metadata == nil {
+					d.Metadata = map[string]string{}
+				}
+				d.Metadata[key] = row[index]
+			}
+		}
+
+		registryID := value("registry")
+		if registryID == "" {
+			return nil, fmt.Errorf("line %d: missing registry", line)
+		}
+		r, ok := byID[registryID]
+		if !ok {
+			r = &registry{ID: registryID}
+			byID[registryID] = r
+			registries = append(registries, r)
+		}
+		r.Devices = append(r.Devices, d)
+	}
+	return registries, nil
+}
+
+// validateInventory checks that IDs are set and unique, and that devices are
+// only bound to gateways of their registry.
+func validateInventory(registries []*registry) error {
+	seen := map[string]bool{}
+	for _, r := range registries {
+		if r.ID == "" {
+			return errors.New("registry without an id")
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("duplicate registry %q", r.ID)
+		}
+		seen[r.ID] = true
+
+		devices := map[string]*device{}
+		for _, d := range r.Devices {
+			if d.ID == "" {
+				return fmt.Errorf("registry %q: device without an id", r.ID)
+			}
+			if devices[d.ID] != nil {
+				return fmt.Errorf("registry %q: duplicate device %q", r.ID, d.ID)
+			}
+			devices[d.ID] = d
+		}
+
+		unknown := []string{}
+		for _, d := range r.Devices {
+			if d.GatewayID == "" {
+				continue
+			}
+			gateway := devices[d.GatewayID]
+			switch {
+			case gateway == nil:
+				unknown = append(unknown, d.GatewayID)
+			case !gateway.Gateway:
+				return fmt.Errorf("registry %q: device %q is bound to %q, which is not a gateway", r.ID, d.ID, d.GatewayID)
+			case d.Gateway:
+				return fmt.Errorf("registry %q: gateway %q cannot be bound to another gateway", r.ID, d.ID)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("registry %q: devices are bound to unknown gateways %q", r.ID, unknown)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Command cb-tfgen generates Terraform configurations of ClearBlade IoT Core
+// registries and devices, either synthetic ones for scale testing or from a
+// JSON or CSV inventory of real devices.
+//
+// Usage:
+//
+//	cb-tfgen [flags]
+//
+// Generate 250 registries with 40 devices each, 2 of them gateways bound to 10
+// devices each:
+//
+//	cb-tfgen -registries 250 -devices 40 -gateways 2 -devices-per-gateway 10 -out ./scale
+//
+// Generate the configuration of an inventory:
+//
+//	cb-tfgen -inventory devices.csv -var project=my-project -out ./fleet
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "cb-tfgen:", err)
+		os.Exit(1)
+	}
+}
+
+// stringMap is a repeatable key=value flag.
+type stringMap map[string]string
+
+func (m stringMap) String() string {
+	pairs := []string{}
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m stringMap) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("%q is not of the form key=value", s)
+	}
+	m[k] = v
+	return nil
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("cb-tfgen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var opts syntheticOptions
+	flags.IntVar(&opts.Registries, "registries", 1, "number of registries to generate")
+	flags.IntVar(&opts.Devices, "devices", 0, "number of devices to generate per registry, including gateways")
+	flags.IntVar(&opts.Gateways, "gateways", 0, "number of the devices of each registry that are gateways")
+	flags.IntVar(&opts.DevicesPerGateway, "devices-per-gateway", 0, "number of devices bound to each gateway")
+	flags.StringVar(&opts.RegistryName, "registry-name", "bas-{n}", "registry ID pattern; {n} is replaced with the registry number")
+	flags.StringVar(&opts.DeviceName, "device-name", "{registry}-device-{n}", "device ID pattern; {registry} is replaced with the registry ID and {n} with the device number")
+	flags.StringVar(&opts.GatewayName, "gateway-name", "{registry}-gateway-{n}", "gateway ID pattern; {registry} is replaced with the registry ID and {n} with the gateway number")
+	flags.StringVar(&opts.RegistryLogLevel, "registry-log-level", "", "log_level of the generated registries")
+	flags.StringVar(&opts.DeviceLogLevel, "device-log-level", "", "log_level of the generated devices")
+	metadata := stringMap{}
+	flags.Var(metadata, "metadata", "metadata `key=value` of the generated devices; may be repeated")
+
+	inventoryPath := flags.String("inventory", "", "JSON or CSV inventory of registries and devices to generate instead of synthetic ones")
+	vars := stringMap{}
+	flags.Var(vars, "var", "default `name=value` of an input variable, such as project, region or credentials_file; may be repeated")
+	out := flags.String("out", ".", "directory to write the configuration to")
+	force := flags.Bool("force", false, "overwrite existing files")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+	opts.Metadata = metadata
+
+	var registries []*registry
+	var err error
+	if *inventoryPath != "" {
+		registries, err = readInventory(*inventoryPath)
+	} else {
+		registries, err = synthesize(opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	files, err := generate(registries, vars)
+	if err != nil {
+		return err
+	}
+	if err := writeFiles(*out, files, *force); err != nil {
+		return err
+	}
+
+	devices := 0
+	for _, r := range registries {
+		devices += len(r.Devices)
+	}
+	fmt.Fprintf(stdout, "Generated %d registries and %d devices in %s\n", len(registries), devices, *out)
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// parseDir parses the configuration files of dir and returns their contents,
+// keyed by file name.
+func parseDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := hclparse.NewParser()
+	files := map[string]string{}
+	for _, path := range paths {
+		if _, diags := parser.ParseHCLFile(path); diags.HasErrors() {
+			t.Fatalf("%s: %s", path, diags)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(path)] = string(data)
+	}
+	return files
+}
+
+func TestRunSynthetic(t *testing.T) {
+	dir := t.TempDir()
+	err := run([]string{
+		"-registries", "2",
+		"-devices", "4",
+		"-gateways", "1",
+		"-devices-per-gateway", "2",
+		"-metadata", "site=plant-1",
+		"-var", "project=my-project",
+		"-out", dir,
+	}, io.Discard, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := parseDir(t, dir)
+	for _, want := range []struct{ file, content string }{
+		{"providers.tf", `default     = "my-project"`},
+		{"registries.tf", `resource "clearblade_iot_registry" "bas-2"`},
+		{"devices.tf", `resource "clearblade_iot_device" "bas-1_bas-1-gateway-1"`},
+		{"devices.tf", `registry = clearblade_iot_registry.bas-2.id`},
+		{"devices.tf", `gateway_type        = "GATEWAY"`},
+		{"bindings.tf", `bas-1-gateway-1 = ["bas-1-device-1", "bas-1-device-2"]`},
+	} {
+		if !strings.Contains(files[want.file], want.content) {
+			t.Errorf("%s does not contain %q:\n%s", want.file, want.content, files[want.file])
+		}
+	}
+	if n := strings.Count(files["devices.tf"], `resource "clearblade_iot_device"`); n != 8 {
+		t.Errorf("generated %d devices, want 8", n)
+	}
+
+	// Generating again does not overwrite the files unless forced, and does
+	// not remove the files it does not write.
+	err = run([]string{"-registries", "1", "-out", dir}, io.Discard, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "use -force to overwrite it") {
+		t.Fatalf("run without -force: got %v, want an error", err)
+	}
+	if got := parseDir(t, dir); !reflect.DeepEqual(got, files) {
+		t.Error("run without -force changed the files")
+	}
+	if err := run([]string{"-registries", "1", "-force", "-out", dir}, io.Discard, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	got := parseDir(t, dir)
+	if strings.Contains(got["registries.tf"], "bas-2") {
+		t.Errorf("registries.tf was not overwritten:\n%s", got["registries.tf"])
+	}
+	for _, name := range []string{"devices.tf", "bindings.tf"} {
+		if got[name] != files[name] {
+			t.Errorf("%s was changed", name)
+		}
+	}
+}
+
+func TestRunInventory(t *testing.T) {
+	for _, tc := range []struct {
+		name, inventory string
+	}{
+		{"inventory.csv", `registry,id,gateway,gateway_id,blocked,metadata.site
+plant,gw-1,true,,,
+plant,sensor-1,,gw-1,true,north
+`},
+		{"inventory.json", `[{"id": "plant", "devices": [
+	{"id": "gw-1", "gateway": true},
+	{"id": "sensor-1", "gateway_id": "gw-1", "blocked": true, "metadata": {"site": "north"}}
+]}]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tc.name)
+			if err := os.WriteFile(path, []byte(tc.inventory), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(dir, "out")
+			if err := run([]string{"-inventory", path, "-out", out}, io.Discard, io.Discard); err != nil {
+				t.Fatal(err)
+			}
+
+			files := parseDir(t, out)
+			for _, want := range []struct{ file, content string }{
+				{"devices.tf", `blocked  = true`},
+				{"devices.tf", `site = "north"`},
+				{"bindings.tf", `gw-1 = ["sensor-1"]`},
+			} {
+				if !strings.Contains(files[want.file], want.content) {
+					t.Errorf("%s does not contain %q:\n%s", want.file, want.content, files[want.file])
+				}
+			}
+		})
+	}
+}
+
+func TestReadInventoryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, inventory, err string
+	}{
+		{"columns.csv", "registry,id,name\nplant,d1,x\n", `unknown column "name"`},
+		{"duplicate.csv", "registry,id\nplant,d1\nplant,d1\n", `duplicate device "d1"`},
+		{"unknown.csv", "registry,id,gateway_id\nplant,d1,gw-1\n", `unknown gateways ["gw-1"]`},
+		{"not-gateway.csv", "registry,id,gateway_id\nplant,d1,\nplant,d2,d1\n", `which is not a gateway`},
+		{"blocked.csv", "registry,id,blocked\nplant,d1,maybe\n", `line 2: invalid blocked "maybe"`},
+		{"fields.json", `[{"id": "plant", "devices": [{"id": "d1", "name": "x"}]}]`, `unknown field "name"`},
+		{"inventory.yaml", "", `unsupported extension ".yaml"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(path, []byte(tc.inventory), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := readInventory(path)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("readInventory() error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestSynthesizeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		opts syntheticOptions
+		err  string
+	}{
+		{syntheticOptions{Registries: -1}, "counts cannot be negative"},
+		{syntheticOptions{Registries: 1, Devices: 1, Gateways: 2}, "2 gateways do not fit in 1 devices"},
+		{syntheticOptions{Registries: 1, Devices: 3, Gateways: 1, DevicesPerGateway: 3}, "needs more than the 2 other devices"},
+	} {
+		_, err := synthesize(tc.opts)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("synthesize(%+v) error %v, want %q", tc.opts, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+# Look up a single device by its ID
+data "clearblade_iot_device" "example" {
+  registry = "example-registry"
+  id       = "example-device"
+}
+
+# Or by its numeric ID
+data "clearblade_iot_device" "by_num_id" {
+  registry = "example-registry"
+  num_id   = "2820062810421365"
+}
+
+output "device_metadata" {
+  value = data.clearblade_iot_device.example.metadata
+}
@@ -0,0 +1,17 @@
+# Find which registry a device lives in
+data "clearblade_iot_device_search" "by_id" {
+  id = "example-device"
+}
+
+# Find the devices of one site across every registry
+data "clearblade_iot_device_search" "site" {
+  id_prefix = "sensor-"
+  metadata = {
+    site = "plant-7"
+  }
+  concurrency = 4
+}
+
+output "device_registry" {
+  value = one(data.clearblade_iot_device_search.by_id.devices[*].registry)
+}
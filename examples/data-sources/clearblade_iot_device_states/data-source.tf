@@ -0,0 +1,10 @@
+# List the most recent states reported by a device
+data "clearblade_iot_device_states" "example" {
+  registry   = "example-registry"
+  device_id  = "example-device"
+  num_states = 5
+}
+
+output "latest_state" {
+  value = data.clearblade_iot_device_states.example.states[0].data_text
+}
@@ -0,0 +1,13 @@
+# Look up a single device registry by its ID
+data "clearblade_iot_registry" "example" {
+  id = "example-registry"
+}
+
+# Or by its full resource path
+data "clearblade_iot_registry" "by_name" {
+  id = "projects/example-project/locations/us-central1/registries/example-registry"
+}
+
+output "registry_certificate_expiry" {
+  value = [for c in data.clearblade_iot_registry.example.credentials : c.public_key_certificate.x509_details.expiry_time]
+}
@@ -0,0 +1,19 @@
+data "clearblade_iot_registry_summary" "example" {
+  registry             = "example-registry"
+  stale_after_hours    = 48
+  expiring_within_days = 14
+}
+
+output "stale_devices" {
+  value = data.clearblade_iot_registry_summary.example.stale_count
+}
+
+# Fail the plan when too many devices are blocked
+resource "terraform_data" "guard" {
+  lifecycle {
+    precondition {
+      condition     = data.clearblade_iot_registry_summary.example.blocked_count < 10
+      error_message = "More than 10 devices are blocked."
+    }
+  }
+}
@@ -0,0 +1,14 @@
+# Ask a device to reload its certificates whenever they are rotated
+resource "clearblade_iot_device_command" "reload_certificates" {
+  registry  = clearblade_iot_device.example.registry
+  device_id = clearblade_iot_device.example.id
+  subfolder = "maintenance"
+  data_text = jsonencode({ action = "reload-certificates" })
+
+  # Devices that are offline only produce a warning
+  on_disconnected = "WARNING"
+
+  triggers = {
+    certificate = var.device_certificate
+  }
+}
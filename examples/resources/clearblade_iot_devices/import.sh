@@ -0,0 +1,2 @@
+# Import every device of a registry
+terraform import clearblade_iot_devices.sensors example-registry
@@ -0,0 +1,15 @@
+locals {
+  sensors = { for i in range(1000) : format("sensor-%04d", i) => i }
+}
+
+resource "clearblade_iot_devices" "sensors" {
+  registry    = "example-registry"
+  concurrency = 16
+
+  devices = { for id, i in local.sensors : id => {
+    log_level = "INFO"
+    metadata = {
+      site = i < 500 ? "plant-7" : "plant-8"
+    }
+  } }
+}
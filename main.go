@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"terraform-provider-clearblade/clearblade"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
@@ -20,6 +22,26 @@ import (
 ) */
 
 func main() {
+	// terraform-provider-clearblade generate writes the configuration of
+	// existing registries and devices; see clearblade.Generate.
+	// terraform-provider-clearblade convert writes the configuration of
+	// exported Google Cloud IoT Core ones; see clearblade.Convert.
+	commands := map[string]func(args []string) error{
+		"generate": func(args []string) error {
+			return clearblade.Generate(context.Background(), args, os.Stdout, os.Stderr)
+		},
+		"convert": func(args []string) error {
+			return clearblade.Convert(args, os.Stdout, os.Stderr)
+		},
+	}
+	if len(os.Args) > 1 && commands[os.Args[1]] != nil {
+		if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	providerserver.Serve(context.Background(), clearblade.New, providerserver.ServeOpts{
 		// NOTE: This is not a typical Terraform Registry provider address,
 		// such as registry.terraform.io/hashicorp/hashicups. This specific
@@ -28,4 +50,8 @@ func main() {
 		// of this provider.
 		Address: "registry.terraform.io/clearblade/clearblade",
 	})
+	if err := clearblade.Shutdown(); err != nil {
+		fmt.Fprintf(os.Stderr, "shutdown: %s\n", err)
+		os.Exit(1)
+	}
 }